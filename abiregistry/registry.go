@@ -0,0 +1,344 @@
+package abiregistry
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+var (
+	// EIP-1967 slots, i.e. bytes32(uint256(keccak256('eip1967.proxy.implementation')) - 1)
+	// and bytes32(uint256(keccak256('eip1967.proxy.beacon')) - 1)
+	ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	BeaconSlot         = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+
+	// selector of implementation() of beacons
+	implementationSelector = common.FromHex("0x5c60da1b")
+)
+
+// DefaultProxyTTL is how long the implementation of a proxy is cached, since proxies may be upgraded.
+const DefaultProxyTTL = 10 * time.Minute
+
+// Backend is used for resolving implementations of EIP-1967 proxies.
+type Backend interface {
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Call is calldata decoded.
+type Call struct {
+	Method abi.Method
+	Args   map[string]interface{}
+}
+
+// Event is a log decoded.
+type Event struct {
+	Event abi.Event
+	Args  map[string]interface{}
+}
+
+type proxy struct {
+	implementation common.Address // zero if not a proxy
+	err            error          // failed to resolve, retried once the ttl elapsed
+	resolvedAt     time.Time
+}
+
+// mergedKey is the key of an ABI merged for addr, which depends on the implementation it proxies to.
+type mergedKey struct {
+	addr           common.Address
+	implementation common.Address
+}
+
+// Registry keeps ABIs by contract address, so that contracts with the same
+// error selectors or event names decode against their own ABI.
+// ABIs not bound to any address are used as a fallback.
+//
+// ABIs are replaced rather than modified when added, so that the ones returned can be shared.
+type Registry struct {
+	backend  Backend
+	proxyTTL time.Duration
+
+	mu      sync.RWMutex
+	global  abi.ABI
+	abis    map[common.Address]abi.ABI
+	proxies map[common.Address]proxy
+	// ABIs merged for addresses with their own ABIs, until ABIs are added
+	merged map[mergedKey]abi.ABI
+	// selectors of the methods of every ABI added
+	selectors map[string]struct{}
+}
+
+// NewRegistry creates a registry. Proxies are not resolved if backend is nil.
+func NewRegistry(backend Backend) *Registry {
+	return &Registry{
+		backend:   backend,
+		proxyTTL:  DefaultProxyTTL,
+		global:    newABI(),
+		abis:      make(map[common.Address]abi.ABI),
+		proxies:   make(map[common.Address]proxy),
+		merged:    make(map[mergedKey]abi.ABI),
+		selectors: make(map[string]struct{}),
+	}
+}
+
+// SetProxyTTL sets how long the implementation of a proxy is cached, 0 for resolving it every time.
+func (r *Registry) SetProxyTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.proxyTTL = ttl
+}
+
+// AddABI adds intf to the fallback ABIs used for every address.
+func (r *Registry) AddABI(intf abi.ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	global := newABI()
+	mergeABI(&global, r.global)
+	mergeABI(&global, intf)
+	r.global = global
+	r.merged = make(map[mergedKey]abi.ABI)
+	r.addSelectors(intf)
+}
+
+// Register binds intf to the contract at addr, merging it with the ABIs registered before.
+func (r *Registry) Register(addr common.Address, intf abi.ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered := newABI()
+	mergeABI(&registered, r.abis[addr])
+	mergeABI(&registered, intf)
+	r.abis[addr] = registered
+	r.merged = make(map[mergedKey]abi.ABI)
+	r.addSelectors(intf)
+}
+
+func (r *Registry) addSelectors(intf abi.ABI) {
+	for _, m := range intf.Methods {
+		r.selectors[string(m.ID)] = struct{}{}
+	}
+}
+
+// CanDecodeCall reports whether calls to addr with selector may be decoded, i.e. addr has its own ABIs or
+// selector is a method of any ABI added, without resolving proxies.
+// Callers decoding lots of calls check it first, so that unknown calls cost no proxy lookups.
+func (r *Registry) CanDecodeCall(addr common.Address, selector []byte) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.abis[addr]; ok {
+		return true
+	}
+	_, ok := r.selectors[string(selector)]
+	return ok
+}
+
+// ABIOf returns the ABI used for decoding data of the contract at addr,
+// i.e. the ABI of addr over the one of its implementation if it's an EIP-1967 proxy,
+// over the fallback ABIs. Proxies are only resolved if ABIs are registered by address.
+// The ABI returned is shared, so it must not be modified.
+func (r *Registry) ABIOf(ctx context.Context, addr *common.Address) abi.ABI {
+	r.mu.RLock()
+	global, registered := r.global, len(r.abis) > 0
+	r.mu.RUnlock()
+
+	if addr == nil || !registered {
+		return global
+	}
+
+	implementation, err := r.Implementation(ctx, *addr)
+	if err != nil {
+		log.Debug("resolve proxy failed", "addr", addr.Hex(), "err", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := mergedKey{addr: *addr, implementation: implementation}
+	if intf, ok := r.merged[key]; ok {
+		return intf
+	}
+
+	own, hasOwn := r.abis[*addr]
+	impl, hasImpl := r.abis[implementation]
+	if !hasOwn && (implementation == (common.Address{}) || !hasImpl) {
+		return r.global
+	}
+
+	intf := newABI()
+	mergeABI(&intf, r.global)
+	if implementation != (common.Address{}) {
+		mergeABI(&intf, impl)
+	}
+	mergeABI(&intf, own)
+	r.merged[key] = intf
+
+	return intf
+}
+
+// Implementation returns the implementation of the EIP-1967 proxy at addr,
+// or the zero address if it's not a proxy. Results, failures included, are cached for the proxy ttl.
+func (r *Registry) Implementation(ctx context.Context, addr common.Address) (common.Address, error) {
+	if r.backend == nil {
+		return common.Address{}, nil
+	}
+
+	r.mu.RLock()
+	cached, ok := r.proxies[addr]
+	ttl := r.proxyTTL
+	r.mu.RUnlock()
+	if ok && time.Since(cached.resolvedAt) < ttl {
+		return cached.implementation, cached.err
+	}
+
+	implementation, err := r.resolveProxy(ctx, addr)
+	if err != nil && ctx.Err() != nil {
+		// Cancelled by the caller, it says nothing about addr.
+		return common.Address{}, err
+	}
+
+	r.mu.Lock()
+	r.proxies[addr] = proxy{implementation: implementation, err: err, resolvedAt: time.Now()}
+	r.mu.Unlock()
+
+	return implementation, err
+}
+
+func (r *Registry) resolveProxy(ctx context.Context, addr common.Address) (common.Address, error) {
+	slot, err := r.backend.StorageAt(ctx, addr, ImplementationSlot, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("read implementation slot err: %v", err)
+	}
+
+	if implementation := common.BytesToAddress(slot); implementation != (common.Address{}) {
+		return implementation, nil
+	}
+
+	slot, err = r.backend.StorageAt(ctx, addr, BeaconSlot, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("read beacon slot err: %v", err)
+	}
+
+	beacon := common.BytesToAddress(slot)
+	if beacon == (common.Address{}) {
+		return common.Address{}, nil
+	}
+
+	ret, err := r.backend.CallContract(ctx, ethereum.CallMsg{To: &beacon, Data: implementationSelector}, nil)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("call implementation of beacon %v err: %v", beacon.Hex(), err)
+	}
+
+	return common.BytesToAddress(ret), nil
+}
+
+// DecodeError decodes err returned by calling or sending txs to the contract at to.
+func (r *Registry) DecodeError(ctx context.Context, to *common.Address, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return consts.DecodeJsonRpcError(err, r.ABIOf(ctx, to))
+}
+
+// DecodeCalldata decodes data of a call to the contract at to.
+func (r *Registry) DecodeCalldata(ctx context.Context, to *common.Address, data []byte) (*Call, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("calldata too short: %d bytes", len(data))
+	}
+
+	intf := r.ABIOf(ctx, to)
+	method, err := intf.MethodById(data[:4])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, data[4:]); err != nil {
+		return nil, fmt.Errorf("unpack args of %v err: %v", method.Name, err)
+	}
+
+	return &Call{Method: *method, Args: args}, nil
+}
+
+// DecodeLog decodes l against the ABI of the contract emitting it.
+// Anonymous events are not supported.
+func (r *Registry) DecodeLog(ctx context.Context, l types.Log) (*Event, error) {
+	if len(l.Topics) == 0 {
+		return nil, fmt.Errorf("log without topics")
+	}
+
+	intf := r.ABIOf(ctx, &l.Address)
+	event, err := intf.EventByID(l.Topics[0])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]interface{})
+	if len(l.Data) > 0 {
+		if err := event.Inputs.UnpackIntoMap(args, l.Data); err != nil {
+			return nil, fmt.Errorf("unpack data of %v err: %v", event.Name, err)
+		}
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, l.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("parse topics of %v err: %v", event.Name, err)
+	}
+
+	return &Event{Event: *event, Args: args}, nil
+}
+
+func newABI() abi.ABI {
+	return abi.ABI{
+		Methods: make(map[string]abi.Method),
+		Events:  make(map[string]abi.Event),
+		Errors:  make(map[string]abi.Error),
+	}
+}
+
+// mergeABI adds the methods, events and errors of src to dst, replacing the ones with the same names or ids.
+func mergeABI(dst *abi.ABI, src abi.ABI) {
+	for k, v := range src.Methods {
+		for name, m := range dst.Methods {
+			if string(m.ID) == string(v.ID) {
+				delete(dst.Methods, name)
+			}
+		}
+		dst.Methods[k] = v
+	}
+
+	for k, v := range src.Events {
+		for name, e := range dst.Events {
+			if e.ID == v.ID {
+				delete(dst.Events, name)
+			}
+		}
+		dst.Events[k] = v
+	}
+
+	for k, v := range src.Errors {
+		for name, e := range dst.Errors {
+			if e.ID == v.ID {
+				delete(dst.Errors, name)
+			}
+		}
+		dst.Errors[k] = v
+	}
+}
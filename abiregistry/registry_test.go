@@ -0,0 +1,244 @@
+package abiregistry
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+const (
+	erc20ABI = `[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+		{"type":"error","name":"Failed","inputs":[{"name":"value","type":"uint256"}]},
+		{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}
+	]`
+	erc721ABI = `[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+		{"type":"error","name":"Failed","inputs":[{"name":"owner","type":"address"}]}
+	]`
+)
+
+type rpcDataError struct {
+	data string
+}
+
+func (e rpcDataError) Error() string          { return "execution reverted" }
+func (e rpcDataError) ErrorCode() int         { return 3 }
+func (e rpcDataError) ErrorData() interface{} { return e.data }
+
+type fakeBackend struct {
+	storage map[common.Address]map[common.Hash]common.Hash
+	// implementations of beacons
+	beacons map[common.Address]common.Address
+	reads   int
+	err     error
+}
+
+func (b *fakeBackend) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	b.reads++
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.storage[account][key].Bytes(), nil
+}
+
+func (b *fakeBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	implementation, ok := b.beacons[*msg.To]
+	if !ok {
+		return nil, errors.New("not a beacon")
+	}
+	return common.LeftPadBytes(implementation.Bytes(), 32), nil
+}
+
+func mustParseABI(t *testing.T, def string) abi.ABI {
+	intf, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return intf
+}
+
+func TestRegistry_SameNames(t *testing.T) {
+	token, nft := common.HexToAddress("0x20"), common.HexToAddress("0x721")
+	erc20, erc721 := mustParseABI(t, erc20ABI), mustParseABI(t, erc721ABI)
+
+	r := NewRegistry(nil)
+	r.AddABI(erc20)
+	r.Register(token, erc20)
+	r.Register(nft, erc721)
+
+	from, to := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	topics := []common.Hash{erc20.Events["Transfer"].ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+
+	event, err := r.DecodeLog(context.Background(), types.Log{
+		Address: token,
+		Topics:  topics,
+		Data:    common.LeftPadBytes(big.NewInt(100).Bytes(), 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Args["value"].(*big.Int).Int64() != 100 || event.Args["to"].(common.Address) != to {
+		t.Fatalf("unexpected erc20 transfer: %v", event.Args)
+	}
+
+	event, err = r.DecodeLog(context.Background(), types.Log{
+		Address: nft,
+		Topics:  append(topics, common.BigToHash(big.NewInt(7))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Args["tokenId"].(*big.Int).Int64() != 7 {
+		t.Fatalf("unexpected erc721 transfer: %v", event.Args)
+	}
+
+	data, err := erc721.Errors["Failed"].Inputs.Pack(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := erc721.Errors["Failed"].ID
+	revert := rpcDataError{hexutil.Encode(append(id[:4], data...))}
+
+	var jsonErr *consts.JsonRpcError
+	if err := r.DecodeError(context.Background(), &nft, revert); !errors.As(err, &jsonErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, ok := jsonErr.DecodedData.(consts.RevertError)
+	if !ok || decoded.FuncSignature != "Failed(address owner)" {
+		t.Fatalf("want error decoded with the abi of %v, got %v", nft.Hex(), jsonErr.DecodedData)
+	}
+
+	call, err := r.DecodeCalldata(context.Background(), &token, append(erc20.Methods["transfer"].ID, common.LeftPadBytes(to.Bytes(), 32)...))
+	if err == nil {
+		t.Fatalf("want short args not decoded, got %v", call.Args)
+	}
+	packed, err := erc20.Pack("transfer", to, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err = r.DecodeCalldata(context.Background(), &token, packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Method.Name != "transfer" || call.Args["value"].(*big.Int).Int64() != 5 {
+		t.Fatalf("unexpected call: %v %v", call.Method.Name, call.Args)
+	}
+
+	// fallback
+	other := common.HexToAddress("0x3")
+	if _, err := r.DecodeCalldata(context.Background(), &other, packed); err != nil {
+		t.Fatalf("want calldata decoded with the abis added: %v", err)
+	}
+}
+
+func TestRegistry_Proxy(t *testing.T) {
+	proxy, implementation := common.HexToAddress("0x1967"), common.HexToAddress("0x20")
+	beaconProxy, beacon := common.HexToAddress("0xbeac0"), common.HexToAddress("0xbeac")
+	erc20 := mustParseABI(t, erc20ABI)
+
+	backend := &fakeBackend{
+		storage: map[common.Address]map[common.Hash]common.Hash{
+			proxy:       {ImplementationSlot: common.BytesToHash(implementation.Bytes())},
+			beaconProxy: {BeaconSlot: common.BytesToHash(beacon.Bytes())},
+		},
+		beacons: map[common.Address]common.Address{beacon: implementation},
+	}
+	r := NewRegistry(backend)
+	r.Register(implementation, erc20)
+
+	for _, addr := range []common.Address{proxy, beaconProxy} {
+		got, err := r.Implementation(context.Background(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != implementation {
+			t.Fatalf("want implementation of %v resolved, got %v", addr.Hex(), got.Hex())
+		}
+
+		if _, ok := r.ABIOf(context.Background(), &addr).Methods["transfer"]; !ok {
+			t.Fatalf("want abi of %v from its implementation", addr.Hex())
+		}
+	}
+
+	other := common.HexToAddress("0x3")
+	if _, ok := r.ABIOf(context.Background(), &other).Methods["transfer"]; ok {
+		t.Fatal("want abi of the implementation only for its proxies")
+	}
+
+	reads := backend.reads
+	r.ABIOf(context.Background(), &proxy)
+	if backend.reads != reads {
+		t.Fatal("want implementation cached")
+	}
+
+	r.SetProxyTTL(0)
+	r.ABIOf(context.Background(), &proxy)
+	if backend.reads == reads {
+		t.Fatal("want implementation resolved again once expired")
+	}
+}
+
+func TestRegistry_Lookups(t *testing.T) {
+	token, other := common.HexToAddress("0x20"), common.HexToAddress("0x3")
+	erc20, erc721 := mustParseABI(t, erc20ABI), mustParseABI(t, erc721ABI)
+
+	backend := &fakeBackend{err: errors.New("node down")}
+	r := NewRegistry(backend)
+	r.AddABI(erc721)
+
+	// nothing registered by address, so no proxy to resolve
+	if _, ok := r.ABIOf(context.Background(), &other).Events["Transfer"]; !ok || backend.reads != 0 {
+		t.Fatalf("want the fallback abi without reads, got %v reads", backend.reads)
+	}
+
+	r.Register(token, erc20)
+
+	// failures are cached too
+	for i := 0; i < 3; i++ {
+		if _, ok := r.ABIOf(context.Background(), &token).Methods["transfer"]; !ok {
+			t.Fatal("want the abi of the address")
+		}
+	}
+	if backend.reads != 1 {
+		t.Fatalf("want the failure to resolve the proxy cached, got %v reads", backend.reads)
+	}
+	if _, err := r.Implementation(context.Background(), token); err == nil {
+		t.Fatal("want the failure cached")
+	}
+
+	// merged abis are kept until abis are added
+	extra := mustParseABI(t, `[{"type":"function","name":"mint","inputs":[],"outputs":[]}]`)
+	r.Register(token, extra)
+	intf := r.ABIOf(context.Background(), &token)
+	if _, ok := intf.Methods["mint"]; !ok {
+		t.Fatal("want the abi registered last")
+	}
+	if _, ok := intf.Methods["transfer"]; !ok {
+		t.Fatal("want the abis registered before kept")
+	}
+	r.AddABI(mustParseABI(t, `[{"type":"function","name":"burn","inputs":[],"outputs":[]}]`))
+	if _, ok := r.ABIOf(context.Background(), &token).Methods["burn"]; !ok {
+		t.Fatal("want the abi added last")
+	}
+	if _, ok := intf.Methods["burn"]; ok {
+		t.Fatal("abis returned before should not be modified")
+	}
+	// calls decodable are told without resolving proxies
+	reads := backend.reads
+	if !r.CanDecodeCall(token, []byte{1, 2, 3, 4}) || !r.CanDecodeCall(other, erc20.Methods["transfer"].ID) {
+		t.Fatal("want calls to addresses with their own abis or of methods known decodable")
+	}
+	if r.CanDecodeCall(other, []byte{1, 2, 3, 4}) || backend.reads != reads {
+		t.Fatalf("want unknown calls told without reads, got %v reads", backend.reads-reads)
+	}
+}
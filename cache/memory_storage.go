@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"github.com/ethereum/go-ethereum/common/lru"
+)
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// MemoryStorage keeps the most recently used entries in memory.
+type MemoryStorage struct {
+	lru *lru.Cache[string, []byte]
+}
+
+func NewMemoryStorage(size int) *MemoryStorage {
+	return &MemoryStorage{lru: lru.NewCache[string, []byte](size)}
+}
+
+func (s *MemoryStorage) Get(key string) ([]byte, bool, error) {
+	value, ok := s.lru.Get(key)
+	return value, ok, nil
+}
+
+func (s *MemoryStorage) Set(key string, value []byte) error {
+	s.lru.Add(key, value)
+	return nil
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/go-redsync/redsync/v4/redis"
+)
+
+var _ Storage = (*RedisStorage)(nil)
+
+// RedisStorage persists entries in redis, so that they're shared by processes and survive restarts.
+// Entries never expire, since they're immutable.
+type RedisStorage struct {
+	chainId   *big.Int
+	redisPool redis.Pool
+}
+
+func NewRedisStorage(chainId *big.Int, pool redis.Pool) *RedisStorage {
+	return &RedisStorage{
+		chainId:   chainId,
+		redisPool: pool,
+	}
+}
+
+func (s *RedisStorage) Get(key string) ([]byte, bool, error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	value, err := conn.Get(s.key(key))
+	if err != nil {
+		return nil, false, err
+	}
+	if value == "" {
+		return nil, false, nil
+	}
+
+	return []byte(value), true, nil
+}
+
+func (s *RedisStorage) Set(key string, value []byte) error {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Set(s.key(key), string(value))
+	return err
+}
+
+func (s *RedisStorage) key(key string) string {
+	return fmt.Sprintf("cache-chain-%s-%s", s.chainId.String(), key)
+}
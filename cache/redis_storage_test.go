@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	goredislib "github.com/redis/go-redis/v9"
+)
+
+func TestRedisStorage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	pool := goredis.NewPool(goredislib.NewClient(&goredislib.Options{Addr: mr.Addr()}))
+	storage := NewRedisStorage(big.NewInt(1), pool)
+
+	if _, ok, err := storage.Get("eth_chainId:[]"); ok || err != nil {
+		t.Fatalf("want missing key, got %v %v", ok, err)
+	}
+
+	if err := storage.Set("eth_chainId:[]", []byte(`"0x1"`)); err != nil {
+		t.Fatal(err)
+	}
+
+	value, ok, err := storage.Get("eth_chainId:[]")
+	if err != nil || !ok || string(value) != `"0x1"` {
+		t.Fatalf("unexpected value: %s %v %v", value, ok, err)
+	}
+
+	// keys are separated by chain
+	if _, ok, _ := NewRedisStorage(big.NewInt(2), pool).Get("eth_chainId:[]"); ok {
+		t.Fatal("want key of another chain missing")
+	}
+}
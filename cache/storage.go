@@ -0,0 +1,8 @@
+package cache
+
+// Storage stores responses of immutable data, e.g. blocks by hash, keyed by method and params.
+type Storage interface {
+	// Get returns the value of key, and false if it's not stored.
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte) error
+}
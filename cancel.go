@@ -0,0 +1,173 @@
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/message"
+)
+
+// CancelMsg cancels a msg scheduled. A msg not broadcasted yet is dropped by the pipeline,
+// and the next ticks of a recurring msg are not executed any more. The tx of a msg broadcasted is
+// replaced by a zero-value self-transfer with the same nonce, which may fail if the tx gets on chain first.
+func (c *Client) CancelMsg(ctx context.Context, msgId common.Hash) error {
+	msg, err := c.msgStore.GetMsg(msgId)
+	if err != nil {
+		return err
+	}
+
+	if msg.Req.Interval != 0 {
+		err = c.cancelChain(chainRoot(msg))
+		if err != nil {
+			return err
+		}
+	}
+
+	switch msg.Status {
+	case message.MessageStatusSubmitted, message.MessageStatusScheduled, message.MessageStatusQueued:
+		log.Info("cancel msg", "msgId", msgId.Hex(), "status", msg.Status)
+		err = message.SwapMsgStatus(c.msgStore, msgId, notBroadcastedStatuses, message.MessageStatusCancelled)
+		if errors.Is(err, message.ErrMsgStatusChanged) {
+			// Broadcasted meanwhile, so its tx is cancelled instead.
+			return c.CancelMsg(ctx, msgId)
+		}
+		return err
+	case message.MessageStatusNonceAssigned, message.MessageStatusInflight:
+		resp := c.msgManager.CancelMsg(ctx, msgId)
+		return resp.Err
+	case message.MessageStatusCancelled:
+		return nil
+	default:
+		if msg.Req.Interval != 0 {
+			// Only the next ticks are cancelled.
+			return nil
+		}
+		return fmt.Errorf("msg can not be cancelled in status %v", msg.Status)
+	}
+}
+
+// notBroadcastedStatuses are the statuses of msgs cancelled without sending a tx.
+var notBroadcastedStatuses = []message.MessageStatus{
+	message.MessageStatusSubmitted,
+	message.MessageStatusScheduled,
+	message.MessageStatusQueued,
+}
+
+// protectStopper is implemented by broadcasters able to stop protecting msgs, e.g. SimpleBroadcaster.
+type protectStopper interface {
+	StopProtecting(msgId common.Hash) bool
+}
+
+// ReplaceMsg replaces the tx of a msg broadcasted by the one of newReq, with the same nonce.
+// The msg replaced is marked as MessageStatusNonceReleased, and newReq is protected instead.
+func (c *Client) ReplaceMsg(ctx context.Context, msgId common.Hash, newReq *message.Request) (resp message.Response) {
+	req := newReq.Copy()
+	if req.Id() == (common.Hash{}) {
+		message.AssignMessageId(req)
+	}
+
+	// Stopped before its nonce is reused, so that it's not bumped or marked on chain any more.
+	protected := false
+	if stopper, ok := c.broadcaster.(protectStopper); ok {
+		protected = stopper.StopProtecting(msgId)
+	}
+
+	resp = c.msgManager.ReplaceMsg(ctx, msgId, *req)
+	if resp.Err != nil {
+		if protected {
+			c.broadcaster.ProtectMsg(ctx, msgId)
+		}
+		return
+	}
+
+	c.broadcaster.ProtectMsg(ctx, req.Id())
+
+	return
+}
+
+// cancelChain stops the recurring msg rooted at root, cancelling its ticks not broadcasted yet.
+func (c *Client) cancelChain(root common.Hash) error {
+	c.cancelledChains.Store(root, struct{}{})
+
+	lister, ok := c.msgStore.(message.StorageLister)
+	if !ok {
+		return nil
+	}
+
+	for _, status := range []message.MessageStatus{
+		message.MessageStatusSubmitted,
+		message.MessageStatusScheduled,
+		message.MessageStatusQueued,
+	} {
+		msgIds, err := lister.MsgIdsByStatus(status)
+		if err != nil {
+			return err
+		}
+
+		for _, msgId := range msgIds {
+			msg, err := c.msgStore.GetMsg(msgId)
+			if err != nil {
+				return err
+			}
+
+			if msg.Req.Interval == 0 || chainRoot(msg) != root {
+				continue
+			}
+
+			log.Info("cancel tick of recurring msg", "msgId", msgId.Hex(), "root", root.Hex())
+			err = message.SwapMsgStatus(c.msgStore, msgId, notBroadcastedStatuses, message.MessageStatusCancelled)
+			if errors.Is(err, message.ErrMsgStatusChanged) {
+				// Broadcasted meanwhile, only the next ticks are cancelled.
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isMsgCancelled reports whether the msg should be dropped by the pipeline.
+func (c *Client) isMsgCancelled(msgId common.Hash) bool {
+	msg, err := c.msgStore.GetMsg(msgId)
+	if err != nil {
+		return false
+	}
+
+	if msg.Status == message.MessageStatusCancelled {
+		return true
+	}
+
+	if msg.Req.Interval == 0 {
+		return false
+	}
+
+	_, cancelled := c.cancelledChains.Load(chainRoot(msg))
+	return cancelled
+}
+
+// dropCancelledMsg marks the msg dropped by the pipeline as cancelled, returning the error responded.
+func (c *Client) dropCancelledMsg(msgId common.Hash) error {
+	log.Info("drop cancelled msg", "msgId", msgId.Hex())
+
+	err := c.msgStore.UpdateMsgStatus(msgId, message.MessageStatusCancelled)
+	if err != nil {
+		return err
+	}
+	c.handleStatus(msgId, message.MessageStatusCancelled)
+
+	return message.ErrMsgCancelled
+}
+
+// chainRoot returns the first msg of the recurring msg which msg belongs to.
+func chainRoot(msg message.Message) common.Hash {
+	if msg.Root != nil {
+		return *msg.Root
+	}
+	return msg.Id()
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -13,16 +15,23 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/abiregistry"
 	"github.com/ivanzzeth/ethclient/account"
 	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/gas"
 	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/metrics"
+	"github.com/ivanzzeth/ethclient/multicall"
 	"github.com/ivanzzeth/ethclient/nonce"
 	"github.com/ivanzzeth/ethclient/subscriber"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Implements Ethereum interfaces
@@ -50,14 +59,18 @@ var _ ethereum.ChainIDReader = (*Client)(nil)
 
 // Implements interfaces
 var _ message.StorageReader = (*Client)(nil)
+var _ message.StorageQuerier = (*Client)(nil)
 
 type Client struct {
 	*ethclient.Client
 	*gethClient
 	rpcClient *rpc.Client
+	// transports owned by the client, e.g. the failover transport of DialMulti
+	transports []io.Closer
 
-	msgBuffer int
-	abi       abi.ABI
+	msgBuffer   int
+	abiRegistry *abiregistry.Registry
+	multicaller *multicall.Multicaller
 
 	closed          atomic.Bool
 	reqClosed       atomic.Bool
@@ -73,6 +86,26 @@ type Client struct {
 	msgSequencer message.Sequencer
 	broadcaster  message.Broadcaster
 
+	finalityTracker *message.FinalityTracker
+	nonceGapFiller  *message.NonceGapFiller
+	// msgs being retried by the broadcast stage
+	retries       sync.WaitGroup
+	retryCtx      context.Context
+	cancelRetries context.CancelFunc
+	// roots of recurring msgs cancelled
+	cancelledChains sync.Map
+	receiptMu       sync.Mutex
+	receiptClosed   bool
+
+	recoveryReport RecoveryReport
+	metrics        atomic.Pointer[metrics.Metrics]
+	events         *message.EventBus
+	eventMu        sync.Mutex
+	lastEvents     *lru.Cache[common.Hash, eventKey]
+	tracer         trace.Tracer
+	// traces of msgs unfinished by msgId
+	msgTraces sync.Map
+
 	subscriber.Subscriber
 }
 
@@ -138,16 +171,49 @@ func NewEthClient(
 		respChannel:     make(chan message.Response, consts.DefaultMsgBuffer),
 		receiptChannel:  make(chan message.Receipt, consts.DefaultMsgBuffer),
 		msgBuffer:       consts.DefaultMsgBuffer,
+		abiRegistry:     abiregistry.NewRegistry(ethc),
 		msgStore:        msgStore,
 		msgSequencer:    sequencer,
 		nonceManager:    nonceManager,
 		msgManager:      msgManager,
-		broadcaster:     message.NewSimpleBroadcaster(msgManager),
 		Subscriber:      subscriber,
+		tracer:          otel.Tracer(tracerName),
+		events:          message.NewEventBus(),
+		lastEvents:      lru.NewCache[common.Hash, eventKey](trackedEvents),
+	}
+
+	cli.retryCtx, cli.cancelRetries = context.WithCancel(context.Background())
+	cli.multicaller = multicall.NewMulticaller(ethc, cli.abiRegistry, multicall.DefaultConfig())
+
+	if mm, ok := msgManager.(*message.SimpleManager); ok {
+		mm.SetStatusHandler(cli.handleStatus)
+	}
+
+	broadcaster := message.NewSimpleBroadcaster(msgManager)
+	broadcaster.SetReceiptHandler(cli.emitReceipt)
+	broadcaster.SetReplacementHandler(cli.handleReplacement)
+	cli.broadcaster = broadcaster
+
+	cli.finalityTracker = message.NewFinalityTracker(ethc, msgStore, broadcaster, message.DefaultFinalityConfig())
+	cli.finalityTracker.SetReceiptHandler(cli.emitReceipt)
+	go cli.finalityTracker.Run(context.Background())
+
+	if filler, ok := msgManager.(interface {
+		FillNonceGaps(ctx context.Context, account common.Address) ([]*types.Transaction, error)
+	}); ok {
+		cli.nonceGapFiller = message.NewNonceGapFiller(filler)
+		go cli.nonceGapFiller.Run(context.Background())
 	}
 
 	go cli.sendMsgTask(context.Background())
 
+	report, err := cli.recoverMsgs(context.Background())
+	if err != nil {
+		cli.CloseSendMsg()
+		return nil, fmt.Errorf("recover msgs err: %v", err)
+	}
+	cli.recoveryReport = report
+
 	return cli, nil
 }
 
@@ -163,16 +229,30 @@ func (c *Client) Close() {
 	// Wait for all messages to be sent
 	time.Sleep(3 * time.Second)
 
+	c.cancelRetries()
+
 	c.Subscriber.Close()
 
 	log.Debug("subscriber closed")
 
+	c.finalityTracker.Close()
+
+	if c.nonceGapFiller != nil {
+		c.nonceGapFiller.Close()
+	}
+
 	c.CloseSendMsg()
 
+	c.endMsgTraces()
+
 	c.Client.Close()
 
 	log.Debug("underlying ethclient closed")
 
+	for _, t := range c.transports {
+		t.Close()
+	}
+
 	log.Info("client closed..")
 }
 
@@ -217,6 +297,14 @@ func (c *Client) SetMaxQueriesPerMerge(n int) {
 	}
 }
 
+// SetGasOracle sets the oracle suggesting the fees of messages sent, see package gas.
+// No-op if the underlying message manager is not a *message.SimpleManager.
+func (c *Client) SetGasOracle(oracle gas.Oracle) {
+	if mm, ok := c.msgManager.(*message.SimpleManager); ok {
+		mm.SetGasOracle(oracle)
+	}
+}
+
 func (c *Client) GetSigner() bind.SignerFn {
 	return c.accRegistry.GetSigner()
 }
@@ -239,11 +327,18 @@ func (c *Client) NewMethodData(a abi.ABI, methodName string, args ...interface{}
 }
 
 func (c *Client) ScheduleMsg(req *message.Request) {
+	c.ScheduleMsgWithContext(context.Background(), req)
+}
+
+// ScheduleMsgWithContext schedules req like ScheduleMsg, tracing the msg as a child of the span in ctx.
+// ctx is not used for cancellation, see CancelMsg.
+func (c *Client) ScheduleMsgWithContext(ctx context.Context, req *message.Request) {
 	log.Info("schedule message", "msgId", req.Id().Hex())
 	if c.reqClosed.Load() {
 		// TODO: return error
 		return
 	}
+	c.startMsgTrace(ctx, *req)
 	c.reqChannel <- *req.Copy()
 }
 
@@ -271,9 +366,95 @@ func (c *Client) Response() <-chan message.Response {
 	return c.respChannel
 }
 
+// Receipt returns the receipts of msgs each time they get on chain, finalized or reorged out.
+// Receipts are dropped if the channel is full, so keep consuming it once used.
+func (c *Client) Receipt() <-chan message.Receipt {
+	return c.receiptChannel
+}
+
+// SetFinalityConfig sets how msgs on chain are considered finalized, see message.FinalityConfig.
+func (c *Client) SetFinalityConfig(config message.FinalityConfig) {
+	c.finalityTracker.SetConfig(config)
+}
+
+// SetRetryPolicy sets how msgs failed to send are retried, see message.RetryPolicy.
+func (c *Client) SetRetryPolicy(policy message.RetryPolicy) {
+	if broadcaster, ok := c.broadcaster.(*message.SimpleBroadcaster); ok {
+		broadcaster.SetRetryPolicy(policy)
+	}
+}
+
+// SetNonceGapInterval sets how often the nonce gaps of the accounts sending msgs are filled
+// with no-op txs, 0 (the default) disables it. See message.NonceGapFiller.
+func (c *Client) SetNonceGapInterval(interval time.Duration) {
+	if c.nonceGapFiller != nil {
+		c.nonceGapFiller.SetInterval(interval)
+	}
+}
+
+func (c *Client) emitReceipt(receipt message.Receipt) {
+	c.handleStatus(receipt.Id, receipt.Status)
+	c.observeReceipt(receipt)
+	c.traceReceipt(receipt)
+
+	c.receiptMu.Lock()
+	defer c.receiptMu.Unlock()
+
+	if c.receiptClosed {
+		return
+	}
+
+	select {
+	case c.receiptChannel <- receipt:
+	default:
+		log.Warn("receipt channel is full, drop the receipt", "msgId", receipt.Id.Hex(), "status", receipt.Status)
+	}
+}
+
+// handleStatus reports msgId entering status to the metrics and the subscribers of msg events.
+func (c *Client) handleStatus(msgId common.Hash, status message.MessageStatus) {
+	c.observeStatus(msgId, status)
+	c.publishStatus(msgId, status)
+}
+
+// handleStoredStatus reports the status of msgId after steps updating it on their own, e.g. sending.
+func (c *Client) handleStoredStatus(msgId common.Hash) {
+	if c.metrics.Load() == nil && !c.events.HasSubscribers() {
+		return
+	}
+
+	msg, err := c.msgStore.GetMsg(msgId)
+	if err != nil {
+		return
+	}
+	c.handleStatus(msgId, msg.Status)
+}
+
+func (c *Client) handleReplacement(msgId common.Hash, resp message.Response) {
+	c.observeReplacement(msgId, resp)
+	c.traceTx(msgId, resp.Tx)
+	c.publishReplaced(msgId, resp)
+}
+
+// failMsg reports msgId failed with err, no more steps following.
+func (c *Client) failMsg(msgId common.Hash, err error) {
+	c.endMsgTrace(msgId, err)
+	c.publishFailed(msgId, err)
+}
+
+func (c *Client) closeReceipt() {
+	c.receiptMu.Lock()
+	defer c.receiptMu.Unlock()
+
+	if !c.receiptClosed {
+		c.receiptClosed = true
+		close(c.receiptChannel)
+	}
+}
+
 func (c *Client) CallMsg(ctx context.Context, msg message.Request, blockNumber *big.Int) (returnData []byte, err error) {
 	resp := c.msgManager.CallMsg(ctx, msg, blockNumber)
-	return resp.ReturnData, resp.Err
+	return resp.ReturnData, c.abiRegistry.DecodeError(ctx, msg.To, resp.Err)
 }
 
 func (c *Client) sendMsgTask(ctx context.Context) {
@@ -297,6 +478,7 @@ func (c *Client) schedule() {
 				if err != nil {
 					log.Debug("Client.schedule UpdateResponse", "resp", resp)
 
+					c.failMsg(resp.Id, err)
 					resp.Err = err
 					c.msgStore.UpdateResponse(resp.Id, resp)
 					c.respChannel <- resp
@@ -307,6 +489,10 @@ func (c *Client) schedule() {
 				panic(fmt.Errorf("no msgId provided"))
 			}
 
+			// Msgs recovered or replayed are traced from here.
+			c.startMsgTrace(context.Background(), req)
+			c.startMsgStage(req.Id(), "ethclient.schedule")
+
 			if !c.msgStore.HasMsg(req.Id()) {
 				// message.MessageStatusSubmitted
 				err = c.msgStore.AddMsg(req)
@@ -314,6 +500,7 @@ func (c *Client) schedule() {
 					err = fmt.Errorf("no msgId provided: %v", err)
 					return
 				}
+				c.handleStatus(req.Id(), message.MessageStatusSubmitted)
 			}
 
 			msg, err := c.msgStore.GetMsg(req.Id())
@@ -321,6 +508,11 @@ func (c *Client) schedule() {
 				return
 			}
 
+			if c.isMsgCancelled(msg.Id()) {
+				err = c.dropCancelledMsg(msg.Id())
+				return
+			}
+
 			now := time.Now().UnixNano()
 
 			if msg.Req.Interval != 0 {
@@ -339,6 +531,7 @@ func (c *Client) schedule() {
 				if err != nil {
 					return
 				}
+				c.handleStatus(req.Id(), message.MessageStatusExpired)
 
 				err = fmt.Errorf("timeout")
 			}
@@ -366,6 +559,7 @@ func (c *Client) schedule() {
 				err = fmt.Errorf("no msgId provided")
 				return
 			}
+			c.handleStatus(req.Id(), message.MessageStatusScheduled)
 
 			if msg.Req.Interval != 0 {
 				newReq := req.CopyWithoutId()
@@ -380,6 +574,7 @@ func (c *Client) schedule() {
 				if err != nil {
 					return
 				}
+				c.handleStatus(newReq.Id(), message.MessageStatusSubmitted)
 
 				newMsg, err := c.msgStore.GetMsg(newReq.Id())
 				if err != nil {
@@ -401,6 +596,7 @@ func (c *Client) schedule() {
 				}
 
 				if !c.reqClosed.Load() {
+					c.startMsgTrace(context.Background(), *newReq, c.msgLink(req.Id()))
 					c.reqChannel <- *newReq
 				} else {
 					log.Warn("ethclient closed, then drop the request", "msg", msg.Id().Hex())
@@ -424,11 +620,20 @@ func (c *Client) sequence() {
 				if err != nil {
 					log.Debug("Client.sequence UpdateResponse", "resp", resp)
 
+					c.failMsg(resp.Id, err)
 					resp.Err = err
 					c.msgStore.UpdateResponse(resp.Id, resp)
 					c.respChannel <- resp
 				}
 			}()
+			if c.isMsgCancelled(msg.Id()) {
+				err = c.dropCancelledMsg(msg.Id())
+				return
+			}
+
+			// Until the msgs it comes after are sent.
+			c.startMsgStage(msg.Id(), "ethclient.sequence")
+
 			err = c.msgSequencer.PushMsg(msg)
 			if err != nil {
 				return
@@ -438,6 +643,7 @@ func (c *Client) sequence() {
 			if err != nil {
 				return
 			}
+			c.handleStatus(msg.Id(), message.MessageStatusQueued)
 		}()
 	}
 
@@ -454,34 +660,77 @@ func (c *Client) broadcast(ctx context.Context) {
 			msg, err := c.msgSequencer.PopMsg()
 			if err != nil {
 				if errors.Is(err, message.ErrPendingChannelClosed) {
+					c.retries.Wait()
+
 					log.Debug("close responseChannel...")
 					close(c.respChannel)
-					close(c.receiptChannel)
+					c.closeReceipt()
 					return true
 				}
 				log.Error("unexpected broadcast case", "err", err)
 				return true
 			}
 
+			c.endMsgStage(msg.Id())
+			ctx := c.msgContext(ctx, msg.Id())
+
 			var resp message.Response
 			resp.Id = msg.Id()
+			retrying, responded := false, false
 			defer func() {
-				log.Debug("Client.broadcast UpdateResponse", "resp", resp, "msgId", msg.Id())
+				if retrying {
+					return
+				}
+
+				if !responded {
+					log.Debug("Client.broadcast UpdateResponse", "resp", resp, "msgId", msg.Id())
 
-				c.msgStore.UpdateResponse(resp.Id, resp)
+					if resp.Err != nil {
+						c.failMsg(resp.Id, resp.Err)
+					}
+
+					c.msgStore.UpdateResponse(resp.Id, resp)
+					c.handleStoredStatus(msg.Id())
+				}
+				c.ackMsg(msg.Id())
 				c.respChannel <- resp
 			}()
 
+			if stored, err := c.msgStore.GetMsg(msg.Id()); err == nil && stored.Resp != nil {
+				// Redelivered by the sequencer after its response was stored, e.g. by a process crashed before acking it.
+				log.Info("msg already responded", "msgId", msg.Id().Hex())
+				resp, responded = *stored.Resp, true
+				return
+			}
+
+			if c.isMsgCancelled(msg.Id()) {
+				resp.Err = c.dropCancelledMsg(msg.Id())
+				return
+			}
+
 			if msg.SimulationOn {
 				resp = c.msgManager.CallMsg(ctx, msg, nil)
+				resp.Err = c.abiRegistry.DecodeError(ctx, msg.To, resp.Err)
 			}
 
 			if resp.Err == nil {
+				if c.nonceGapFiller != nil {
+					c.nonceGapFiller.Track(msg.From)
+				}
+
 				sendResp := c.broadcaster.SendMsg(ctx, msg)
 				log.Debug("broadcaster.SendMsg resp", "resp", sendResp)
 				resp.Id = sendResp.Id
 				resp.Err = sendResp.Err
 				resp.Tx = sendResp.Tx
+				c.traceTx(msg.Id(), resp.Tx)
+
+				if retrier, ok := c.broadcaster.(msgRetrier); ok && retrier.ShouldRetry(resp.Err) {
+					// Retried in the background, so that backoffs don't hold the msgs behind.
+					retrying = true
+					c.retries.Add(1)
+					go c.retryMsg(retrier, msg, resp)
+				}
 			}
 
 			return
@@ -493,14 +742,70 @@ func (c *Client) broadcast(ctx context.Context) {
 	}
 }
 
+type msgRetrier interface {
+	ShouldRetry(err error) bool
+	RetryMsg(ctx context.Context, msg message.Request, resp message.Response) message.Response
+}
+
+func (c *Client) retryMsg(retrier msgRetrier, msg message.Request, resp message.Response) {
+	defer c.retries.Done()
+
+	resp = retrier.RetryMsg(c.msgContext(c.retryCtx, msg.Id()), msg, resp)
+	c.traceTx(msg.Id(), resp.Tx)
+	if resp.Err != nil {
+		c.failMsg(msg.Id(), resp.Err)
+	}
+
+	log.Debug("Client.retryMsg UpdateResponse", "resp", resp, "msgId", msg.Id())
+
+	c.msgStore.UpdateResponse(resp.Id, resp)
+	c.handleStoredStatus(msg.Id())
+	c.ackMsg(msg.Id())
+	c.respChannel <- resp
+}
+
+// ackMsg acks msgId popped from sequencers redelivering msgs unless acked, once its response is stored.
+func (c *Client) ackMsg(msgId common.Hash) {
+	acker, ok := c.msgSequencer.(message.SequencerAcker)
+	if !ok {
+		return
+	}
+
+	if err := acker.AckMsg(msgId); err != nil {
+		log.Warn("failed to ack msg", "msgId", msgId.Hex(), "err", err)
+	}
+}
+
 func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
 	return c.nonceManager.PendingNonceAt(ctx, account)
 }
 
 func (c *Client) SuggestGasPrice(ctx context.Context) (gasPrice *big.Int, err error) {
+	if mm, ok := c.msgManager.(*message.SimpleManager); ok {
+		fees, err := mm.SuggestFees(ctx, gas.UrgencyNormal)
+		if err != nil {
+			return nil, err
+		}
+		return fees.GasPrice, nil
+	}
+
 	return c.nonceManager.SuggestGasPrice(ctx)
 }
 
+func (c *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	if mm, ok := c.msgManager.(*message.SimpleManager); ok {
+		fees, err := mm.SuggestFees(ctx, gas.UrgencyNormal)
+		if err != nil {
+			return nil, err
+		}
+		if fees.GasTipCap != nil {
+			return fees.GasTipCap, nil
+		}
+	}
+
+	return c.Client.SuggestGasTipCap(ctx)
+}
+
 func (c *Client) WaitTxReceipt(txHash common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool) {
 	return c.msgManager.WaitTxReceipt(txHash, confirmations, timeout)
 }
@@ -534,16 +839,18 @@ func (c *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
 	gas, err := c.nonceManager.EstimateGas(ctx, msg)
 	if err != nil {
-		return 0, c.DecodeJsonRpcError(err)
+		return 0, c.abiRegistry.DecodeError(ctx, msg.To, err)
 	}
 
 	return gas, nil
 }
 
+// CallContract calls msg, which is coalesced with concurrent calls into one multicall
+// if enabled by SetMulticallConfig.
 func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
-	ret, err := c.Client.CallContract(ctx, msg, blockNumber)
+	ret, err := c.multicaller.CallContract(ctx, msg, blockNumber)
 	if err != nil {
-		return nil, c.DecodeJsonRpcError(err)
+		return nil, c.abiRegistry.DecodeError(ctx, msg.To, err)
 	}
 
 	return ret, nil
@@ -552,7 +859,7 @@ func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNu
 func (c *Client) CallContractWithAccountOverride(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int, overrides *map[common.Address]gethclient.OverrideAccount) ([]byte, error) {
 	ret, err := c.gethClient.CallContract(ctx, msg, blockNumber, overrides)
 	if err != nil {
-		return nil, c.DecodeJsonRpcError(err)
+		return nil, c.abiRegistry.DecodeError(ctx, msg.To, err)
 	}
 
 	return ret, nil
@@ -561,7 +868,7 @@ func (c *Client) CallContractWithAccountOverride(ctx context.Context, msg ethere
 func (c *Client) CallContractAtHash(ctx context.Context, msg ethereum.CallMsg, blockHash common.Hash) ([]byte, error) {
 	ret, err := c.Client.CallContractAtHash(ctx, msg, blockHash)
 	if err != nil {
-		return nil, c.DecodeJsonRpcError(err)
+		return nil, c.abiRegistry.DecodeError(ctx, msg.To, err)
 	}
 
 	return ret, nil
@@ -570,12 +877,24 @@ func (c *Client) CallContractAtHash(ctx context.Context, msg ethereum.CallMsg, b
 func (c *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
 	ret, err := c.Client.PendingCallContract(ctx, msg)
 	if err != nil {
-		return nil, c.DecodeJsonRpcError(err)
+		return nil, c.abiRegistry.DecodeError(ctx, msg.To, err)
 	}
 
 	return ret, nil
 }
 
+// Multicall calls calls by aggregate3 of Multicall3 at blockNumber, split into multicalls by calldata size and gas.
+// Outputs and reverts of calls are decoded with the ABIs registered.
+func (c *Client) Multicall(ctx context.Context, calls []multicall.Call, blockNumber *big.Int) ([]multicall.Result, error) {
+	return c.multicaller.Aggregate3(ctx, calls, blockNumber)
+}
+
+// SetMulticallConfig sets the address of Multicall3 and how calls are split,
+// and enables coalescing concurrent CallContract calls if config.Window is not 0.
+func (c *Client) SetMulticallConfig(config multicall.Config) {
+	c.multicaller.SetConfig(config)
+}
+
 func (c *Client) HasMsg(msgId common.Hash) bool {
 	return c.msgStore.HasMsg(msgId)
 }
@@ -588,66 +907,58 @@ func (c *Client) GetNonce(msgId common.Hash) (uint64, error) {
 	return c.msgStore.GetNonce(msgId)
 }
 
-func (c *Client) DebugTransactionOnChain(ctx context.Context, txHash common.Hash) ([]byte, error) {
-	receipt, confirmed := c.WaitTxReceipt(txHash, 3, 30*time.Second)
-	if !confirmed {
-		return nil, fmt.Errorf("tx receipt not found")
-	}
-
-	tx, _, err := c.TransactionByHash(ctx, txHash)
-	if err != nil {
-		return nil, err
-	}
-
-	from, err := c.TransactionSender(ctx, tx, receipt.BlockHash, receipt.TransactionIndex)
+// GetMsgHistory returns the changes of the msg in order: its statuses, the txs sent for it and the errors it failed with.
+func (c *Client) GetMsgHistory(msgId common.Hash) ([]message.HistoryRecord, error) {
+	msg, err := c.msgStore.GetMsg(msgId)
 	if err != nil {
 		return nil, err
 	}
+	return msg.History, nil
+}
 
-	msg := ethereum.CallMsg{
-		From:          from,
-		To:            tx.To(),
-		Value:         tx.Value(),
-		Data:          tx.Data(),
-		Gas:           tx.Gas(),
-		GasPrice:      tx.GasPrice(),
-		AccessList:    tx.AccessList(),
-		BlobGasFeeCap: tx.BlobGasFeeCap(),
-		BlobHashes:    tx.BlobHashes(),
+// QueryMsgs returns the msgs matching query, e.g. the ones of an account inflight for long.
+// The msg storage needs to implement message.StorageQuerier.
+func (c *Client) QueryMsgs(query message.MsgQuery) ([]message.Message, error) {
+	querier, ok := c.msgStore.(message.StorageQuerier)
+	if !ok {
+		return nil, errors.New("msg storage does not support queries")
 	}
-	ret, err := c.CallContractAtHash(ctx, msg, receipt.BlockHash)
-
-	return ret, err
+	return querier.QueryMsgs(query)
 }
 
-// Trying to decode some data using the abi if specific
+// AddABI adds intf to the ABIs used for decoding data of any contract.
+// Use RegisterABI instead if contracts define errors or events with the same selectors or names.
 func (c *Client) AddABI(intf abi.ABI) {
-	if c.abi.Errors == nil {
-		c.abi.Errors = make(map[string]abi.Error)
-	}
+	c.abiRegistry.AddABI(intf)
+}
 
-	if c.abi.Methods == nil {
-		c.abi.Methods = make(map[string]abi.Method)
-	}
+// RegisterABI binds intf to the contract at addr, which takes precedence over the ABIs added by AddABI.
+// Data of EIP-1967 proxies are decoded with the ABI registered for their implementation as well.
+func (c *Client) RegisterABI(addr common.Address, intf abi.ABI) {
+	c.abiRegistry.Register(addr, intf)
+}
 
-	if c.abi.Events == nil {
-		c.abi.Events = make(map[string]abi.Event)
-	}
+func (c *Client) GetABIRegistry() *abiregistry.Registry {
+	return c.abiRegistry
+}
 
-	// errors
-	for k, v := range intf.Errors {
-		c.abi.Errors[k] = v
-	}
+// DecodeJsonRpcError decodes err using the ABIs added by AddABI only.
+// Use DecodeContractError to decode errors of calls to a contract.
+func (c *Client) DecodeJsonRpcError(err error) error {
+	return c.abiRegistry.DecodeError(context.Background(), nil, err)
+}
 
-	for k, v := range intf.Methods {
-		c.abi.Methods[k] = v
-	}
+// DecodeContractError decodes err of calling or sending txs to the contract at to.
+func (c *Client) DecodeContractError(ctx context.Context, to *common.Address, err error) error {
+	return c.abiRegistry.DecodeError(ctx, to, err)
+}
 
-	for k, v := range intf.Events {
-		c.abi.Events[k] = v
-	}
+// DecodeCalldata decodes data of a call to the contract at to.
+func (c *Client) DecodeCalldata(ctx context.Context, to *common.Address, data []byte) (*abiregistry.Call, error) {
+	return c.abiRegistry.DecodeCalldata(ctx, to, data)
 }
 
-func (c *Client) DecodeJsonRpcError(err error) error {
-	return consts.DecodeJsonRpcError(err, c.abi)
+// DecodeLog decodes l against the ABI of the contract emitting it.
+func (c *Client) DecodeLog(ctx context.Context, l types.Log) (*abiregistry.Event, error) {
+	return c.abiRegistry.DecodeLog(ctx, l)
 }
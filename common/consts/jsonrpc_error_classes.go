@@ -0,0 +1,203 @@
+package consts
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Classes of json rpc errors, which are reported with different messages by different nodes and providers.
+// Use errors.Is on errors decoded by DecodeJsonRpcError, or ClassifyError on any other error.
+var (
+	ErrNonceTooLow            = errors.New("nonce too low")
+	ErrNonceTooHigh           = errors.New("nonce too high")
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+	ErrFeeTooLow              = errors.New("transaction fee too low")
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+	ErrAlreadyKnown           = errors.New("already known")
+	ErrExecutionReverted      = errors.New("execution reverted")
+	ErrIntrinsicGasTooLow     = errors.New("intrinsic gas too low")
+	ErrGasLimitExceeded       = errors.New("exceeds block gas limit")
+	ErrRateLimited            = errors.New("rate limited")
+)
+
+type errorClass struct {
+	class error
+	codes []JsonRpcErrorCode
+	// lower case substrings of messages
+	patterns []string
+}
+
+// errorClasses are matched in order, e.g. replacements before other underpriced txs.
+// Reverts go first, since revert reasons are free-form.
+var errorClasses = []errorClass{
+	{
+		class: ErrExecutionReverted,
+		codes: []JsonRpcErrorCode{3},
+		patterns: []string{
+			"execution reverted", // geth, erigon, anvil, besu
+			"vm execution error", // nethermind
+			"reverted",           // nethermind, gateways
+			"revert",             // ganache, hardhat
+			"invalid opcode",     // geth
+			"evm error",          // anvil
+		},
+	},
+	{
+		class: ErrNonceTooLow,
+		patterns: []string{
+			"nonce too low", // geth, erigon, anvil, nethermind
+			"oldnonce",      // nethermind
+			"nonce_too_low", // besu
+			"nonce has already been used",
+			"invalid transaction nonce", // gateways
+		},
+	},
+	{
+		class: ErrNonceTooHigh,
+		patterns: []string{
+			"nonce too high",                     // geth, anvil
+			"noncegap",                           // nethermind
+			"nonce too far",                      // besu
+			"nonce_too_far_in_future_for_sender", // besu
+			"nonce_too_high",
+		},
+	},
+	{
+		class: ErrReplacementUnderpriced,
+		patterns: []string{
+			"replacement transaction underpriced", // geth, erigon, anvil, besu
+			"replacement_underpriced",             // besu
+			"replacementnotallowed",               // nethermind
+			"feetoolowtocompete",                  // nethermind
+			"could not replace existing tx",       // erigon
+		},
+	},
+	{
+		class: ErrFeeTooLow,
+		patterns: []string{
+			"transaction underpriced",                  // geth
+			"fee cap less than block base fee",         // geth
+			"max fee per gas less than block base fee", // geth, anvil
+			"feetoolow",                          // nethermind
+			"minerpremiumnegative",               // nethermind
+			"gas price below configured minimum", // besu
+			"gas_price_too_low",                  // besu
+			"gas price too low",                  // gateways
+			"maxfeepergas too low",               // gateways
+		},
+	},
+	{
+		class: ErrInsufficientFunds,
+		patterns: []string{
+			"insufficient funds",                   // geth, erigon, anvil
+			"insufficientfunds",                    // nethermind
+			"insufficient sender balance",          // nethermind
+			"upfront cost exceeds account balance", // besu
+			"upfront_cost_exceeds_balance",         // besu
+			"insufficient balance",                 // gateways
+		},
+	},
+	{
+		class: ErrAlreadyKnown,
+		patterns: []string{
+			"already known",                // geth, erigon, nethermind
+			"alreadyknown",                 // nethermind
+			"known transaction",            // geth before 1.9.20, besu
+			"transaction_already_known",    // besu
+			"already_exists",               // erigon
+			"transaction already imported", // anvil
+			"already in mempool",           // gateways
+		},
+	},
+	{
+		class: ErrIntrinsicGasTooLow,
+		patterns: []string{
+			"intrinsic gas too low",           // geth, erigon, anvil
+			"intrinsicgastoolow",              // nethermind
+			"intrinsic gas exceeds gas limit", // besu
+			"intrinsic_gas_exceeds_gas_limit", // besu
+		},
+	},
+	{
+		class: ErrGasLimitExceeded,
+		patterns: []string{
+			"exceeds block gas limit", // geth, erigon, anvil
+			"gaslimitexceeded",        // nethermind
+			"exceeds_block_gas_limit", // besu
+			"gas limit reached",       // geth txpool
+		},
+	},
+	{
+		class: ErrRateLimited,
+		codes: []JsonRpcErrorCode{JsonRpcErrorCodeLimitExceeded, 429},
+		patterns: []string{
+			"too many requests",
+			"rate limit",
+			"limit exceeded",
+			"quota",
+			"capacity", // quicknode
+			"throttl",
+			"compute units",       // alchemy
+			"request limit",       // infura
+			"daily request count", // infura
+		},
+	},
+}
+
+// ClassifyError returns the class of err, e.g. ErrNonceTooLow, or nil if it's not classified.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var jsonErr *JsonRpcError
+	if errors.As(err, &jsonErr) {
+		return jsonErr.class()
+	}
+
+	var code *JsonRpcErrorCode
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		c := JsonRpcErrorCode(rpcErr.ErrorCode())
+		code = &c
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		c := JsonRpcErrorCode(httpErr.StatusCode)
+		code = &c
+	}
+
+	return classifyError(code, err.Error())
+}
+
+// ClassifyJsonRpcError returns the class of a json rpc error with code and msg, or nil if it's not classified.
+func ClassifyJsonRpcError(code JsonRpcErrorCode, msg string) error {
+	return classifyError(&code, msg)
+}
+
+func classifyError(code *JsonRpcErrorCode, msg string) error {
+	msg = strings.ToLower(msg)
+
+	for _, ec := range errorClasses {
+		for _, pattern := range ec.patterns {
+			if strings.Contains(msg, pattern) {
+				return ec.class
+			}
+		}
+
+		if code == nil {
+			continue
+		}
+
+		for _, c := range ec.codes {
+			if c == *code {
+				return ec.class
+			}
+		}
+	}
+
+	return nil
+}
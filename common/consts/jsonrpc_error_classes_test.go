@@ -0,0 +1,77 @@
+package consts
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+type testRpcError struct {
+	code int
+	msg  string
+}
+
+func (e testRpcError) Error() string  { return e.msg }
+func (e testRpcError) ErrorCode() int { return e.code }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err   error
+		class error
+	}{
+		// geth
+		{testRpcError{-32000, "nonce too low: address 0x70997970C51812dc3A010C7d01b50e0d17dc79C8, tx: 0 state: 1"}, ErrNonceTooLow},
+		{testRpcError{-32000, "replacement transaction underpriced"}, ErrReplacementUnderpriced},
+		{testRpcError{-32000, "transaction underpriced: tip needed 1, tip permitted 0"}, ErrFeeTooLow},
+		{testRpcError{-32000, "insufficient funds for gas * price + value: balance 0, tx cost 1, overshot 1"}, ErrInsufficientFunds},
+		{testRpcError{-32000, "already known"}, ErrAlreadyKnown},
+		{testRpcError{3, "execution reverted: not owner"}, ErrExecutionReverted},
+		{testRpcError{-32000, "intrinsic gas too low: gas 0, minimum needed 21000"}, ErrIntrinsicGasTooLow},
+		{testRpcError{-32000, "exceeds block gas limit"}, ErrGasLimitExceeded},
+		// nethermind
+		{testRpcError{-32010, "OldNonce, Current nonce: 1, nonce of rejected tx: 0"}, ErrNonceTooLow},
+		{testRpcError{-32010, "InsufficientFunds, Account balance: 0, cumulative cost: 1"}, ErrInsufficientFunds},
+		{testRpcError{-32010, "AlreadyKnown"}, ErrAlreadyKnown},
+		{testRpcError{-32015, "VM execution error."}, ErrExecutionReverted},
+		// besu
+		{testRpcError{-32000, "Nonce too low"}, ErrNonceTooLow},
+		{testRpcError{-32000, "Replacement transaction underpriced"}, ErrReplacementUnderpriced},
+		{testRpcError{-32000, "Upfront cost exceeds account balance"}, ErrInsufficientFunds},
+		{testRpcError{-32000, "Known transaction"}, ErrAlreadyKnown},
+		// anvil
+		{testRpcError{-32003, "Insufficient funds for gas * price + value"}, ErrInsufficientFunds},
+		{testRpcError{-32003, "transaction already imported"}, ErrAlreadyKnown},
+		// gateways
+		{testRpcError{-32005, "daily request count exceeded, request rate limited"}, ErrRateLimited},
+		{testRpcError{429, "Your app has exceeded its compute units per second capacity"}, ErrRateLimited},
+		// not classified
+		{testRpcError{-32000, "invalid sender"}, nil},
+		{errors.New("connection refused"), nil},
+	}
+
+	for _, tt := range tests {
+		if class := ClassifyError(tt.err); class != tt.class {
+			t.Errorf("ClassifyError(%q): want %v, got %v", tt.err, tt.class, class)
+		}
+
+		if class := ClassifyError(fmt.Errorf("wrapped: %w", tt.err)); class != tt.class {
+			t.Errorf("ClassifyError(wrapped %q): want %v, got %v", tt.err, tt.class, class)
+		}
+
+		decoded := DecodeJsonRpcError(tt.err, abi.ABI{})
+		if tt.class != nil && !errors.Is(decoded, tt.class) {
+			t.Errorf("errors.Is(%v, %v) is false", decoded, tt.class)
+		}
+
+		var rpcErr testRpcError
+		if _, ok := tt.err.(testRpcError); ok && !errors.As(decoded, &rpcErr) {
+			t.Errorf("%v does not unwrap to the error decoded", decoded)
+		}
+	}
+
+	if errors.Is(DecodeJsonRpcError(testRpcError{-32000, "nonce too low"}, abi.ABI{}), ErrAlreadyKnown) {
+		t.Error("nonce too low is not already known")
+	}
+}
@@ -45,6 +45,9 @@ type JsonRpcError struct {
 	RevertReason string `json:"revert_reason,omitempty"`
 	// IsRevertError indicates whether this error is a revert error (Error(string) format)
 	IsRevertError bool `json:"is_revert_error,omitempty"`
+
+	// the error decoded, which is lost once stored
+	err error
 }
 
 func (e *JsonRpcError) Error() string {
@@ -106,6 +109,25 @@ func (err *JsonRpcError) ErrorData() interface{} {
 	return err.Data
 }
 
+// Is reports whether the error belongs to target class, e.g. ErrNonceTooLow.
+func (e *JsonRpcError) Is(target error) bool {
+	class := e.class()
+	return class != nil && class == target
+}
+
+func (e *JsonRpcError) Unwrap() error {
+	return e.err
+}
+
+func (e *JsonRpcError) class() error {
+	if e.IsRevertError {
+		return ErrExecutionReverted
+	}
+
+	code := e.Code
+	return classifyError(&code, e.Message+"\n"+e.RawError)
+}
+
 // GetRevertReason returns the raw revert reason string extracted from Error(string) format.
 // This can be used to extract binary data (e.g., address bytes) from revert errors.
 func (err *JsonRpcError) GetRevertReason() string {
@@ -123,6 +145,7 @@ func DecodeJsonRpcError(err error, evmABI abi.ABI) error {
 		// default error for internal errors from client side
 		Code:    JsonRpcErrorCodeInternalError,
 		Message: err.Error(),
+		err:     err,
 	}
 
 	ec, ok := err.(rpc.Error)
@@ -0,0 +1,390 @@
+package ethclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/abiregistry"
+)
+
+// ErrReplayUnavailable is returned by ReplayTransaction if the txs before the one replayed in its block can't be
+// replayed first, i.e. the node supports neither debug_traceTransaction nor eth_callMany.
+var ErrReplayUnavailable = errors.New("replay unavailable: eth_callMany is not supported and the tx is not the first of its block")
+
+// CallFrame is a call made by a tx, with its inner calls.
+type CallFrame struct {
+	Type    string // e.g. CALL, DELEGATECALL, CREATE
+	From    common.Address
+	To      *common.Address
+	Value   *big.Int
+	Gas     uint64
+	GasUsed uint64
+	Input   []byte
+	Output  []byte
+	// Error is set if the call failed, e.g. "execution reverted"
+	Error        string
+	RevertReason string
+	// Logs emitted by the call itself, not by its inner calls.
+	// Logs of calls reverted are dropped.
+	Logs  []types.Log
+	Calls []*CallFrame
+
+	// Call is Input decoded with the ABI of To, nil if not decoded.
+	Call *abiregistry.Call
+	// Events are Logs decoded, with nil for logs not decoded.
+	Events []*abiregistry.Event
+	// DecodedError is the error of the call decoded with the ABI of To, nil if the call succeeded.
+	DecodedError error
+}
+
+// TxDebugResult is the result of debugging a tx on chain.
+type TxDebugResult struct {
+	TxHash  common.Hash
+	Receipt *types.Receipt
+	// Root is the call tree of the tx. Replayed txs have the root call only.
+	Root *CallFrame
+	// Revert is the innermost call reverting the tx, nil if the tx succeeded.
+	Revert *CallFrame
+	// Traced is true if the call tree comes from debug_traceTransaction, false if the tx was replayed.
+	Traced bool
+}
+
+// Err returns the decoded error of the call reverting the tx, nil if the tx succeeded.
+func (r *TxDebugResult) Err() error {
+	if r.Revert == nil {
+		return nil
+	}
+
+	return r.Revert.DecodedError
+}
+
+// DebugTransactionOnChain returns the call tree of a tx mined, traced by debug_traceTransaction with callTracer,
+// or the tx replayed by ReplayTransaction if the node doesn't support tracing it.
+// Inputs, logs and errors are decoded with the ABIs registered.
+func (c *Client) DebugTransactionOnChain(ctx context.Context, txHash common.Hash) (*TxDebugResult, error) {
+	receipt, err := c.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("get tx receipt err: %v", err)
+	}
+
+	root, err := c.traceTransaction(ctx, txHash)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		log.Debug("trace tx failed, then replay it", "tx", txHash.Hex(), "err", err)
+		return c.replayTransaction(ctx, txHash, receipt)
+	}
+
+	c.decodeCallFrame(ctx, root)
+
+	return &TxDebugResult{
+		TxHash:  txHash,
+		Receipt: receipt,
+		Root:    root,
+		Revert:  innermostRevert(root),
+		Traced:  true,
+	}, nil
+}
+
+// ReplayTransaction replays a tx mined on top of the state before it, without tracing it.
+// The txs before it in its block are replayed first by eth_callMany if the node supports it (e.g. erigon, reth).
+// Otherwise only the first tx of a block is replayed, on top of the parent block with the context of its own block,
+// and ErrReplayUnavailable is returned for the others.
+func (c *Client) ReplayTransaction(ctx context.Context, txHash common.Hash) (*TxDebugResult, error) {
+	receipt, err := c.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("get tx receipt err: %v", err)
+	}
+
+	return c.replayTransaction(ctx, txHash, receipt)
+}
+
+func (c *Client) replayTransaction(ctx context.Context, txHash common.Hash, receipt *types.Receipt) (*TxDebugResult, error) {
+	tx, _, err := c.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("get tx err: %v", err)
+	}
+
+	from, err := c.TransactionSender(ctx, tx, receipt.BlockHash, receipt.TransactionIndex)
+	if err != nil {
+		return nil, fmt.Errorf("get tx sender err: %v", err)
+	}
+
+	args := toCallArgs(from, tx)
+
+	ret, callErr, err := c.callManyAt(ctx, args, receipt)
+	if err != nil {
+		if receipt.TransactionIndex > 0 {
+			log.Debug("eth_callMany failed", "tx", txHash.Hex(), "err", err)
+			return nil, ErrReplayUnavailable
+		}
+
+		log.Debug("eth_callMany failed, then replay tx on top of the parent block", "tx", txHash.Hex(), "err", err)
+
+		header, err := c.HeaderByHash(ctx, receipt.BlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("get block header err: %v", err)
+		}
+
+		var raw hexutil.Bytes
+		callErr = c.rpcClient.CallContext(ctx, &raw, "eth_call", args, header.ParentHash, struct{}{}, toBlockOverrides(header))
+		if _, ok := callErr.(rpc.Error); callErr != nil && !ok {
+			return nil, fmt.Errorf("replay tx err: %v", callErr)
+		}
+		ret = raw
+	}
+
+	root := &CallFrame{
+		Type:    "CALL",
+		From:    from,
+		To:      tx.To(),
+		Value:   tx.Value(),
+		Gas:     tx.Gas(),
+		GasUsed: receipt.GasUsed,
+		Input:   tx.Data(),
+		Output:  ret,
+	}
+	if tx.To() == nil {
+		root.Type = "CREATE"
+	}
+
+	if callErr != nil {
+		root.Error = callErr.Error()
+		root.DecodedError = c.abiRegistry.DecodeError(ctx, tx.To(), callErr)
+	} else {
+		for _, l := range receipt.Logs {
+			root.Logs = append(root.Logs, *l)
+		}
+	}
+
+	c.decodeCallFrame(ctx, root)
+
+	result := &TxDebugResult{
+		TxHash:  txHash,
+		Receipt: receipt,
+		Root:    root,
+	}
+	if callErr != nil {
+		result.Revert = root
+	}
+
+	return result, nil
+}
+
+func (c *Client) traceTransaction(ctx context.Context, txHash common.Hash) (*CallFrame, error) {
+	var frame callFrameJSON
+	config := map[string]interface{}{
+		"tracer":       "callTracer",
+		"tracerConfig": map[string]interface{}{"withLog": true},
+	}
+	if err := c.rpcClient.CallContext(ctx, &frame, "debug_traceTransaction", txHash, config); err != nil {
+		return nil, err
+	}
+
+	return frame.toCallFrame(), nil
+}
+
+// callManyAt calls args on top of the state before the tx of receipt, returning err if eth_callMany is not supported.
+func (c *Client) callManyAt(ctx context.Context, args map[string]interface{}, receipt *types.Receipt) (ret []byte, callErr error, err error) {
+	bundles := []map[string]interface{}{{"transactions": []interface{}{args}}}
+	stateContext := map[string]interface{}{
+		"blockNumber":      hexutil.EncodeBig(receipt.BlockNumber),
+		"transactionIndex": receipt.TransactionIndex,
+	}
+
+	var results [][]struct {
+		Value hexutil.Bytes   `json:"value"`
+		Error json.RawMessage `json:"error"`
+	}
+	if err := c.rpcClient.CallContext(ctx, &results, "eth_callMany", bundles, stateContext); err != nil {
+		return nil, nil, err
+	}
+
+	if len(results) != 1 || len(results[0]) != 1 {
+		return nil, nil, fmt.Errorf("unexpected eth_callMany results: %v", results)
+	}
+
+	result := results[0][0]
+	if len(result.Error) == 0 || string(result.Error) == "null" {
+		return result.Value, nil, nil
+	}
+
+	return result.Value, newCallError(result.Error, result.Value), nil
+}
+
+// callError is an error of eth_callMany, which is decoded as json rpc errors.
+type callError struct {
+	msg  string
+	data string
+}
+
+func newCallError(raw json.RawMessage, ret []byte) callError {
+	var e struct {
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &e.Message); err != nil {
+		_ = json.Unmarshal(raw, &e)
+	}
+
+	if e.Data == "" && len(ret) > 0 {
+		e.Data = hexutil.Encode(ret)
+	}
+
+	return callError{msg: e.Message, data: e.Data}
+}
+
+func (e callError) Error() string  { return e.msg }
+func (e callError) ErrorCode() int { return 3 }
+func (e callError) ErrorData() interface{} {
+	if e.data == "" {
+		return nil
+	}
+	return e.data
+}
+
+// decodeCallFrame decodes inputs, logs and errors of frame and its inner calls.
+func (c *Client) decodeCallFrame(ctx context.Context, frame *CallFrame) {
+	if frame.To != nil && len(frame.Input) >= 4 {
+		frame.Call, _ = c.abiRegistry.DecodeCalldata(ctx, frame.To, frame.Input)
+	}
+
+	frame.Events = make([]*abiregistry.Event, len(frame.Logs))
+	for i, l := range frame.Logs {
+		frame.Events[i], _ = c.abiRegistry.DecodeLog(ctx, l)
+	}
+
+	if frame.Error != "" && frame.DecodedError == nil {
+		data := ""
+		if len(frame.Output) > 0 {
+			data = hexutil.Encode(frame.Output)
+		}
+		frame.DecodedError = c.abiRegistry.DecodeError(ctx, frame.To, callError{msg: frame.Error, data: data})
+	}
+
+	for _, call := range frame.Calls {
+		c.decodeCallFrame(ctx, call)
+	}
+}
+
+// innermostRevert follows the calls failed from root whose revert was bubbled up, i.e. with the same output
+// as their caller. A call failed but caught by its caller, e.g. with try/catch, is not followed.
+func innermostRevert(root *CallFrame) *CallFrame {
+	if root.Error == "" {
+		return nil
+	}
+
+	frame := root
+	for {
+		var failed *CallFrame
+		for _, call := range frame.Calls {
+			if call.Error != "" && bytes.Equal(call.Output, frame.Output) {
+				failed = call
+			}
+		}
+
+		if failed == nil {
+			return frame
+		}
+		frame = failed
+	}
+}
+
+func toCallArgs(from common.Address, tx *types.Transaction) map[string]interface{} {
+	args := map[string]interface{}{
+		"from":  from,
+		"input": hexutil.Bytes(tx.Data()),
+		"gas":   hexutil.Uint64(tx.Gas()),
+		"value": (*hexutil.Big)(tx.Value()),
+	}
+	if tx.To() != nil {
+		args["to"] = tx.To()
+	}
+
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	if len(tx.AccessList()) > 0 {
+		args["accessList"] = tx.AccessList()
+	}
+
+	return args
+}
+
+// toBlockOverrides overrides the context of calls with the one of header.
+func toBlockOverrides(header *types.Header) map[string]interface{} {
+	overrides := map[string]interface{}{
+		"number":   (*hexutil.Big)(header.Number),
+		"time":     hexutil.Uint64(header.Time),
+		"gasLimit": hexutil.Uint64(header.GasLimit),
+		"coinbase": header.Coinbase,
+		"random":   header.MixDigest,
+	}
+	if header.BaseFee != nil {
+		overrides["baseFee"] = (*hexutil.Big)(header.BaseFee)
+	}
+
+	return overrides
+}
+
+type callLogJSON struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+}
+
+// callFrameJSON is a call frame of callTracer.
+type callFrameJSON struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to"`
+	Value        *hexutil.Big    `json:"value"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output"`
+	Error        string          `json:"error"`
+	RevertReason string          `json:"revertReason"`
+	Calls        []callFrameJSON `json:"calls"`
+	Logs         []callLogJSON   `json:"logs"`
+}
+
+func (f callFrameJSON) toCallFrame() *CallFrame {
+	frame := &CallFrame{
+		Type:         f.Type,
+		From:         f.From,
+		To:           f.To,
+		Value:        (*big.Int)(f.Value),
+		Gas:          uint64(f.Gas),
+		GasUsed:      uint64(f.GasUsed),
+		Input:        f.Input,
+		Output:       f.Output,
+		Error:        f.Error,
+		RevertReason: f.RevertReason,
+	}
+
+	for _, l := range f.Logs {
+		frame.Logs = append(frame.Logs, types.Log{Address: l.Address, Topics: l.Topics, Data: l.Data})
+	}
+
+	for _, call := range f.Calls {
+		frame.Calls = append(frame.Calls, call.toCallFrame())
+	}
+
+	return frame
+}
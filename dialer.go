@@ -1,6 +1,7 @@
 package ethclient
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"sync"
@@ -10,6 +11,8 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/metrics"
+	"github.com/ivanzzeth/ethclient/transport"
 )
 
 type EthClientInterface interface {
@@ -98,3 +101,144 @@ func DialWithTransport(rawurl string, transport *http.Transport) (*Client, error
 	}
 	return Dial(rawurl)
 }
+
+type DialOption interface {
+	apply(*dialOptions)
+}
+
+type dialOptionFunc func(*dialOptions)
+
+func (f dialOptionFunc) apply(o *dialOptions) {
+	f(o)
+}
+
+type dialOptions struct {
+	baseTransport  http.RoundTripper
+	failoverConfig transport.FailoverConfig
+	quorumConfig   *transport.QuorumConfig
+	batchConfig    *transport.BatchConfig
+	cacheConfig    *transport.CacheConfig
+	rateLimit      *transport.RateLimitConfig
+	metrics        *metrics.Metrics
+}
+
+// WithBaseTransport sets the transport used to reach every endpoint, http.DefaultTransport by default.
+func WithBaseTransport(t http.RoundTripper) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.baseTransport = t
+	})
+}
+
+// WithFailoverConfig overrides transport.DefaultFailoverConfig.
+func WithFailoverConfig(config transport.FailoverConfig) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.failoverConfig = config
+	})
+}
+
+// WithQuorum enables quorum reads: read methods such as eth_blockNumber, eth_getBlockByNumber,
+// eth_call and eth_getLogs are sent to config.Endpoints endpoints and only return when
+// config.Threshold of them agree, otherwise a *transport.QuorumError is returned.
+func WithQuorum(config transport.QuorumConfig) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.quorumConfig = &config
+	})
+}
+
+// WithBatching coalesces concurrent requests (e.g. eth_getBalance, eth_getTransactionReceipt, eth_call)
+// into JSON-RPC batch requests, held for config.Window and up to config.MaxBatchSize requests each.
+// Errors of each request in a batch are returned to its own caller.
+func WithBatching(config transport.BatchConfig) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.batchConfig = &config
+	})
+}
+
+// WithCache answers requests of immutable data (eth_chainId, eth_getBlockByHash, and
+// eth_getTransactionByHash and eth_getTransactionReceipt once final) from an in-memory LRU,
+// backed by config.Storage if set, e.g. cache.NewRedisStorage.
+func WithCache(config transport.CacheConfig) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.cacheConfig = &config
+	})
+}
+
+// WithRateLimit holds requests to each endpoint within its requests/sec and compute units/sec,
+// with method costs from config. Transactions are sent before other waiting requests, and log
+// backfills after them, see transport.DefaultMethodPriorities and transport.WithPriority.
+func WithRateLimit(config transport.RateLimitConfig) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.rateLimit = &config
+	})
+}
+
+// WithMetrics reports the latency and errors of RPC requests by method to m, along with
+// the msgs and subscriptions of the client, see Client.SetMetrics.
+func WithMetrics(m *metrics.Metrics) DialOption {
+	return dialOptionFunc(func(o *dialOptions) {
+		o.metrics = m
+	})
+}
+
+// DialMulti creates a client backed by several HTTP/HTTPS endpoints ordered by priority.
+// Requests go to the first available endpoint and fail over to the next one on
+// transport errors, 5xx responses or rate limiting, while endpoints failing repeatedly
+// are skipped by a circuit breaker until they recover.
+// All components sharing the underlying rpc.Client (message sending, ChainSubscriber,
+// nonce manager...) benefit from it transparently.
+func DialMulti(rawurls []string, opts ...DialOption) (*Client, error) {
+	options := dialOptions{
+		failoverConfig: transport.DefaultFailoverConfig(),
+	}
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	if options.quorumConfig != nil {
+		options.failoverConfig.Quorum = options.quorumConfig
+	}
+
+	for _, rawurl := range rawurls {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("DialMulti only supports http(s) endpoints, got %v", rawurl)
+		}
+	}
+
+	base := options.baseTransport
+	if options.rateLimit != nil {
+		base = transport.NewRateLimitTransport(base, *options.rateLimit)
+	}
+
+	t, err := transport.NewFailoverTransport(rawurls, base, options.failoverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var rt http.RoundTripper = t
+	if options.batchConfig != nil {
+		rt = transport.NewBatchTransport(t, *options.batchConfig)
+	}
+	if options.cacheConfig != nil {
+		rt = transport.NewCacheTransport(rt, *options.cacheConfig)
+	}
+	if options.metrics != nil {
+		rt = transport.NewObserveTransport(rt, options.metrics.ObserveRPC)
+	}
+
+	rpcClient, err := rpc.DialHTTPWithClient(rawurls[0], &http.Client{Transport: rt})
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	client := NewClient(rpcClient)
+	client.transports = append(client.transports, t)
+	if options.metrics != nil {
+		client.SetMetrics(options.metrics)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,125 @@
+package ethclient
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ivanzzeth/ethclient/message"
+)
+
+// trackedEvents is the max number of msgs whose last event is remembered for publishing each state once.
+const trackedEvents = 100000
+
+// eventKey is the state of a msg published, which may be observed several times,
+// e.g. by the receipt emitted after the status is updated.
+type eventKey struct {
+	status message.MessageStatus
+	tx     common.Hash
+	block  common.Hash
+}
+
+// SubscribeMsg sends the events of msgId to ch until it's final, then the subscription ends,
+// closing its Err channel. Subscribe before scheduling the msg, events already published are not sent.
+func (c *Client) SubscribeMsg(msgId common.Hash, ch chan<- message.Event) event.Subscription {
+	return c.events.SubscribeMsg(msgId, ch)
+}
+
+// SubscribeMsgEvents sends the events of msgs matching filter to ch until unsubscribed.
+// Events are queued for slow subscribers rather than dropped, so keep consuming ch.
+func (c *Client) SubscribeMsgEvents(filter message.EventFilter, ch chan<- message.Event) event.Subscription {
+	return c.events.Subscribe(filter, ch)
+}
+
+// newEvent returns the event of type t of msgId in its current state.
+func (c *Client) newEvent(msgId common.Hash, t message.EventType) (message.Event, bool) {
+	msg, err := c.msgStore.GetMsg(msgId)
+	if err != nil {
+		return message.Event{}, false
+	}
+
+	e := message.Event{Type: t, MsgId: msgId, From: msg.Req.From, Status: msg.Status, Time: time.Now()}
+	if msg.Resp != nil {
+		e.Tx = msg.Resp.Tx
+	}
+	if msg.Receipt != nil {
+		e.Receipt = msg.Receipt.TxReceipt
+	}
+	return e, true
+}
+
+// publishStatus publishes msgId entering status, unless its state is published already.
+func (c *Client) publishStatus(msgId common.Hash, status message.MessageStatus) {
+	if status == message.MessageStatusFailed || !c.events.HasSubscribers() {
+		// Failures are published with their error by publishFailed.
+		return
+	}
+
+	e, ok := c.newEvent(msgId, message.StatusEvent(status))
+	if !ok {
+		return
+	}
+	e.Status = status
+	if status == message.MessageStatusInflight && e.Tx == nil {
+		// Published once the response of the msg is stored.
+		return
+	}
+
+	key := eventKey{status: status}
+	if e.Tx != nil {
+		key.tx = e.Tx.Hash()
+	}
+	if e.Receipt != nil {
+		key.block = e.Receipt.BlockHash
+	}
+
+	c.eventMu.Lock()
+	defer c.eventMu.Unlock()
+
+	if last, ok := c.lastEvents.Get(msgId); ok && last == key {
+		return
+	}
+	if e.Final() {
+		c.lastEvents.Remove(msgId)
+	} else {
+		c.lastEvents.Add(msgId, key)
+	}
+	c.events.Publish(e)
+}
+
+func (c *Client) publishReplaced(msgId common.Hash, resp message.Response) {
+	if resp.Err != nil || resp.Tx == nil || !c.events.HasSubscribers() {
+		return
+	}
+
+	e, ok := c.newEvent(msgId, message.EventReplaced)
+	if !ok {
+		return
+	}
+	e.Tx = resp.Tx
+
+	c.eventMu.Lock()
+	defer c.eventMu.Unlock()
+
+	c.events.Publish(e)
+}
+
+// publishFailed publishes msgId failed with err. Msgs cancelled are published as such.
+func (c *Client) publishFailed(msgId common.Hash, err error) {
+	if errors.Is(err, message.ErrMsgCancelled) || !c.events.HasSubscribers() {
+		return
+	}
+
+	e, ok := c.newEvent(msgId, message.EventFailed)
+	if !ok {
+		e = message.Event{Type: message.EventFailed, MsgId: msgId, Time: time.Now()}
+	}
+	e.Err = err
+
+	c.eventMu.Lock()
+	defer c.eventMu.Unlock()
+
+	c.lastEvents.Remove(msgId)
+	c.events.Publish(e)
+}
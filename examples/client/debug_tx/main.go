@@ -20,6 +20,13 @@ func main() {
 	}
 
 	// client.SetABI()
-	ret, err := client.DebugTransactionOnChain(context.Background(), common.HexToHash("0x8b1becff129aa708913bd3278c3581f34833227561c5bf3a54ece3334f5d4a47"))
-	fmt.Printf("got ret 0x%x, err %v\n", ret, err)
+	result, err := client.DebugTransactionOnChain(context.Background(), common.HexToHash("0x8b1becff129aa708913bd3278c3581f34833227561c5bf3a54ece3334f5d4a47"))
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("traced %v, gas used %v, revert err %v\n", result.Traced, result.Root.GasUsed, result.Err())
+	if result.Revert != nil {
+		fmt.Printf("reverted in call to %v: %v\n", result.Revert.To, result.Revert.DecodedError)
+	}
 }
@@ -0,0 +1,98 @@
+package gas
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+var _ Oracle = (*FeeHistoryOracle)(nil)
+
+type feeHistoryBackend interface {
+	ethereum.FeeHistoryReader
+	ethereum.GasPricer
+}
+
+// FeeHistoryOracle suggests tips from the rewards paid in recent blocks (eth_feeHistory),
+// taking a higher percentile for more urgent transactions.
+type FeeHistoryOracle struct {
+	backend feeHistoryBackend
+	config  FeeHistoryConfig
+}
+
+type FeeHistoryConfig struct {
+	// Number of recent blocks sampled.
+	Blocks uint64
+	// Reward percentile sampled per urgency.
+	Percentiles map[Urgency]float64
+	// Lower bound of suggested tips, used when recent blocks paid no tip at all.
+	MinTipCap *big.Int
+}
+
+func DefaultFeeHistoryConfig() FeeHistoryConfig {
+	return FeeHistoryConfig{
+		Blocks: 20,
+		Percentiles: map[Urgency]float64{
+			UrgencyLow:    10,
+			UrgencyNormal: 50,
+			UrgencyHigh:   90,
+		},
+		MinTipCap: big.NewInt(1e8), // 0.1 gwei
+	}
+}
+
+func NewFeeHistoryOracle(backend feeHistoryBackend, config FeeHistoryConfig) *FeeHistoryOracle {
+	return &FeeHistoryOracle{backend: backend, config: config}
+}
+
+func (o *FeeHistoryOracle) SuggestFees(ctx context.Context, urgency Urgency) (Fees, error) {
+	percentile, ok := o.config.Percentiles[urgency]
+	if !ok {
+		percentile = o.config.Percentiles[UrgencyNormal]
+	}
+
+	history, err := o.backend.FeeHistory(ctx, o.config.Blocks, nil, []float64{percentile})
+	if err != nil {
+		return Fees{}, err
+	}
+
+	// BaseFee contains the base fee of the next block as its last element.
+	var nextBaseFee *big.Int
+	if len(history.BaseFee) > 0 {
+		nextBaseFee = history.BaseFee[len(history.BaseFee)-1]
+	}
+
+	if nextBaseFee == nil || nextBaseFee.Sign() == 0 {
+		gasPrice, err := o.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return Fees{}, err
+		}
+		return Fees{GasPrice: gasPrice}, nil
+	}
+
+	return dynamicFees(nextBaseFee, o.tipFromRewards(history.Reward)), nil
+}
+
+// tipFromRewards returns the median of the sampled rewards, skipping blocks which paid no tip.
+func (o *FeeHistoryOracle) tipFromRewards(rewards [][]*big.Int) *big.Int {
+	var tips []*big.Int
+	for _, r := range rewards {
+		if len(r) > 0 && r[0] != nil && r[0].Sign() > 0 {
+			tips = append(tips, r[0])
+		}
+	}
+
+	tip := new(big.Int)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip.Set(tips[len(tips)/2])
+	}
+
+	if o.config.MinTipCap != nil && tip.Cmp(o.config.MinTipCap) < 0 {
+		tip.Set(o.config.MinTipCap)
+	}
+
+	return tip
+}
@@ -0,0 +1,137 @@
+// Package gas provides gas oracles suggesting the fees of transactions.
+package gas
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Urgency selects how fast a transaction should be included, and so how much it pays for it.
+type Urgency uint8
+
+const (
+	UrgencyNormal Urgency = iota // the zero value, so requests without urgency are normal
+	UrgencyLow
+	UrgencyHigh
+)
+
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyNormal:
+		return "normal"
+	case UrgencyLow:
+		return "low"
+	case UrgencyHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// Fees suggested for a transaction.
+type Fees struct {
+	// Price of legacy transactions, always set.
+	GasPrice *big.Int
+	// EIP-1559 fees, only set on chains with London activated.
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+func (f Fees) Copy() Fees {
+	return Fees{
+		GasPrice:  copyBig(f.GasPrice),
+		GasTipCap: copyBig(f.GasTipCap),
+		GasFeeCap: copyBig(f.GasFeeCap),
+	}
+}
+
+type Oracle interface {
+	SuggestFees(ctx context.Context, urgency Urgency) (Fees, error)
+}
+
+// Limiter is implemented by oracles enforcing fee ceilings. Ceilings apply to every
+// transaction sent, including fees set on requests and bumped by replacements.
+type Limiter interface {
+	Limit(fees Fees) Fees
+}
+
+// Limit applies the ceilings of o to fees if o is a Limiter.
+func Limit(o Oracle, fees Fees) Fees {
+	if l, ok := o.(Limiter); ok {
+		return l.Limit(fees)
+	}
+	return fees
+}
+
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+type nodeBackend interface {
+	headerReader
+	ethereum.GasPricer
+	ethereum.GasPricer1559
+}
+
+var _ Oracle = (*NodeOracle)(nil)
+
+// NodeOracle uses the fees suggested by the node as is, whatever the urgency.
+type NodeOracle struct {
+	backend nodeBackend
+}
+
+func NewNodeOracle(backend nodeBackend) *NodeOracle {
+	return &NodeOracle{backend: backend}
+}
+
+func (o *NodeOracle) SuggestFees(ctx context.Context, urgency Urgency) (Fees, error) {
+	head, err := o.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return Fees{}, err
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := o.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return Fees{}, err
+		}
+		return Fees{GasPrice: gasPrice}, nil
+	}
+
+	tip, err := o.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return Fees{}, err
+	}
+
+	return dynamicFees(head.BaseFee, tip), nil
+}
+
+// dynamicFees builds fees keeping the tx includable through six full blocks in a row,
+// the same way as bind does.
+func dynamicFees(baseFee, tip *big.Int) Fees {
+	feeCap := new(big.Int).Mul(baseFee, big.NewInt(2))
+	feeCap.Add(feeCap, tip)
+
+	return Fees{
+		GasPrice:  new(big.Int).Add(baseFee, tip),
+		GasTipCap: new(big.Int).Set(tip),
+		GasFeeCap: feeCap,
+	}
+}
+
+func copyBig(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
+
+func minBig(a, b *big.Int) *big.Int {
+	if a == nil || b == nil || a.Cmp(b) <= 0 {
+		return a
+	}
+	return new(big.Int).Set(b)
+}
@@ -0,0 +1,154 @@
+package gas
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type fakeBackend struct {
+	baseFee  *big.Int
+	gasPrice *big.Int
+	tip      *big.Int
+	rewards  [][]*big.Int
+
+	percentiles []float64
+}
+
+func (b *fakeBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: b.baseFee}, nil
+}
+
+func (b *fakeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(b.gasPrice), nil
+}
+
+func (b *fakeBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(b.tip), nil
+}
+
+func (b *fakeBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	b.percentiles = rewardPercentiles
+
+	var baseFees []*big.Int
+	for i := uint64(0); i <= blockCount; i++ {
+		baseFees = append(baseFees, b.baseFee)
+	}
+
+	return &ethereum.FeeHistory{BaseFee: baseFees, Reward: b.rewards}, nil
+}
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+}
+
+func TestNodeOracle(t *testing.T) {
+	backend := &fakeBackend{baseFee: gwei(10), gasPrice: gwei(12), tip: gwei(2)}
+
+	fees, err := NewNodeOracle(backend).SuggestFees(context.Background(), UrgencyNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasTipCap.Cmp(gwei(2)) != 0 || fees.GasFeeCap.Cmp(gwei(22)) != 0 || fees.GasPrice.Cmp(gwei(12)) != 0 {
+		t.Fatalf("unexpected fees: %+v", fees)
+	}
+
+	backend.baseFee = nil
+	fees, err = NewNodeOracle(backend).SuggestFees(context.Background(), UrgencyNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasPrice.Cmp(gwei(12)) != 0 || fees.GasFeeCap != nil || fees.GasTipCap != nil {
+		t.Fatalf("unexpected legacy fees: %+v", fees)
+	}
+}
+
+func TestFeeHistoryOracle(t *testing.T) {
+	backend := &fakeBackend{
+		baseFee:  gwei(10),
+		gasPrice: gwei(12),
+		rewards:  [][]*big.Int{{gwei(1)}, {gwei(0)}, {gwei(3)}, {gwei(2)}},
+	}
+	config := DefaultFeeHistoryConfig()
+	o := NewFeeHistoryOracle(backend, config)
+
+	fees, err := o.SuggestFees(context.Background(), UrgencyHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.percentiles[0] != config.Percentiles[UrgencyHigh] {
+		t.Fatalf("unexpected percentile sampled: %v", backend.percentiles)
+	}
+	// Median of non zero rewards.
+	if fees.GasTipCap.Cmp(gwei(2)) != 0 || fees.GasFeeCap.Cmp(gwei(22)) != 0 {
+		t.Fatalf("unexpected fees: %+v", fees)
+	}
+
+	backend.rewards = [][]*big.Int{{gwei(0)}}
+	fees, err = o.SuggestFees(context.Background(), UrgencyLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasTipCap.Cmp(config.MinTipCap) != 0 {
+		t.Fatalf("expected min tip cap, got %v", fees.GasTipCap)
+	}
+
+	backend.baseFee = big.NewInt(0)
+	fees, err = o.SuggestFees(context.Background(), UrgencyNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasPrice.Cmp(gwei(12)) != 0 || fees.GasFeeCap != nil {
+		t.Fatalf("unexpected legacy fees: %+v", fees)
+	}
+}
+
+func TestCappedOracle(t *testing.T) {
+	inner := NewFixedOracle(Fees{GasPrice: gwei(500), GasTipCap: gwei(50), GasFeeCap: gwei(500)})
+	o := NewCappedOracle(inner, Fees{GasPrice: gwei(100), GasFeeCap: gwei(40)})
+
+	fees, err := o.SuggestFees(context.Background(), UrgencyHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tip can never exceed the fee cap.
+	if fees.GasPrice.Cmp(gwei(100)) != 0 || fees.GasFeeCap.Cmp(gwei(40)) != 0 || fees.GasTipCap.Cmp(gwei(40)) != 0 {
+		t.Fatalf("unexpected fees: %+v", fees)
+	}
+
+	limited := Limit(o, Fees{GasPrice: gwei(1), GasTipCap: gwei(1), GasFeeCap: gwei(2)})
+	if limited.GasPrice.Cmp(gwei(1)) != 0 || limited.GasTipCap.Cmp(gwei(1)) != 0 || limited.GasFeeCap.Cmp(gwei(2)) != 0 {
+		t.Fatalf("fees under ceilings should be kept: %+v", limited)
+	}
+}
+
+func TestTieredOracle(t *testing.T) {
+	low := NewFixedOracle(Fees{GasPrice: gwei(1)})
+	normal := NewFixedOracle(Fees{GasPrice: gwei(5)})
+	o := NewTieredOracle(NewCappedOracle(normal, Fees{GasPrice: gwei(3)}), map[Urgency]Oracle{UrgencyLow: low})
+
+	tests := []struct {
+		urgency Urgency
+		want    *big.Int
+	}{
+		{UrgencyLow, gwei(1)},
+		{UrgencyNormal, gwei(3)},
+		{UrgencyHigh, gwei(3)},
+	}
+	for _, tt := range tests {
+		fees, err := o.SuggestFees(context.Background(), tt.urgency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fees.GasPrice.Cmp(tt.want) != 0 {
+			t.Fatalf("urgency %v: want %v, got %v", tt.urgency, tt.want, fees.GasPrice)
+		}
+	}
+
+	if limited := Limit(o, Fees{GasPrice: gwei(10)}); limited.GasPrice.Cmp(gwei(3)) != 0 {
+		t.Fatalf("fallback ceilings should apply, got %v", limited.GasPrice)
+	}
+}
@@ -0,0 +1,97 @@
+package gas
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var _ Oracle = (*FixedOracle)(nil)
+
+// FixedOracle always suggests the same fees.
+type FixedOracle struct {
+	fees Fees
+}
+
+// NewFixedOracle creates an oracle suggesting fees whatever the urgency.
+// Leave the EIP-1559 fees nil on chains without London activated.
+func NewFixedOracle(fees Fees) *FixedOracle {
+	return &FixedOracle{fees: fees.Copy()}
+}
+
+func (o *FixedOracle) SuggestFees(ctx context.Context, urgency Urgency) (Fees, error) {
+	return o.fees.Copy(), nil
+}
+
+var (
+	_ Oracle  = (*CappedOracle)(nil)
+	_ Limiter = (*CappedOracle)(nil)
+)
+
+// CappedOracle bounds the fees of every transaction, so that a fee spike never drains hot wallets.
+// Transactions may then stay pending until fees go back under the ceilings.
+type CappedOracle struct {
+	Oracle
+	max Fees
+}
+
+// NewCappedOracle wraps o with the ceilings in max, nil fields are not capped.
+func NewCappedOracle(o Oracle, max Fees) *CappedOracle {
+	return &CappedOracle{Oracle: o, max: max.Copy()}
+}
+
+func (o *CappedOracle) SuggestFees(ctx context.Context, urgency Urgency) (Fees, error) {
+	fees, err := o.Oracle.SuggestFees(ctx, urgency)
+	if err != nil {
+		return Fees{}, err
+	}
+
+	return o.Limit(fees), nil
+}
+
+func (o *CappedOracle) Limit(fees Fees) Fees {
+	// Inner oracles may enforce their own ceilings too.
+	fees = Limit(o.Oracle, fees)
+
+	limited := Fees{
+		GasPrice:  minBig(fees.GasPrice, o.max.GasPrice),
+		GasFeeCap: minBig(fees.GasFeeCap, o.max.GasFeeCap),
+		GasTipCap: minBig(fees.GasTipCap, o.max.GasTipCap),
+	}
+	limited.GasTipCap = minBig(limited.GasTipCap, limited.GasFeeCap)
+
+	if limited.GasPrice != fees.GasPrice || limited.GasFeeCap != fees.GasFeeCap || limited.GasTipCap != fees.GasTipCap {
+		log.Warn("fees reached out ceilings", "gasPrice", fees.GasPrice, "gasFeeCap", fees.GasFeeCap, "gasTipCap", fees.GasTipCap,
+			"limitedGasPrice", limited.GasPrice, "limitedGasFeeCap", limited.GasFeeCap, "limitedGasTipCap", limited.GasTipCap)
+	}
+
+	return limited
+}
+
+var (
+	_ Oracle  = (*TieredOracle)(nil)
+	_ Limiter = (*TieredOracle)(nil)
+)
+
+// TieredOracle delegates to a different oracle per urgency, falling back to a default one.
+type TieredOracle struct {
+	tiers    map[Urgency]Oracle
+	fallback Oracle
+}
+
+func NewTieredOracle(fallback Oracle, tiers map[Urgency]Oracle) *TieredOracle {
+	return &TieredOracle{tiers: tiers, fallback: fallback}
+}
+
+func (o *TieredOracle) SuggestFees(ctx context.Context, urgency Urgency) (Fees, error) {
+	if tier, ok := o.tiers[urgency]; ok {
+		return tier.SuggestFees(ctx, urgency)
+	}
+
+	return o.fallback.SuggestFees(ctx, urgency)
+}
+
+// Limit applies the ceilings of the fallback oracle, the ones of tiers only apply to their suggestions.
+func (o *TieredOracle) Limit(fees Fees) Fees {
+	return Limit(o.fallback, fees)
+}
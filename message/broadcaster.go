@@ -2,47 +2,277 @@ package message
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Broadcaster interface {
 	CallAndSendMsg(ctx context.Context, msg Request) (resp Response)
 	SendMsg(ctx context.Context, msg Request) (resp Response)
+	// ProtectMsg makes sure a msg already broadcasted gets on chain, e.g. after restarts.
+	ProtectMsg(ctx context.Context, msgId common.Hash)
 }
 
 // SimpleBroadcaster makes sure that every message broadcasted could be consumed(on-chain) correctly.
 type SimpleBroadcaster struct {
-	msgManager         Manager
+	msgManager Manager
+	// Msgs are on chain as soon as mined, their finality is tracked by FinalityTracker.
 	blockConfirmations uint64
 	timeout            time.Duration
+	onReceipt          ReceiptHandler
+	onReplacement      ReplacementHandler
+	retryPolicy        RetryPolicy
+	protections        *protections
 }
 
+// protections are the msgs being protected, so that they can be stopped, e.g. before their nonces are reused.
+type protections struct {
+	mu   sync.Mutex
+	msgs map[common.Hash][]*protection
+}
+
+type protection struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ReplacementHandler is called each time the tx of a msg not mined in time was replaced with higher fees.
+type ReplacementHandler func(msgId common.Hash, resp Response)
+
 func NewSimpleBroadcaster(msgManager Manager) *SimpleBroadcaster {
 	return &SimpleBroadcaster{
 		msgManager:         msgManager,
-		blockConfirmations: 0, // TODO:
+		blockConfirmations: 0,
 		timeout:            20 * time.Second,
+		retryPolicy:        DefaultRetryPolicy(),
+		protections:        &protections{msgs: make(map[common.Hash][]*protection)},
 	}
 }
 
+// SetReceiptHandler sets the handler called when msgs get on chain, before broadcasting any msg.
+func (b *SimpleBroadcaster) SetReceiptHandler(handler ReceiptHandler) {
+	b.onReceipt = handler
+}
+
+// SetReplacementHandler sets the handler called when msgs are replaced, before broadcasting any msg.
+func (b *SimpleBroadcaster) SetReplacementHandler(handler ReplacementHandler) {
+	b.onReplacement = handler
+}
+
+// SetRetryPolicy sets how msgs failed to send are retried, before broadcasting any msg.
+func (b *SimpleBroadcaster) SetRetryPolicy(policy RetryPolicy) {
+	b.retryPolicy = policy
+}
+
 func (b SimpleBroadcaster) CallAndSendMsg(ctx context.Context, msg Request) (resp Response) {
-	resp = b.msgManager.CallAndSendMsg(ctx, msg)
+	sendCtx, span := startSpan(ctx, "ethclient.broadcast", MsgAttributes(msg)...)
+	resp = b.msgManager.CallAndSendMsg(sendCtx, msg)
+	b.endBroadcastSpan(span, resp)
 
-	go b.protect(ctx, msg.Id())
+	b.ProtectMsg(ctx, msg.Id())
 	return
 }
 
 func (b SimpleBroadcaster) SendMsg(ctx context.Context, msg Request) (resp Response) {
-	resp = b.msgManager.SendMsg(ctx, msg)
+	resp = b.send(ctx, msg)
+	if resp.Err != nil {
+		// It's protected once retried successfully, see RetryMsg.
+		return
+	}
 
-	go b.protect(ctx, msg.Id())
+	b.ProtectMsg(ctx, msg.Id())
 	return
 }
 
+// ShouldRetry reports whether a msg failed to send with err is retried by RetryMsg.
+func (b SimpleBroadcaster) ShouldRetry(err error) bool {
+	return err != nil && b.retryPolicy.MaxAttempts > 1 && b.retryPolicy.Action(err) != RetryActionNone
+}
+
+// RetryMsg sends msg failed with resp again according to the retry policy, returning the last response.
+// It blocks during backoffs and while waiting for funds. Retries are recorded in Message.Retries.
+func (b SimpleBroadcaster) RetryMsg(ctx context.Context, msg Request, resp Response) Response {
+	backend, ok := b.msgManager.(retryBackend)
+	if !ok {
+		log.Warn("msg manager does not support retries", "msgId", msg.Id().Hex())
+		return resp
+	}
+
+	for attempt := 1; resp.Err != nil; attempt++ {
+		action := b.retryPolicy.Action(resp.Err)
+		if action == RetryActionNone || attempt >= b.retryPolicy.MaxAttempts {
+			return resp
+		}
+
+		var backoff time.Duration
+		if action != RetryActionTrackTx {
+			backoff = b.retryPolicy.backoff(attempt)
+		}
+
+		log.Warn("retry msg", "msgId", msg.Id().Hex(), "attempt", attempt, "action", action, "backoff", backoff, "err", resp.Err)
+		b.recordRetry(msg.Id(), RetryRecord{Attempt: attempt, Time: time.Now(), Err: resp.Err.Error(), Action: action, Backoff: backoff})
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("action", action.String()),
+			attribute.String("error", resp.Err.Error()),
+		))
+
+		if action == RetryActionTrackTx {
+			if resp.Tx == nil {
+				return resp
+			}
+
+			resp.Err = b.msgManager.UpdateMsgStatus(msg.Id(), MessageStatusInflight)
+			if resp.Err != nil {
+				return resp
+			}
+			break
+		}
+
+		// Recovered as a msg queued if the client restarts meanwhile.
+		b.msgManager.UpdateMsgStatus(msg.Id(), MessageStatusQueued)
+
+		var err error
+		if action == RetryActionWaitForFunds {
+			err = waitForFunds(ctx, backend, msg.From, backoff, b.retryPolicy.FundsTimeout)
+		} else {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+		if err != nil {
+			return Response{Id: msg.Id(), Err: err}
+		}
+
+		stored, err := b.msgManager.GetMsg(msg.Id())
+		if err == nil && stored.Status == MessageStatusCancelled {
+			return Response{Id: msg.Id(), Err: ErrMsgCancelled}
+		}
+
+		switch action {
+		case RetryActionResyncNonce:
+			err = backend.SyncNonce(ctx, msg.From)
+		case RetryActionBumpFee:
+			msg = *msg.Copy()
+			err = bumpRequestFees(ctx, backend, &msg)
+			if err == nil && stored.Req != nil {
+				stored.Req = &msg
+				err = b.msgManager.UpdateMsg(stored)
+			}
+		}
+		if err != nil {
+			return Response{Id: msg.Id(), Err: err}
+		}
+
+		resp = b.send(ctx, msg)
+	}
+
+	b.ProtectMsg(ctx, msg.Id())
+	return resp
+}
+
+func (b SimpleBroadcaster) send(ctx context.Context, msg Request) Response {
+	sendCtx, span := startSpan(ctx, "ethclient.broadcast", MsgAttributes(msg)...)
+	resp := b.msgManager.SendMsg(sendCtx, msg)
+	b.endBroadcastSpan(span, resp)
+	return resp
+}
+
+func (b SimpleBroadcaster) endBroadcastSpan(span trace.Span, resp Response) {
+	if resp.Tx != nil {
+		span.SetAttributes(TxAttributes(resp.Tx)...)
+	}
+	endSpan(span, resp.Err)
+}
+
+func (b SimpleBroadcaster) recordRetry(msgId common.Hash, record RetryRecord) {
+	msg, err := b.msgManager.GetMsg(msgId)
+	if err != nil {
+		log.Error("record retry failed", "msgId", msgId.Hex(), "err", err)
+		return
+	}
+
+	msg.Retries = append(msg.Retries, record)
+	err = b.msgManager.UpdateMsg(msg)
+	if err != nil {
+		log.Error("record retry failed", "msgId", msgId.Hex(), "err", err)
+	}
+}
+
+func (b SimpleBroadcaster) ProtectMsg(ctx context.Context, msgId common.Hash) {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &protection{cancel: cancel, done: make(chan struct{})}
+
+	b.protections.mu.Lock()
+	b.protections.msgs[msgId] = append(b.protections.msgs[msgId], p)
+	b.protections.mu.Unlock()
+
+	go func() {
+		defer close(p.done)
+		defer b.protections.remove(msgId, p)
+		defer cancel()
+
+		b.protect(ctx, msgId)
+	}()
+}
+
+// StopProtecting stops protecting msgId, returning once its tx is not replaced or marked on chain any more.
+// It reports whether msgId was being protected.
+func (b SimpleBroadcaster) StopProtecting(msgId common.Hash) bool {
+	b.protections.mu.Lock()
+	ps := b.protections.msgs[msgId]
+	delete(b.protections.msgs, msgId)
+	b.protections.mu.Unlock()
+
+	for _, p := range ps {
+		p.cancel()
+		<-p.done
+	}
+
+	return len(ps) > 0
+}
+
+func (ps *protections) remove(msgId common.Hash, p *protection) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	remaining := ps.msgs[msgId][:0]
+	for _, other := range ps.msgs[msgId] {
+		if other != p {
+			remaining = append(remaining, other)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(ps.msgs, msgId)
+	} else {
+		ps.msgs[msgId] = remaining
+	}
+}
+
 func (b SimpleBroadcaster) protect(ctx context.Context, msgId common.Hash) {
+	ctx, span := startSpan(ctx, "ethclient.protect", attribute.String("msg.id", msgId.Hex()))
+	defer span.End()
+
+	b.waitOnChain(ctx, msgId)
+}
+
+// receiptsWaiter is implemented by managers waiting for the receipt of any of several txs, e.g. SimpleManager.
+type receiptsWaiter interface {
+	WaitTxsReceipt(ctx context.Context, txHashes []common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool)
+}
+
+// waitOnChain replaces the tx of msgId with higher fees until one of the txs sent for it gets on chain.
+func (b SimpleBroadcaster) waitOnChain(ctx context.Context, msgId common.Hash) {
 	resp, ok := b.msgManager.WaitMsgResponse(msgId, b.timeout)
 	if !ok {
 		log.Error("no need to protect error response", "msgId", msgId)
@@ -53,13 +283,84 @@ func (b SimpleBroadcaster) protect(ctx context.Context, msgId common.Hash) {
 		return
 	}
 
+	msg, err := b.msgManager.GetMsg(msgId)
+	if err != nil {
+		log.Error("protect msg failed", "msgId", msgId, "err", err)
+		return
+	}
+
+	if msg.Status == MessageStatusNonceReleased {
+		log.Info("stop protecting msg replaced by another one", "msgId", msgId.Hex())
+		return
+	}
+
 	log.Info("protect msg", "msgId", msgId.Hex(), "txHash", resp.Tx.Hash().Hex(), "resp", *resp)
 
-	txReceipt, ok := b.msgManager.WaitTxReceipt(resp.Tx.Hash(), b.blockConfirmations, b.timeout)
+	// Any tx sent for the msg may get on chain, not only the last replacement.
+	txHashes := msg.sentTxHashes()
+	if len(txHashes) == 0 {
+		txHashes = []common.Hash{resp.Tx.Hash()}
+	}
+
+	_, span := startSpan(ctx, "ethclient.wait_receipt", TxAttributes(resp.Tx)...)
+	txReceipt, ok := b.waitTxsReceipt(ctx, txHashes)
+	span.SetAttributes(attribute.Bool("mined", ok))
+	span.End()
+
+	if ctx.Err() != nil {
+		log.Info("stop protecting msg", "msgId", msgId.Hex())
+		return
+	}
+
 	if !ok {
-		b.msgManager.ReplaceMsgWithHigherGasPrice(ctx, msgId)
-		b.protect(ctx, msgId)
-	} else {
-		b.msgManager.UpdateReceipt(msgId, Receipt{Id: msgId, TxReceipt: txReceipt})
+		replaceCtx, span := startSpan(ctx, "ethclient.replace", TxAttributes(resp.Tx)...)
+		resp := b.msgManager.ReplaceMsgWithHigherGasPrice(replaceCtx, msgId)
+		if resp.Tx != nil {
+			span.SetAttributes(attribute.String("tx.replacement", resp.Tx.Hash().Hex()))
+		}
+		endSpan(span, resp.Err)
+
+		if b.onReplacement != nil {
+			b.onReplacement(msgId, resp)
+		}
+
+		if consts.ClassifyError(resp.Err) != consts.ErrNonceTooLow {
+			b.waitOnChain(ctx, msgId)
+			return
+		}
+
+		// One of the txs sent before got on chain meanwhile, so look for its receipt instead of replacing again.
+		txReceipt, ok = b.waitTxsReceipt(ctx, txHashes)
+		if ctx.Err() != nil {
+			log.Info("stop protecting msg", "msgId", msgId.Hex())
+			return
+		}
+		if !ok {
+			log.Error("stop protecting msg whose nonce was used by another tx", "msgId", msgId.Hex(), "txHashes", txHashes)
+			return
+		}
+	}
+
+	status := MessageStatusOnChain
+	msg, err = b.msgManager.GetMsg(msgId)
+	if err == nil && msg.cancelledBy(txReceipt.TxHash) {
+		// The self-transfer cancelling it got on chain, otherwise the cancellation came too late.
+		status = MessageStatusCancelled
 	}
+
+	receipt := Receipt{Id: msgId, TxReceipt: txReceipt, Status: status}
+	b.msgManager.UpdateReceipt(msgId, receipt)
+	b.msgManager.UpdateMsgStatus(msgId, status)
+
+	if b.onReceipt != nil {
+		b.onReceipt(receipt)
+	}
+}
+
+func (b SimpleBroadcaster) waitTxsReceipt(ctx context.Context, txHashes []common.Hash) (*types.Receipt, bool) {
+	if waiter, ok := b.msgManager.(receiptsWaiter); ok {
+		return waiter.WaitTxsReceipt(ctx, txHashes, b.blockConfirmations, b.timeout)
+	}
+
+	return b.msgManager.WaitTxReceipt(txHashes[len(txHashes)-1], b.blockConfirmations, b.timeout)
 }
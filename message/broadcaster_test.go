@@ -0,0 +1,147 @@
+package message
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeProtectManager mines the first tx sent for a msg once it's replaced,
+// so that replacing it again fails with nonce too low.
+type fakeProtectManager struct {
+	Storage
+	ScheduleManager
+
+	first    common.Hash
+	mined    common.Hash
+	replaced int
+}
+
+func (m *fakeProtectManager) WaitMsgResponse(msgId common.Hash, timeout time.Duration) (*Response, bool) {
+	msg, err := m.GetMsg(msgId)
+	if err != nil || msg.Resp == nil {
+		return nil, false
+	}
+	return msg.Resp, true
+}
+
+func (m *fakeProtectManager) WaitTxsReceipt(ctx context.Context, txHashes []common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool) {
+	for _, hash := range txHashes {
+		if hash == m.mined {
+			return &types.Receipt{TxHash: hash, BlockNumber: big.NewInt(1)}, true
+		}
+	}
+	return nil, false
+}
+
+func (m *fakeProtectManager) ReplaceMsgWithHigherGasPrice(ctx context.Context, msgId common.Hash) Response {
+	m.replaced++
+	msg, err := m.GetMsg(msgId)
+	if err != nil {
+		return Response{Id: msgId, Err: err}
+	}
+
+	if m.replaced > 1 {
+		m.mined = m.first
+		return Response{Id: msgId, Err: nodeError{"nonce too low"}}
+	}
+
+	msg.Resp = &Response{Id: msgId, Tx: types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(2)})}
+	if err := m.UpdateMsg(msg); err != nil {
+		return Response{Id: msgId, Err: err}
+	}
+	return *msg.Resp
+}
+
+func TestWaitOnChain_EarlierTxMined(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1)})
+	manager := &fakeProtectManager{Storage: storage, first: original.Hash()}
+	broadcaster := NewSimpleBroadcaster(manager)
+
+	req := (&Request{From: common.HexToAddress("0x1")}).SetRandomId()
+	if err := storage.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateResponse(req.Id(), Response{Id: req.Id(), Tx: original}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		broadcaster.waitOnChain(context.Background(), req.Id())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("protecting msg did not stop")
+	}
+
+	msg, err := storage.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.replaced != 2 || msg.Status != MessageStatusOnChain || msg.Receipt.TxReceipt.TxHash != original.Hash() {
+		t.Fatalf("want the original tx on chain after 2 replacements, got %v replacements, status %v", manager.replaced, msg.Status)
+	}
+}
+
+// blockingProtectManager never finds the receipts of a msg until its protection is stopped.
+type blockingProtectManager struct {
+	fakeProtectManager
+	waiting chan struct{}
+}
+
+func (m *blockingProtectManager) WaitTxsReceipt(ctx context.Context, txHashes []common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool) {
+	close(m.waiting)
+	<-ctx.Done()
+	return nil, false
+}
+
+func TestStopProtecting(t *testing.T) {
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := &blockingProtectManager{fakeProtectManager: fakeProtectManager{Storage: storage}, waiting: make(chan struct{})}
+	broadcaster := NewSimpleBroadcaster(manager)
+
+	req := (&Request{From: common.HexToAddress("0x1")}).SetRandomId()
+	if err := storage.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1)})
+	if err := storage.UpdateResponse(req.Id(), Response{Id: req.Id(), Tx: tx}); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcaster.ProtectMsg(context.Background(), req.Id())
+	select {
+	case <-manager.waiting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("msg not protected")
+	}
+
+	if !broadcaster.StopProtecting(req.Id()) {
+		t.Fatal("want msg protected")
+	}
+	if broadcaster.StopProtecting(req.Id()) {
+		t.Fatal("want msg not protected any more")
+	}
+
+	msg, err := storage.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.replaced != 0 || msg.Status == MessageStatusOnChain {
+		t.Fatalf("want msg untouched after protection stopped, got %v replacements, status %v", manager.replaced, msg.Status)
+	}
+}
@@ -0,0 +1,190 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+// storedMessage is the serialized form of Message used by persistent storages.
+type storedMessage struct {
+	Root    *common.Hash    `json:"root,omitempty"`
+	Parent  *common.Hash    `json:"parent,omitempty"`
+	Req     *storedRequest  `json:"req"`
+	Resp    *storedResponse `json:"resp,omitempty"`
+	Receipt *storedReceipt  `json:"receipt,omitempty"`
+	Status  MessageStatus   `json:"status"`
+	Retries []RetryRecord   `json:"retries,omitempty"`
+	History []HistoryRecord `json:"history,omitempty"`
+}
+
+type storedRequest struct {
+	Id common.Hash `json:"id"`
+	Request
+}
+
+type storedResponse struct {
+	Id         common.Hash   `json:"id"`
+	Tx         hexutil.Bytes `json:"tx,omitempty"` // binary encoding of the tx
+	ReturnData hexutil.Bytes `json:"returnData,omitempty"`
+	Err        *storedError  `json:"err,omitempty"`
+}
+
+type storedReceipt struct {
+	Id        common.Hash    `json:"id"`
+	TxReceipt *types.Receipt `json:"txReceipt,omitempty"`
+	Status    MessageStatus  `json:"status,omitempty"`
+}
+
+// storedError keeps the information needed to tell errors apart after a round trip,
+// other errors only keep their message.
+type storedError struct {
+	Message      string               `json:"message"`
+	JsonRpcError *consts.JsonRpcError `json:"jsonRpcError,omitempty"`
+	Code         *int                 `json:"code,omitempty"`
+	Data         interface{}          `json:"data,omitempty"`
+}
+
+// codecError restores errors of the rpc package, such as the ones returned by SendTransaction.
+type codecError struct {
+	message string
+	code    int
+	data    interface{}
+}
+
+var (
+	_ rpc.Error     = (*codecError)(nil)
+	_ rpc.DataError = (*codecError)(nil)
+)
+
+func (e *codecError) Error() string          { return e.message }
+func (e *codecError) ErrorCode() int         { return e.code }
+func (e *codecError) ErrorData() interface{} { return e.data }
+
+func encodeError(err error) *storedError {
+	if err == nil {
+		return nil
+	}
+
+	stored := &storedError{Message: err.Error()}
+
+	var jsonRpcErr *consts.JsonRpcError
+	var rpcErr rpc.Error
+	if errors.As(err, &jsonRpcErr) {
+		stored.JsonRpcError = jsonRpcErr
+	} else if errors.As(err, &rpcErr) {
+		code := rpcErr.ErrorCode()
+		stored.Code = &code
+
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) {
+			stored.Data = dataErr.ErrorData()
+		}
+	}
+
+	return stored
+}
+
+func decodeError(stored *storedError) error {
+	if stored == nil {
+		return nil
+	}
+
+	if stored.JsonRpcError != nil {
+		return stored.JsonRpcError
+	}
+
+	if stored.Code != nil {
+		return &codecError{message: stored.Message, code: *stored.Code, data: stored.Data}
+	}
+
+	return errors.New(stored.Message)
+}
+
+func encodeMessage(msg Message) ([]byte, error) {
+	if msg.Req == nil {
+		return nil, fmt.Errorf("message without request")
+	}
+
+	stored := storedMessage{
+		Root:    msg.Root,
+		Parent:  msg.Parent,
+		Req:     &storedRequest{Id: msg.Req.id, Request: *msg.Req},
+		Status:  msg.Status,
+		Retries: msg.Retries,
+		History: msg.History,
+	}
+
+	if msg.Resp != nil {
+		stored.Resp = &storedResponse{
+			Id:         msg.Resp.Id,
+			ReturnData: msg.Resp.ReturnData,
+			Err:        encodeError(msg.Resp.Err),
+		}
+
+		if msg.Resp.Tx != nil {
+			tx, err := msg.Resp.Tx.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			stored.Resp.Tx = tx
+		}
+	}
+
+	if msg.Receipt != nil {
+		stored.Receipt = &storedReceipt{Id: msg.Receipt.Id, TxReceipt: msg.Receipt.TxReceipt, Status: msg.Receipt.Status}
+	}
+
+	return json.Marshal(stored)
+}
+
+func decodeMessage(data []byte) (Message, error) {
+	var stored storedMessage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return Message{}, err
+	}
+
+	if stored.Req == nil {
+		return Message{}, fmt.Errorf("message without request")
+	}
+
+	req := stored.Req.Request
+	req.id = stored.Req.Id
+
+	msg := Message{
+		Root:    stored.Root,
+		Parent:  stored.Parent,
+		Req:     &req,
+		Status:  stored.Status,
+		Retries: stored.Retries,
+		History: stored.History,
+	}
+
+	if stored.Resp != nil {
+		msg.Resp = &Response{
+			Id:         stored.Resp.Id,
+			ReturnData: stored.Resp.ReturnData,
+			Err:        decodeError(stored.Resp.Err),
+		}
+
+		if len(stored.Resp.Tx) > 0 {
+			tx := new(types.Transaction)
+			if err := tx.UnmarshalBinary(stored.Resp.Tx); err != nil {
+				return Message{}, err
+			}
+			msg.Resp.Tx = tx
+		}
+	}
+
+	if stored.Receipt != nil {
+		msg.Receipt = &Receipt{Id: stored.Receipt.Id, TxReceipt: stored.Receipt.TxReceipt, Status: stored.Receipt.Status}
+	}
+
+	return msg, nil
+}
@@ -1,6 +1,12 @@
 package message
 
-import "github.com/ethereum/go-ethereum"
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
 
 type ethBackend interface {
 	ethereum.ContractCaller
@@ -9,5 +15,8 @@ type ethBackend interface {
 	ethereum.TransactionReader
 	ethereum.PendingStateReader
 	ethereum.GasPricer
+	ethereum.GasPricer1559
 	ethereum.GasEstimator
+	ethereum.ChainIDReader
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
 }
@@ -0,0 +1,283 @@
+package message
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+type EventType uint8
+
+const (
+	EventSubmitted EventType = iota + 1
+	EventScheduled
+	EventQueued
+	EventNonceAssigned
+	EventInflight
+	// the tx of the msg was replaced with higher fees as not mined in time
+	EventReplaced
+	EventOnChain
+	EventFinalized
+	EventNonceReleased
+	EventExpired
+	EventCancelled
+	// the msg failed, e.g. its tx reverted on estimation or could not be sent
+	EventFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSubmitted:
+		return "submitted"
+	case EventScheduled:
+		return "scheduled"
+	case EventQueued:
+		return "queued"
+	case EventNonceAssigned:
+		return "nonceAssigned"
+	case EventInflight:
+		return "inflight"
+	case EventReplaced:
+		return "replaced"
+	case EventOnChain:
+		return "onChain"
+	case EventFinalized:
+		return "finalized"
+	case EventNonceReleased:
+		return "nonceReleased"
+	case EventExpired:
+		return "expired"
+	case EventCancelled:
+		return "cancelled"
+	case EventFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// StatusEvent returns the type of the event of msgs entering status.
+func StatusEvent(status MessageStatus) EventType {
+	switch status {
+	case MessageStatusSubmitted:
+		return EventSubmitted
+	case MessageStatusScheduled:
+		return EventScheduled
+	case MessageStatusQueued:
+		return EventQueued
+	case MessageStatusNonceAssigned:
+		return EventNonceAssigned
+	case MessageStatusInflight:
+		return EventInflight
+	case MessageStatusOnChain:
+		return EventOnChain
+	case MessageStatusFinalized:
+		return EventFinalized
+	case MessageStatusNonceReleased:
+		return EventNonceReleased
+	case MessageStatusExpired:
+		return EventExpired
+	case MessageStatusCancelled:
+		return EventCancelled
+	case MessageStatusFailed:
+		return EventFailed
+	default:
+		return 0
+	}
+}
+
+// Event of the lifecycle of a msg.
+type Event struct {
+	Type    EventType
+	MsgId   common.Hash
+	From    common.Address
+	Status  MessageStatus      // status of the msg after the event
+	Tx      *types.Transaction // the last tx sent for the msg, the replacement one for EventReplaced
+	Receipt *types.Receipt     // not nil if the tx is on chain
+	Err     error              // why the msg failed for EventFailed
+	Time    time.Time
+}
+
+// Final reports whether no more events follow for the msg. A msg cancelled is final once the tx
+// cancelling it is on chain, or if it was never broadcasted. The nonce of a msg failed to send is
+// released before it's retried, so it's only final if its tx was broadcasted and replaced by another msg.
+func (e Event) Final() bool {
+	switch e.Type {
+	case EventFinalized, EventExpired, EventFailed:
+		return true
+	case EventNonceReleased:
+		return e.Tx != nil
+	case EventCancelled:
+		return e.Tx == nil || e.Receipt != nil
+	default:
+		return false
+	}
+}
+
+// EventFilter matches events by msg, sender and type. Empty fields match any.
+type EventFilter struct {
+	MsgIds []common.Hash
+	From   []common.Address
+	Types  []EventType
+}
+
+func (f EventFilter) Match(e Event) bool {
+	return matchAny(f.MsgIds, e.MsgId) && matchAny(f.From, e.From) && matchAny(f.Types, e.Type)
+}
+
+func matchAny[T comparable](values []T, v T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// EventBus delivers the events of msgs to subscribers. Publishing never blocks: events are queued
+// per subscription and sent in order, so keep consuming the channels subscribed.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*eventSub]struct{})}
+}
+
+// Subscribe sends the events matching filter to ch until unsubscribed.
+func (b *EventBus) Subscribe(filter EventFilter, ch chan<- Event) event.Subscription {
+	return b.subscribe(filter, ch, false)
+}
+
+// SubscribeMsg sends the events of msgId to ch until it's final, then the subscription ends,
+// closing its Err channel. Events published before subscribing are not sent.
+func (b *EventBus) SubscribeMsg(msgId common.Hash, ch chan<- Event) event.Subscription {
+	return b.subscribe(EventFilter{MsgIds: []common.Hash{msgId}}, ch, true)
+}
+
+func (b *EventBus) subscribe(filter EventFilter, ch chan<- Event, untilFinal bool) event.Subscription {
+	sub := &eventSub{
+		bus:        b,
+		filter:     filter,
+		untilFinal: untilFinal,
+		ch:         ch,
+		notify:     make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+		err:        make(chan error),
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.loop()
+	return sub
+}
+
+// HasSubscribers reports whether events are published to anyone.
+func (b *EventBus) HasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subs) > 0
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+
+		final := sub.untilFinal && e.Final()
+		sub.push(e, final)
+		if final {
+			delete(b.subs, sub)
+		}
+	}
+}
+
+func (b *EventBus) remove(sub *eventSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subs, sub)
+}
+
+type eventSub struct {
+	bus        *EventBus
+	filter     EventFilter
+	untilFinal bool
+	ch         chan<- Event
+
+	mu     sync.Mutex
+	queue  []Event
+	done   bool
+	notify chan struct{}
+
+	quit chan struct{}
+	err  chan error
+	once sync.Once
+}
+
+func (s *eventSub) push(e Event, done bool) {
+	s.mu.Lock()
+	s.queue = append(s.queue, e)
+	s.done = done
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *eventSub) loop() {
+	for {
+		s.mu.Lock()
+		queue, done := s.queue, s.done
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case s.ch <- e:
+			case <-s.quit:
+				return
+			}
+		}
+
+		if done {
+			s.Unsubscribe()
+			return
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *eventSub) Err() <-chan error {
+	return s.err
+}
+
+func (s *eventSub) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.remove(s)
+		close(s.quit)
+		close(s.err)
+	})
+}
@@ -0,0 +1,79 @@
+package message
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func receiveEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func TestEventBus_Subscribe(t *testing.T) {
+	bus := NewEventBus()
+	from := common.HexToAddress("0x1")
+
+	ch := make(chan Event)
+	sub := bus.Subscribe(EventFilter{From: []common.Address{from}, Types: []EventType{EventOnChain, EventFailed}}, ch)
+	defer sub.Unsubscribe()
+
+	// published while the subscriber is busy, delivered in order
+	bus.Publish(Event{Type: EventInflight, MsgId: common.HexToHash("0x1"), From: from})
+	bus.Publish(Event{Type: EventOnChain, MsgId: common.HexToHash("0x2"), From: common.HexToAddress("0x2")})
+	bus.Publish(Event{Type: EventOnChain, MsgId: common.HexToHash("0x3"), From: from})
+	bus.Publish(Event{Type: EventFailed, MsgId: common.HexToHash("0x4"), From: from})
+
+	if e := receiveEvent(t, ch); e.MsgId != common.HexToHash("0x3") {
+		t.Fatalf("want event of 0x3, got %v", e.MsgId)
+	}
+	if e := receiveEvent(t, ch); e.MsgId != common.HexToHash("0x4") {
+		t.Fatalf("want event of 0x4, got %v", e.MsgId)
+	}
+
+	sub.Unsubscribe()
+	if _, ok := <-sub.Err(); ok {
+		t.Fatal("want err channel closed")
+	}
+	if bus.HasSubscribers() {
+		t.Fatal("want subscription removed")
+	}
+}
+
+func TestEventBus_SubscribeMsg(t *testing.T) {
+	bus := NewEventBus()
+	msgId := common.HexToHash("0x1")
+
+	ch := make(chan Event, 10)
+	sub := bus.SubscribeMsg(msgId, ch)
+
+	bus.Publish(Event{Type: EventInflight, MsgId: msgId})
+	bus.Publish(Event{Type: EventInflight, MsgId: common.HexToHash("0x2")})
+	// the nonce of a msg not sent yet is released before retries
+	bus.Publish(Event{Type: EventNonceReleased, MsgId: msgId})
+	bus.Publish(Event{Type: EventFinalized, MsgId: msgId})
+	bus.Publish(Event{Type: EventFinalized, MsgId: msgId})
+
+	select {
+	case <-sub.Err():
+	case <-time.After(time.Second):
+		t.Fatal("want subscription ended once msg finalized")
+	}
+
+	var types []EventType
+	for len(ch) > 0 {
+		types = append(types, (<-ch).Type)
+	}
+	if len(types) != 3 || types[0] != EventInflight || types[1] != EventNonceReleased || types[2] != EventFinalized {
+		t.Fatalf("want events of msg until final, got %v", types)
+	}
+}
@@ -0,0 +1,248 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+type finalityBackend interface {
+	ethereum.BlockNumberReader
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+type FinalityConfig struct {
+	// Block tag considered final, rpc.FinalizedBlockNumber or rpc.SafeBlockNumber.
+	// Confirmations are used instead if 0 or if the node does not support the tag.
+	BlockTag rpc.BlockNumber
+	// Blocks on top of the one including the tx before it's considered final when not using tags.
+	Confirmations uint64
+	// How often msgs on chain are checked.
+	PollInterval time.Duration
+}
+
+func DefaultFinalityConfig() FinalityConfig {
+	return FinalityConfig{
+		BlockTag:      rpc.FinalizedBlockNumber,
+		Confirmations: 12,
+		PollInterval:  consts.RetryInterval,
+	}
+}
+
+// ReceiptHandler is called on every step of a msg after it was mined.
+type ReceiptHandler func(receipt Receipt)
+
+// FinalityTracker moves msgs on chain to finalized, and back to inflight if their tx
+// was reorged out, so that they're protected again.
+type FinalityTracker struct {
+	backend     finalityBackend
+	storage     Storage
+	broadcaster Broadcaster
+
+	mu        sync.Mutex
+	config    FinalityConfig
+	onReceipt ReceiptHandler
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func NewFinalityTracker(backend finalityBackend, storage Storage, broadcaster Broadcaster, config FinalityConfig) *FinalityTracker {
+	return &FinalityTracker{
+		backend:     backend,
+		storage:     storage,
+		broadcaster: broadcaster,
+		config:      config,
+		closeCh:     make(chan struct{}),
+	}
+}
+
+func (t *FinalityTracker) SetConfig(config FinalityConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.config = config
+}
+
+func (t *FinalityTracker) SetReceiptHandler(handler ReceiptHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onReceipt = handler
+}
+
+// Run tracks msgs until Close is called. It needs a storage implementing StorageLister.
+func (t *FinalityTracker) Run(ctx context.Context) {
+	lister, ok := t.storage.(StorageLister)
+	if !ok {
+		log.Warn("msg storage does not support listing msgs, finality of msgs is not tracked")
+		return
+	}
+
+	for {
+		t.mu.Lock()
+		interval := t.config.PollInterval
+		t.mu.Unlock()
+
+		select {
+		case <-t.closeCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if err := t.poll(ctx, lister); err != nil {
+			log.Warn("track finality of msgs failed", "err", err)
+		}
+	}
+}
+
+func (t *FinalityTracker) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closeCh)
+	})
+}
+
+func (t *FinalityTracker) poll(ctx context.Context, lister StorageLister) error {
+	msgIds, err := lister.MsgIdsByStatus(MessageStatusOnChain)
+	if err != nil {
+		return err
+	}
+
+	if len(msgIds) == 0 {
+		return nil
+	}
+
+	finalized, err := t.finalizedBlock(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, msgId := range msgIds {
+		if err := t.track(ctx, msgId, finalized); err != nil {
+			log.Warn("track finality of msg failed", "msgId", msgId.Hex(), "err", err)
+		}
+	}
+
+	return nil
+}
+
+// finalizedBlock returns the highest block number considered final.
+func (t *FinalityTracker) finalizedBlock(ctx context.Context) (uint64, error) {
+	t.mu.Lock()
+	config := t.config
+	t.mu.Unlock()
+
+	if config.BlockTag < 0 {
+		head, err := t.backend.HeaderByNumber(ctx, big.NewInt(config.BlockTag.Int64()))
+		if err == nil {
+			return head.Number.Uint64(), nil
+		}
+		log.Debug("block tag not supported, fall back to confirmations", "tag", config.BlockTag, "err", err)
+	}
+
+	latest, err := t.backend.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if latest < config.Confirmations {
+		return 0, nil
+	}
+
+	return latest - config.Confirmations, nil
+}
+
+func (t *FinalityTracker) track(ctx context.Context, msgId common.Hash, finalized uint64) error {
+	msg, err := t.storage.GetMsg(msgId)
+	if err != nil {
+		return err
+	}
+
+	if msg.Receipt == nil || msg.Receipt.TxReceipt == nil {
+		return nil
+	}
+
+	receipt, err := t.backend.TransactionReceipt(ctx, msg.Receipt.TxReceipt.TxHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return t.reorged(ctx, msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The block including the tx may have been replaced, the receipt returned is the canonical one.
+	head, err := t.backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return err
+	}
+	if head.Hash() != receipt.BlockHash {
+		// The node is catching up with a reorg, check it again later.
+		return nil
+	}
+
+	if receipt.BlockHash != msg.Receipt.TxReceipt.BlockHash {
+		log.Info("msg tx was included in another block after a reorg", "msgId", msgId.Hex(),
+			"txHash", receipt.TxHash.Hex(), "block", receipt.BlockNumber, "blockHash", receipt.BlockHash.Hex())
+
+		updated := Receipt{Id: msgId, TxReceipt: receipt, Status: MessageStatusOnChain}
+		if err := t.storage.UpdateReceipt(msgId, updated); err != nil {
+			return err
+		}
+		t.emit(updated)
+	}
+
+	if receipt.BlockNumber.Uint64() > finalized {
+		return nil
+	}
+
+	finalizedReceipt := Receipt{Id: msgId, TxReceipt: receipt, Status: MessageStatusFinalized}
+	if err := t.storage.UpdateReceipt(msgId, finalizedReceipt); err != nil {
+		return err
+	}
+	if err := t.storage.UpdateMsgStatus(msgId, MessageStatusFinalized); err != nil {
+		return err
+	}
+
+	log.Debug("msg finalized", "msgId", msgId.Hex(), "txHash", receipt.TxHash.Hex(), "block", receipt.BlockNumber)
+	t.emit(finalizedReceipt)
+
+	return nil
+}
+
+func (t *FinalityTracker) reorged(ctx context.Context, msg Message) error {
+	log.Warn("msg tx was reorged out, protect it again", "msgId", msg.Id().Hex(), "txHash", msg.Receipt.TxReceipt.TxHash.Hex(),
+		"block", msg.Receipt.TxReceipt.BlockNumber, "blockHash", msg.Receipt.TxReceipt.BlockHash.Hex())
+
+	msg.Receipt = nil
+	msg.Status = MessageStatusInflight
+	if err := t.storage.UpdateMsg(msg); err != nil {
+		return err
+	}
+
+	t.emit(Receipt{Id: msg.Id(), Status: MessageStatusInflight})
+	t.broadcaster.ProtectMsg(ctx, msg.Id())
+
+	return nil
+}
+
+func (t *FinalityTracker) emit(receipt Receipt) {
+	t.mu.Lock()
+	onReceipt := t.onReceipt
+	t.mu.Unlock()
+
+	if onReceipt != nil {
+		onReceipt(receipt)
+	}
+}
@@ -0,0 +1,236 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type fakeFinalityBackend struct {
+	latest    uint64
+	finalized *uint64
+	receipts  map[common.Hash]*types.Receipt
+	headers   map[uint64]*types.Header
+}
+
+func (b *fakeFinalityBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	return b.latest, nil
+}
+
+func (b *fakeFinalityBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, ok := b.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (b *fakeFinalityBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number.Sign() < 0 {
+		if b.finalized == nil {
+			return nil, errors.New("finalized block not supported")
+		}
+		return &types.Header{Number: new(big.Int).SetUint64(*b.finalized)}, nil
+	}
+
+	head, ok := b.headers[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return head, nil
+}
+
+// addBlock makes header the canonical block at its height and includes the tx in it.
+func (b *fakeFinalityBackend) addBlock(header *types.Header, txHash common.Hash) *types.Receipt {
+	b.headers[header.Number.Uint64()] = header
+	receipt := &types.Receipt{
+		TxHash:      txHash,
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+	}
+	b.receipts[txHash] = receipt
+	return receipt
+}
+
+type fakeBroadcaster struct {
+	Broadcaster
+	protected []common.Hash
+}
+
+func (b *fakeBroadcaster) ProtectMsg(ctx context.Context, msgId common.Hash) {
+	b.protected = append(b.protected, msgId)
+}
+
+func newTestFinalityTracker(t *testing.T, config FinalityConfig) (*FinalityTracker, *fakeFinalityBackend, *MemoryStorage, *fakeBroadcaster, *[]Receipt) {
+	backend := &fakeFinalityBackend{
+		receipts: make(map[common.Hash]*types.Receipt),
+		headers:  make(map[uint64]*types.Header),
+	}
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	broadcaster := &fakeBroadcaster{}
+
+	tracker := NewFinalityTracker(backend, storage, broadcaster, config)
+	receipts := &[]Receipt{}
+	tracker.SetReceiptHandler(func(receipt Receipt) {
+		*receipts = append(*receipts, receipt)
+	})
+
+	return tracker, backend, storage, broadcaster, receipts
+}
+
+func addOnChainMsg(t *testing.T, storage *MemoryStorage, msgId common.Hash, receipt *types.Receipt) {
+	if err := storage.AddMsg(Request{id: msgId}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateReceipt(msgId, Receipt{Id: msgId, TxReceipt: receipt, Status: MessageStatusOnChain}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateMsgStatus(msgId, MessageStatusOnChain); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertMsgStatus(t *testing.T, storage *MemoryStorage, msgId common.Hash, want MessageStatus) Message {
+	msg, err := storage.GetMsg(msgId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != want {
+		t.Fatalf("msg status: want %v, got %v", want, msg.Status)
+	}
+	return msg
+}
+
+func TestFinalityTracker_BlockTag(t *testing.T) {
+	tracker, backend, storage, _, receipts := newTestFinalityTracker(t, DefaultFinalityConfig())
+	ctx := context.Background()
+
+	msgId := common.HexToHash("0x1")
+	receipt := backend.addBlock(&types.Header{Number: big.NewInt(10)}, common.HexToHash("0xa"))
+	addOnChainMsg(t, storage, msgId, receipt)
+
+	backend.latest = 100
+	finalized := uint64(9)
+	backend.finalized = &finalized
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	assertMsgStatus(t, storage, msgId, MessageStatusOnChain)
+
+	finalized = 10
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	msg := assertMsgStatus(t, storage, msgId, MessageStatusFinalized)
+	if msg.Receipt.Status != MessageStatusFinalized {
+		t.Fatalf("receipt status: want %v, got %v", MessageStatusFinalized, msg.Receipt.Status)
+	}
+
+	if len(*receipts) != 1 || (*receipts)[0].Status != MessageStatusFinalized {
+		t.Fatalf("want one finalized receipt emitted, got %+v", *receipts)
+	}
+}
+
+func TestFinalityTracker_Confirmations(t *testing.T) {
+	tracker, backend, storage, _, receipts := newTestFinalityTracker(t, FinalityConfig{
+		BlockTag:      rpc.FinalizedBlockNumber,
+		Confirmations: 3,
+	})
+	ctx := context.Background()
+
+	msgId := common.HexToHash("0x1")
+	receipt := backend.addBlock(&types.Header{Number: big.NewInt(10)}, common.HexToHash("0xa"))
+	addOnChainMsg(t, storage, msgId, receipt)
+
+	// The backend does not support the finalized tag.
+	backend.latest = 12
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	assertMsgStatus(t, storage, msgId, MessageStatusOnChain)
+
+	backend.latest = 13
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	assertMsgStatus(t, storage, msgId, MessageStatusFinalized)
+
+	if len(*receipts) != 1 || (*receipts)[0].Status != MessageStatusFinalized {
+		t.Fatalf("want one finalized receipt emitted, got %+v", *receipts)
+	}
+}
+
+func TestFinalityTracker_Reorged(t *testing.T) {
+	tracker, backend, storage, broadcaster, receipts := newTestFinalityTracker(t, DefaultFinalityConfig())
+	ctx := context.Background()
+
+	msgId := common.HexToHash("0x1")
+	txHash := common.HexToHash("0xa")
+	receipt := backend.addBlock(&types.Header{Number: big.NewInt(10)}, txHash)
+	addOnChainMsg(t, storage, msgId, receipt)
+
+	// The tx was reorged out.
+	delete(backend.receipts, txHash)
+	backend.latest = 11
+	finalized := uint64(0)
+	backend.finalized = &finalized
+
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := assertMsgStatus(t, storage, msgId, MessageStatusInflight)
+	if msg.Receipt != nil {
+		t.Fatalf("want receipt removed, got %+v", msg.Receipt)
+	}
+
+	if len(broadcaster.protected) != 1 || broadcaster.protected[0] != msgId {
+		t.Fatalf("want msg protected again, got %v", broadcaster.protected)
+	}
+
+	if len(*receipts) != 1 || (*receipts)[0].Status != MessageStatusInflight || (*receipts)[0].TxReceipt != nil {
+		t.Fatalf("want one inflight receipt emitted, got %+v", *receipts)
+	}
+}
+
+func TestFinalityTracker_IncludedInAnotherBlock(t *testing.T) {
+	tracker, backend, storage, broadcaster, receipts := newTestFinalityTracker(t, DefaultFinalityConfig())
+	ctx := context.Background()
+
+	msgId := common.HexToHash("0x1")
+	txHash := common.HexToHash("0xa")
+	receipt := backend.addBlock(&types.Header{Number: big.NewInt(10)}, txHash)
+	addOnChainMsg(t, storage, msgId, receipt)
+
+	// The tx was included again in a sibling block.
+	newReceipt := backend.addBlock(&types.Header{Number: big.NewInt(10), Extra: []byte("reorg")}, txHash)
+	backend.latest = 11
+	finalized := uint64(0)
+	backend.finalized = &finalized
+
+	if err := tracker.poll(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := assertMsgStatus(t, storage, msgId, MessageStatusOnChain)
+	if msg.Receipt.TxReceipt.BlockHash != newReceipt.BlockHash {
+		t.Fatalf("want receipt in block %v, got %v", newReceipt.BlockHash, msg.Receipt.TxReceipt.BlockHash)
+	}
+
+	if len(broadcaster.protected) != 0 {
+		t.Fatalf("want msg not protected, got %v", broadcaster.protected)
+	}
+
+	if len(*receipts) != 1 || (*receipts)[0].Status != MessageStatusOnChain {
+		t.Fatalf("want one on chain receipt emitted, got %+v", *receipts)
+	}
+}
@@ -0,0 +1,104 @@
+package message
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HistoryRecord records a change of a msg: its status, the tx sent for it, e.g. a replacement,
+// or the error it failed with.
+type HistoryRecord struct {
+	Time time.Time     `json:"time"`
+	From MessageStatus `json:"from,omitempty"` // 0 when the msg was added
+	To   MessageStatus `json:"to"`
+	// the tx of the msg after the change, if any
+	TxHash   *common.Hash `json:"txHash,omitempty"`
+	Nonce    *uint64      `json:"nonce,omitempty"`
+	GasPrice *big.Int     `json:"gasPrice,omitempty"` // gas price of legacy txs, fee cap of dynamic fee txs
+	Err      string       `json:"err,omitempty"`
+}
+
+func newHistoryRecord(from MessageStatus, msg Message) HistoryRecord {
+	record := HistoryRecord{Time: time.Now(), From: from, To: msg.Status}
+
+	if msg.Resp != nil {
+		if tx := msg.Resp.Tx; tx != nil {
+			hash, nonce := tx.Hash(), tx.Nonce()
+			record.TxHash = &hash
+			record.Nonce = &nonce
+			record.GasPrice = tx.GasFeeCap()
+		}
+		if msg.Resp.Err != nil {
+			record.Err = msg.Resp.Err.Error()
+		}
+	}
+
+	return record
+}
+
+// withHistory returns msg updated from old, keeping the history stored and recording the change if any.
+// The history is only appended to by storages, whatever the history of msg is.
+func withHistory(old Message, msg Message) Message {
+	msg.History = old.History
+
+	if old.Status != msg.Status || txHash(old) != txHash(msg) || respErr(old) != respErr(msg) {
+		msg.History = append(msg.History[:len(msg.History):len(msg.History)], newHistoryRecord(old.Status, msg))
+	}
+
+	return msg
+}
+
+// sentTxHashes returns the hashes of the txs sent for msg, oldest first, e.g. the ones replaced with higher fees.
+func (m *Message) sentTxHashes() []common.Hash {
+	var hashes []common.Hash
+	seen := make(map[common.Hash]bool)
+	add := func(hash common.Hash) {
+		if hash != (common.Hash{}) && !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+
+	for _, record := range m.History {
+		if record.TxHash != nil {
+			add(*record.TxHash)
+		}
+	}
+	add(txHash(*m))
+
+	return hashes
+}
+
+// cancelledBy reports whether txHash is a self-transfer sent for cancelling msg.
+func (m *Message) cancelledBy(txHash common.Hash) bool {
+	if m.Status != MessageStatusCancelled {
+		return false
+	}
+	if m.Resp != nil && m.Resp.Tx != nil && m.Resp.Tx.Hash() == txHash {
+		return true
+	}
+
+	for _, record := range m.History {
+		if record.To == MessageStatusCancelled && record.TxHash != nil && *record.TxHash == txHash {
+			return true
+		}
+	}
+
+	return false
+}
+
+func txHash(msg Message) common.Hash {
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		return common.Hash{}
+	}
+	return msg.Resp.Tx.Hash()
+}
+
+func respErr(msg Message) string {
+	if msg.Resp == nil || msg.Resp.Err == nil {
+		return ""
+	}
+	return msg.Resp.Err.Error()
+}
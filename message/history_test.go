@@ -0,0 +1,85 @@
+package message
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStorage_History(t *testing.T) {
+	memory, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, redis := newTestRedisStorage(t)
+	_, sqlite := newTestSQLStorage(t)
+
+	for name, s := range map[string]Storage{"memory": memory, "redis": redis, "sqlite": sqlite} {
+		t.Run(name, func(t *testing.T) {
+			req := (&Request{From: common.HexToAddress("0x1")}).SetRandomId()
+			id := req.Id()
+			if err := s.AddMsg(*req); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.UpdateMsgStatus(id, MessageStatusNonceAssigned); err != nil {
+				t.Fatal(err)
+			}
+			// unchanged, not recorded
+			if err := s.UpdateMsgStatus(id, MessageStatusNonceAssigned); err != nil {
+				t.Fatal(err)
+			}
+
+			tx := newTestSignedTx(t, 7)
+			if err := s.UpdateResponse(id, Response{Id: id, Tx: tx}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.UpdateMsgStatus(id, MessageStatusInflight); err != nil {
+				t.Fatal(err)
+			}
+
+			// replaced by a msg read before, its history is kept anyway
+			msg, err := s.GetMsg(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replacement := newTestSignedTx(t, 7)
+			msg.Resp = &Response{Id: id, Tx: replacement, Err: errors.New("replacement underpriced")}
+			msg.History = nil
+			if err := s.UpdateMsg(msg); err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err = s.GetMsg(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			history := msg.History
+			if len(history) != 5 {
+				t.Fatalf("want 5 records, got %+v", history)
+			}
+
+			transitions := [][2]MessageStatus{
+				{0, MessageStatusSubmitted},
+				{MessageStatusSubmitted, MessageStatusNonceAssigned},
+				{MessageStatusNonceAssigned, MessageStatusNonceAssigned},
+				{MessageStatusNonceAssigned, MessageStatusInflight},
+				{MessageStatusInflight, MessageStatusInflight},
+			}
+			for i, transition := range transitions {
+				if history[i].From != transition[0] || history[i].To != transition[1] {
+					t.Fatalf("want record %v from %v to %v, got %+v", i, transition[0], transition[1], history[i])
+				}
+			}
+
+			if history[2].TxHash == nil || *history[2].TxHash != tx.Hash() || *history[2].Nonce != 7 || history[2].GasPrice.Cmp(tx.GasFeeCap()) != 0 {
+				t.Fatalf("want tx recorded, got %+v", history[2])
+			}
+			if *history[4].TxHash != replacement.Hash() || history[4].Err != "replacement underpriced" {
+				t.Fatalf("want replacement recorded, got %+v", history[4])
+			}
+		})
+	}
+}
@@ -23,7 +23,10 @@ type ScheduleManager interface {
 	ReplaceMsgWithHigherGasPrice(ctx context.Context, msgId common.Hash) (resp Response)
 	// replace old msg with same nonce.
 	// mark old one as MessageStatusNonceReleased
-	// ReplaceMsg(ctx context.Context, msgId common.Hash, newMsg Request) (resp Response)
+	ReplaceMsg(ctx context.Context, msgId common.Hash, newMsg Request) (resp Response)
+	// replace the tx of msg broadcasted with a zero-value self-transfer with same nonce.
+	// mark it as MessageStatusCancelled
+	CancelMsg(ctx context.Context, msgId common.Hash) (resp Response)
 
 	NewTransaction(ctx context.Context, msg Request) (*types.Transaction, error)
 	MessageToTransactOpts(ctx context.Context, msg Request) (*bind.TransactOpts, error)
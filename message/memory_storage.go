@@ -9,8 +9,13 @@ import (
 )
 
 var _ Storage = &MemoryStorage{}
+var _ StorageLister = &MemoryStorage{}
+var _ StorageQuerier = &MemoryStorage{}
+var _ StorageStatusSwapper = &MemoryStorage{}
 
 type MemoryStorage struct {
+	// serializes updates, so that none of them is lost
+	mu    sync.Mutex
 	store sync.Map
 }
 
@@ -20,14 +25,15 @@ func NewMemoryStorage() (*MemoryStorage, error) {
 
 func (s *MemoryStorage) AddMsg(req Request) error {
 	log.Debug("MemoryStorage AddMsg", "req", req)
-	if s.HasMsg(req.id) {
-		return fmt.Errorf("duplicated msg not allowed")
-	}
-
-	s.store.Store(req.id, Message{
+	msg := Message{
 		Req:    &req,
 		Status: MessageStatusSubmitted,
-	})
+	}
+	msg.History = []HistoryRecord{newHistoryRecord(0, msg)}
+
+	if _, loaded := s.store.LoadOrStore(req.id, msg); loaded {
+		return fmt.Errorf("duplicated msg not allowed")
+	}
 	return nil
 }
 
@@ -47,50 +53,49 @@ func (s *MemoryStorage) GetMsg(msgId common.Hash) (Message, error) {
 }
 
 func (s *MemoryStorage) UpdateMsg(msg Message) error {
-	s.store.Store(msg.Req.id, msg)
-	return nil
+	return s.update(msg.Req.id, func(*Message) (Message, error) {
+		return msg, nil
+	})
 }
 
 func (s *MemoryStorage) UpdateResponse(msgId common.Hash, resp Response) error {
 	log.Debug("MemoryStorage UpdateResponse", "msgId", msgId.Hex(), "resp", resp)
 
-	msg, err := s.GetMsg(msgId)
-	if err != nil {
-		return err
-	}
-
-	if msg.Resp != nil {
-		panic("same msg not allowed updating response twice" + msgId.Hex())
-	}
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		if msg.Resp != nil {
+			panic("same msg not allowed updating response twice" + msgId.Hex())
+		}
 
-	msg.Resp = &resp
-	return s.UpdateMsg(msg)
+		msg.Resp = &resp
+		return *msg, nil
+	})
 }
 
 func (s *MemoryStorage) UpdateReceipt(msgId common.Hash, receipt Receipt) error {
 	log.Debug("MemoryStorage UpdateReceipt", "msgId", msgId.Hex(),
 		"txHash", receipt.TxReceipt.TxHash.Hex(), "receipt", receipt)
 
-	msg, err := s.GetMsg(msgId)
-	if err != nil {
-		return err
-	}
-
-	msg.Receipt = &receipt
-	return s.UpdateMsg(msg)
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Receipt = &receipt
+		return *msg, nil
+	})
 }
 
 func (s *MemoryStorage) UpdateMsgStatus(msgId common.Hash, status MessageStatus) error {
 	log.Debug("MemoryStorage UpdateMsgStatus", "msgId", msgId.Hex(), "status", status)
 
-	msg, err := s.GetMsg(msgId)
-	if err != nil {
-		return err
-	}
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Status = status
+		return *msg, nil
+	})
+}
 
-	msg.Status = status
+func (s *MemoryStorage) SwapMsgStatus(msgId common.Hash, from []MessageStatus, status MessageStatus) error {
+	log.Debug("MemoryStorage SwapMsgStatus", "msgId", msgId.Hex(), "from", from, "status", status)
 
-	return s.UpdateMsg(msg)
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		return swapStatus(msg, from, status)
+	})
 }
 
 func (s *MemoryStorage) GetNonce(msgId common.Hash) (nonce uint64, err error) {
@@ -105,3 +110,44 @@ func (s *MemoryStorage) GetNonce(msgId common.Hash) (nonce uint64, err error) {
 
 	return msg.Resp.Tx.Nonce(), nil
 }
+
+func (s *MemoryStorage) MsgIdsByStatus(status MessageStatus) (msgIds []common.Hash, err error) {
+	s.store.Range(func(key, value any) bool {
+		if value.(Message).Status == status {
+			msgIds = append(msgIds, key.(common.Hash))
+		}
+		return true
+	})
+
+	return msgIds, nil
+}
+
+func (s *MemoryStorage) QueryMsgs(query MsgQuery) ([]Message, error) {
+	var msgs []Message
+	s.store.Range(func(key, value any) bool {
+		msgs = append(msgs, value.(Message))
+		return true
+	})
+
+	return queryMsgs(msgs, query), nil
+}
+
+// update applies fn to the stored msg, recording the change in its history.
+func (s *MemoryStorage) update(msgId common.Hash, fn func(msg *Message) (Message, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.GetMsg(msgId)
+	if err != nil {
+		return err
+	}
+	prev := old
+
+	msg, err := fn(&old)
+	if err != nil {
+		return err
+	}
+
+	s.store.Store(msgId, withHistory(prev, msg))
+	return nil
+}
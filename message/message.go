@@ -1,6 +1,8 @@
 package message
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/uuid"
+	"github.com/ivanzzeth/ethclient/gas"
 )
 
 type Message struct {
@@ -17,6 +20,9 @@ type Message struct {
 	Resp    *Response // not nil if inflight
 	Receipt *Receipt  // not nil if on-chain
 	Status  MessageStatus
+	Retries []RetryRecord // retries of sends failed
+	// changes of the msg, appended by the storage only
+	History []HistoryRecord
 }
 
 func (m *Message) Id() common.Hash {
@@ -30,7 +36,10 @@ type Request struct {
 	Value                 *big.Int        // amount of wei sent along with the call
 	Gas                   uint64          // if 0, the call executes with near-infinite gas
 	GasOnEstimationFailed *uint64         // how much gas you wanna provide when the msg estimation failed. As much as possible, so you can debug on-chain
-	GasPrice              *big.Int        // wei <-> gas exchange ratio
+	GasPrice              *big.Int        // wei <-> gas exchange ratio, a legacy transaction is sent if set
+	GasFeeCap             *big.Int        // EIP-1559 fee cap per gas, only used on chains with London activated
+	GasTipCap             *big.Int        // EIP-1559 tip per gas, only used on chains with London activated
+	Urgency               gas.Urgency     // selects the fees suggested by the gas oracle for fees not set above
 	Data                  []byte          // input data, usually an ABI-encoded contract method invocation
 
 	AccessList types.AccessList // EIP-2930 access list.
@@ -53,11 +62,46 @@ const (
 	MessageStatusInflight // Broadcasted but not on chain
 	MessageStatusOnChain
 	MessageStatusFinalized
-	// it was broadcasted but not included on-chain until timeout, so the nonce was released
+	// it was replaced by another msg reusing its nonce, see Manager.ReplaceMsg
 	MessageStatusNonceReleased
 	MessageStatusExpired
+	// it was cancelled before being broadcasted, or its tx was replaced by a self-transfer
+	MessageStatusCancelled
+	// it failed to be sent, so its nonce was released
+	MessageStatusFailed
 )
 
+var ErrMsgCancelled = errors.New("msg cancelled")
+
+func (s MessageStatus) String() string {
+	switch s {
+	case MessageStatusSubmitted:
+		return "submitted"
+	case MessageStatusScheduled:
+		return "scheduled"
+	case MessageStatusQueued:
+		return "queued"
+	case MessageStatusNonceAssigned:
+		return "nonceAssigned"
+	case MessageStatusInflight:
+		return "inflight"
+	case MessageStatusOnChain:
+		return "onChain"
+	case MessageStatusFinalized:
+		return "finalized"
+	case MessageStatusNonceReleased:
+		return "nonceReleased"
+	case MessageStatusExpired:
+		return "expired"
+	case MessageStatusCancelled:
+		return "cancelled"
+	case MessageStatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
 type Response struct {
 	Id         common.Hash
 	Tx         *types.Transaction
@@ -67,7 +111,8 @@ type Response struct {
 
 type Receipt struct {
 	Id        common.Hash
-	TxReceipt *types.Receipt
+	TxReceipt *types.Receipt // nil if the tx was reorged out
+	Status    MessageStatus  // status of the msg when the receipt was emitted
 }
 
 func AssignMessageId(msg *Request) *Request {
@@ -122,6 +167,7 @@ func (q *Request) CopyWithoutId() *Request {
 	var (
 		gasOnEstimationFailed *uint64
 		value, gasPrice       *big.Int
+		gasFeeCap, gasTipCap  *big.Int
 	)
 
 	if q.GasOnEstimationFailed != nil {
@@ -137,6 +183,14 @@ func (q *Request) CopyWithoutId() *Request {
 		gasPrice = big.NewInt(0).Set(q.GasPrice)
 	}
 
+	if q.GasFeeCap != nil {
+		gasFeeCap = big.NewInt(0).Set(q.GasFeeCap)
+	}
+
+	if q.GasTipCap != nil {
+		gasTipCap = big.NewInt(0).Set(q.GasTipCap)
+	}
+
 	req := Request{
 		From:                  q.From,
 		To:                    q.To,
@@ -144,6 +198,9 @@ func (q *Request) CopyWithoutId() *Request {
 		Gas:                   q.Gas,
 		GasOnEstimationFailed: gasOnEstimationFailed,
 		GasPrice:              gasPrice,
+		GasFeeCap:             gasFeeCap,
+		GasTipCap:             gasTipCap,
+		Urgency:               q.Urgency,
 		Data:                  q.Data,
 		AccessList:            q.AccessList,
 		SimulationOn:          q.SimulationOn,
@@ -0,0 +1,115 @@
+package message
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+type nonceGapFillerBackend interface {
+	FillNonceGaps(ctx context.Context, account common.Address) ([]*types.Transaction, error)
+}
+
+// NonceGapFiller periodically fills the nonce gaps of the accounts sending msgs with no-op txs,
+// see SimpleManager.FillNonceGaps. Nonces released are reused by the next msgs first,
+// so only gaps left behind by accounts not sending any more msgs are filled.
+// It's disabled until an interval is set, as the txs it sends cancel the ones the node
+// does not know about, e.g. sent through another node.
+type NonceGapFiller struct {
+	backend nonceGapFillerBackend
+
+	mu       sync.Mutex
+	interval time.Duration
+	accounts map[common.Address]struct{}
+	updated  chan struct{}
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewNonceGapFiller(backend nonceGapFillerBackend) *NonceGapFiller {
+	return &NonceGapFiller{
+		backend:  backend,
+		accounts: make(map[common.Address]struct{}),
+		updated:  make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+	}
+}
+
+// SetInterval sets how often nonce gaps are looked for, 0 disables filling them.
+// A gap is only filled once found twice in a row, so it stays open for at least one interval.
+func (f *NonceGapFiller) SetInterval(interval time.Duration) {
+	f.mu.Lock()
+	f.interval = interval
+	f.mu.Unlock()
+
+	select {
+	case f.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Track adds account to the ones whose nonce gaps are filled.
+func (f *NonceGapFiller) Track(account common.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.accounts[account] = struct{}{}
+}
+
+func (f *NonceGapFiller) Run(ctx context.Context) {
+	for {
+		f.mu.Lock()
+		interval := f.interval
+		f.mu.Unlock()
+
+		// Disabled until an interval is set.
+		var tick <-chan time.Time
+		if interval > 0 {
+			tick = time.After(interval)
+		}
+
+		select {
+		case <-f.closeCh:
+			return
+		case <-ctx.Done():
+			return
+		case <-f.updated:
+			continue
+		case <-tick:
+		}
+
+		f.fill(ctx)
+	}
+}
+
+func (f *NonceGapFiller) Close() {
+	f.closeOnce.Do(func() {
+		close(f.closeCh)
+	})
+}
+
+func (f *NonceGapFiller) fill(ctx context.Context) {
+	f.mu.Lock()
+	accounts := make([]common.Address, 0, len(f.accounts))
+	for account := range f.accounts {
+		accounts = append(accounts, account)
+	}
+	f.mu.Unlock()
+
+	for _, account := range accounts {
+		txs, err := f.backend.FillNonceGaps(ctx, account)
+		if err != nil {
+			log.Warn("fill nonce gaps failed", "account", account.Hex(), "err", err)
+			continue
+		}
+
+		if len(txs) > 0 {
+			log.Info("nonce gaps filled", "account", account.Hex(), "txs", len(txs))
+		}
+	}
+}
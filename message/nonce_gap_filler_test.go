@@ -0,0 +1,47 @@
+package message
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type countingGapBackend struct {
+	calls atomic.Int64
+}
+
+func (b *countingGapBackend) FillNonceGaps(ctx context.Context, account common.Address) ([]*types.Transaction, error) {
+	b.calls.Add(1)
+	return nil, nil
+}
+
+func TestNonceGapFiller_OptIn(t *testing.T) {
+	backend := &countingGapBackend{}
+	filler := NewNonceGapFiller(backend)
+	filler.Track(common.HexToAddress("0x1"))
+	go filler.Run(context.Background())
+	defer filler.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := backend.calls.Load(); n != 0 {
+		t.Fatalf("gaps should not be filled by default, filled %v times", n)
+	}
+
+	filler.SetInterval(20 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if backend.calls.Load() == 0 {
+		t.Fatal("gaps should be filled once an interval is set")
+	}
+
+	filler.SetInterval(0)
+	time.Sleep(30 * time.Millisecond)
+	n := backend.calls.Load()
+	time.Sleep(100 * time.Millisecond)
+	if backend.calls.Load() != n {
+		t.Fatal("gaps should not be filled once disabled")
+	}
+}
@@ -0,0 +1,106 @@
+package message
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StorageQuerier is implemented by storages able to query messages.
+type StorageQuerier interface {
+	QueryMsgs(query MsgQuery) ([]Message, error)
+}
+
+// MsgQuery filters msgs, empty fields match any. Msgs are returned by creation time, then by id.
+type MsgQuery struct {
+	Statuses []MessageStatus
+	From     []common.Address
+	To       []common.Address
+	// msgs of the recurring msg started by Root, the root itself excluded
+	Root *common.Hash
+	// msgs created by Parent, i.e. the next one of a recurring msg
+	Parent *common.Hash
+
+	// bounds of the time msgs were created, and of their last change, e.g. of status.
+	// Zero times are unbounded.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	Offset int
+	// max number of msgs returned, 0 for no limit
+	Limit int
+}
+
+// CreatedAt returns the time msg was added to the storage, zero if unknown.
+func (m *Message) CreatedAt() time.Time {
+	if len(m.History) == 0 {
+		return time.Time{}
+	}
+	return m.History[0].Time
+}
+
+// UpdatedAt returns the time of the last change of msg recorded in its history, zero if unknown.
+func (m *Message) UpdatedAt() time.Time {
+	if len(m.History) == 0 {
+		return time.Time{}
+	}
+	return m.History[len(m.History)-1].Time
+}
+
+func (q MsgQuery) Match(msg Message) bool {
+	if !matchAny(q.Statuses, msg.Status) || !matchAny(q.From, msg.Req.From) {
+		return false
+	}
+
+	if len(q.To) > 0 && (msg.Req.To == nil || !matchAny(q.To, *msg.Req.To)) {
+		return false
+	}
+
+	if q.Root != nil && (msg.Root == nil || *msg.Root != *q.Root) {
+		return false
+	}
+	if q.Parent != nil && (msg.Parent == nil || *msg.Parent != *q.Parent) {
+		return false
+	}
+
+	return inTimeRange(msg.CreatedAt(), q.CreatedAfter, q.CreatedBefore) &&
+		inTimeRange(msg.UpdatedAt(), q.UpdatedAfter, q.UpdatedBefore)
+}
+
+func inTimeRange(t, after, before time.Time) bool {
+	return (after.IsZero() || t.After(after)) && (before.IsZero() || t.Before(before))
+}
+
+// queryMsgs returns the page of msgs matching q, sorted.
+func queryMsgs(msgs []Message, q MsgQuery) []Message {
+	matched := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if q.Match(msg) {
+			matched = append(matched, msg)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		ti, tj := matched[i].CreatedAt(), matched[j].CreatedAt()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		idi, idj := matched[i].Id(), matched[j].Id()
+		return bytes.Compare(idi[:], idj[:]) < 0
+	})
+
+	offset := max(q.Offset, 0)
+	if offset >= len(matched) {
+		return nil
+	}
+	matched = matched[offset:]
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+
+	return matched
+}
@@ -0,0 +1,91 @@
+package message
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStorage_QueryMsgs(t *testing.T) {
+	memory, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, redis := newTestRedisStorage(t)
+	storages := map[string]interface {
+		Storage
+		StorageQuerier
+	}{"memory": memory, "redis": redis}
+	for name, s := range testSQLStorages(t) {
+		storages[name] = s
+	}
+
+	for name, s := range storages {
+		t.Run(name, func(t *testing.T) {
+			addr1, addr2 := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+
+			var ids []common.Hash
+			for i := 0; i < 5; i++ {
+				req := (&Request{From: addr1, To: &addr2}).SetRandomId()
+				if i == 4 {
+					req.From, req.To = addr2, nil
+				}
+				if err := s.AddMsg(*req); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, req.Id())
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			// children of the recurring msg 0
+			for _, id := range ids[1:3] {
+				msg, err := s.GetMsg(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				msg.Root, msg.Parent = &ids[0], &ids[0]
+				if err := s.UpdateMsg(msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			start := time.Now()
+			time.Sleep(2 * time.Millisecond)
+			for _, id := range ids[:2] {
+				if err := s.UpdateMsgStatus(id, MessageStatusInflight); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			query := func(q MsgQuery, want ...common.Hash) {
+				t.Helper()
+
+				msgs, err := s.QueryMsgs(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(msgs) != len(want) {
+					t.Fatalf("want %v msgs, got %v", len(want), len(msgs))
+				}
+				for i, msg := range msgs {
+					if msg.Id() != want[i] {
+						t.Fatalf("want msg %v at %v, got %v", want[i], i, msg.Id())
+					}
+				}
+			}
+
+			query(MsgQuery{}, ids...)
+			query(MsgQuery{Statuses: []MessageStatus{MessageStatusInflight}}, ids[:2]...)
+			query(MsgQuery{From: []common.Address{addr2}}, ids[4])
+			query(MsgQuery{To: []common.Address{addr2}}, ids[:4]...)
+			query(MsgQuery{Root: &ids[0]}, ids[1:3]...)
+			query(MsgQuery{Parent: &ids[0], Statuses: []MessageStatus{MessageStatusSubmitted}}, ids[2])
+			query(MsgQuery{From: []common.Address{addr1}, Statuses: []MessageStatus{MessageStatusInflight}, UpdatedBefore: start})
+			query(MsgQuery{UpdatedAfter: start}, ids[:2]...)
+			query(MsgQuery{CreatedBefore: start, CreatedAfter: time.Now().Add(-time.Hour)}, ids...)
+			query(MsgQuery{Offset: 1, Limit: 2}, ids[1:3]...)
+			query(MsgQuery{Offset: 5})
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-redsync/redsync/v4/redis"
+	"github.com/google/uuid"
+)
+
+var (
+	_ Sequencer      = (*RedisSequencer)(nil)
+	_ SequencerAcker = (*RedisSequencer)(nil)
+)
+
+var ErrNoPendingMsg = errors.New("no pending msg")
+
+// Adds the msg unless it's sequenced already. It's pending unless it waits for the msg it comes after,
+// to be released once that one is acked, or, if it's not sequenced, once it's sent (see resolveSeqMsgScript).
+// KEYS[1]: reqs hash, KEYS[2]: waiting hash, KEYS[3]: orphans set, KEYS[4]: ready list.
+// ARGV[1]: children set key prefix, ARGV[2]: msg id, ARGV[3]: encoded req, ARGV[4]: after msg id or "".
+var pushSeqMsgScript = redis.NewScript(4, `
+if redis.call("HEXISTS", KEYS[1], ARGV[2]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[2], ARGV[3])
+if ARGV[4] == "" then
+	redis.call("RPUSH", KEYS[4], ARGV[2])
+	return 1
+end
+redis.call("HSET", KEYS[2], ARGV[2], ARGV[4])
+if redis.call("HEXISTS", KEYS[1], ARGV[4]) == 1 then
+	redis.call("SADD", ARGV[1] .. ARGV[4], ARGV[2])
+else
+	redis.call("SADD", KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// Resolves a msg waiting for one not sequenced: it's pending if that one was sent,
+// it waits for its ack if it's sequenced meanwhile, and keeps waiting otherwise.
+// KEYS[1]: reqs hash, KEYS[2]: waiting hash, KEYS[3]: orphans set, KEYS[4]: ready list.
+// ARGV[1]: children set key prefix, ARGV[2]: msg id, ARGV[3]: "1" if the msg it comes after was sent.
+var resolveSeqMsgScript = redis.NewScript(4, `
+local after = redis.call("HGET", KEYS[2], ARGV[2])
+if not after or redis.call("SISMEMBER", KEYS[3], ARGV[2]) == 0 then
+	return 0
+end
+if ARGV[3] == "1" then
+	redis.call("SREM", KEYS[3], ARGV[2])
+	redis.call("HDEL", KEYS[2], ARGV[2])
+	redis.call("RPUSH", KEYS[4], ARGV[2])
+	return 1
+end
+if redis.call("HEXISTS", KEYS[1], after) == 1 then
+	redis.call("SREM", KEYS[3], ARGV[2])
+	redis.call("SADD", ARGV[1] .. after, ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// Redelivers the msgs whose visibility timeout expired, unless claimed by a consumer still alive,
+// then pops the first pending msg, claims it for the consumer and hides it from other consumers
+// until acked or its timeout expires.
+// KEYS[1]: reqs hash, KEYS[2]: ready list, KEYS[3]: inflight sorted set, KEYS[4]: claims hash,
+// KEYS[5]: consumers sorted set. ARGV[1]: now in ms, ARGV[2]: visibility deadline in ms, ARGV[3]: consumer.
+var popSeqMsgScript = redis.NewScript(5, `
+redis.call("ZADD", KEYS[5], ARGV[2], ARGV[3])
+local expired = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1])
+for i = #expired, 1, -1 do
+	local owner = redis.call("HGET", KEYS[4], expired[i])
+	local alive = owner and tonumber(redis.call("ZSCORE", KEYS[5], owner) or "0") > tonumber(ARGV[1])
+	if alive then
+		redis.call("ZADD", KEYS[3], ARGV[2], expired[i])
+	else
+		redis.call("ZREM", KEYS[3], expired[i])
+		redis.call("HDEL", KEYS[4], expired[i])
+		redis.call("LPUSH", KEYS[2], expired[i])
+	end
+end
+local id = redis.call("LPOP", KEYS[2])
+if not id then
+	return false
+end
+redis.call("ZADD", KEYS[3], ARGV[2], id)
+redis.call("HSET", KEYS[4], id, ARGV[3])
+return {id, redis.call("HGET", KEYS[1], id)}
+`)
+
+// Keeps the consumer alive and extends the visibility deadlines of the msgs it holds, unless acked.
+// KEYS[1]: inflight sorted set, KEYS[2]: consumers sorted set.
+// ARGV[1]: now in ms, ARGV[2]: visibility deadline in ms, ARGV[3]: consumer, ARGV[4..]: msg ids.
+var extendSeqMsgsScript = redis.NewScript(2, `
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", ARGV[1])
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[3])
+for i = 4, #ARGV do
+	redis.call("ZADD", KEYS[1], "XX", ARGV[2], ARGV[i])
+end
+return 1
+`)
+
+// Removes the msg and releases the ones waiting for it.
+// KEYS[1]: reqs hash, KEYS[2]: waiting hash, KEYS[3]: ready list, KEYS[4]: inflight sorted set, KEYS[5]: claims hash.
+// ARGV[1]: children set key prefix, ARGV[2]: msg id.
+var ackSeqMsgScript = redis.NewScript(5, `
+redis.call("ZREM", KEYS[4], ARGV[2])
+redis.call("HDEL", KEYS[5], ARGV[2])
+if redis.call("HDEL", KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+local children = ARGV[1] .. ARGV[2]
+for _, child in ipairs(redis.call("SMEMBERS", children)) do
+	redis.call("HDEL", KEYS[2], child)
+	redis.call("RPUSH", KEYS[3], child)
+end
+redis.call("DEL", children)
+return 1
+`)
+
+// KEYS[1]: ready list, KEYS[2]: reqs hash.
+var peekSeqMsgScript = redis.NewScript(2, `
+local id = redis.call("LINDEX", KEYS[1], 0)
+if not id then
+	return false
+end
+return redis.call("HGET", KEYS[2], id)
+`)
+
+// KEYS[1]: waiting hash, KEYS[2]: ready list.
+var seqMsgCountsScript = redis.NewScript(2, `return {redis.call("HLEN", KEYS[1]), redis.call("LLEN", KEYS[2])}`)
+
+// KEYS[1]: orphans set, KEYS[2]: waiting hash.
+var seqOrphansScript = redis.NewScript(2, `
+local orphans = {}
+for _, id in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	table.insert(orphans, id)
+	table.insert(orphans, redis.call("HGET", KEYS[2], id) or "")
+end
+return orphans
+`)
+
+// RedisSequencer sequences msgs in redis, so that msgs queued survive restarts and are shared
+// by multiple consumer processes. The AfterMsg edges are kept along with the msgs: a msg is
+// pending once the msg it comes after is acked, or was sent if it's not sequenced.
+//
+// A msg popped is claimed by its consumer and hidden from other consumers until it's acked.
+// Consumers keep the msgs they hold hidden as long as they are alive, e.g. while retrying them,
+// so msgs are redelivered only once the visibility timeout expires after their consumer crashed.
+//
+// Close it once done, otherwise the goroutines resolving the msgs coming after msgs not sequenced and keeping
+// the msgs popped hidden leak.
+type RedisSequencer struct {
+	chainId           *big.Int
+	redisPool         redis.Pool
+	msgStorage        Storage
+	visibilityTimeout time.Duration
+	pollInterval      time.Duration
+	consumer          string
+
+	leasesMu sync.Mutex
+	leases   map[common.Hash]struct{}
+
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewRedisSequencer(chainId *big.Int, pool redis.Pool, msgStorage Storage, visibilityTimeout time.Duration) *RedisSequencer {
+	s := &RedisSequencer{
+		chainId:           chainId,
+		redisPool:         pool,
+		msgStorage:        msgStorage,
+		visibilityTimeout: visibilityTimeout,
+		pollInterval:      100 * time.Millisecond,
+		consumer:          uuid.NewString(),
+		leases:            make(map[common.Hash]struct{}),
+		quit:              make(chan struct{}),
+	}
+
+	go s.resolveOrphans()
+	go s.keepLeases()
+
+	return s
+}
+
+// PushMsg sequences msg, unless it's sequenced already, e.g. when recovered after a restart.
+func (s *RedisSequencer) PushMsg(msg Request) error {
+	data, err := json.Marshal(storedRequest{Id: msg.id, Request: msg})
+	if err != nil {
+		return err
+	}
+
+	after := ""
+	if msg.AfterMsg != nil {
+		after = msg.AfterMsg.Hex()
+	}
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	added, err := conn.Eval(pushSeqMsgScript, s.key("reqs"), s.key("waiting"), s.key("orphans"), s.key("ready"),
+		s.key("children-"), msg.id.Hex(), string(data), after)
+	if err != nil {
+		return err
+	}
+	if n, ok := added.(int64); ok && n == 0 {
+		log.Debug("msg sequenced already", "msgId", msg.id.Hex())
+	}
+
+	return nil
+}
+
+// PopMsg blocks until a msg is pending, which is then kept hidden from other consumers until acked by AckMsg.
+// Msgs already sent, e.g. redelivered as their consumer crashed before acking them, are popped too,
+// consumers ack them once they found their response.
+func (s *RedisSequencer) PopMsg() (Request, error) {
+	for {
+		req, ok, err := s.popMsg()
+		if err != nil {
+			return Request{}, err
+		}
+
+		if ok {
+			log.Debug("Pop req from redis sequencer", "req ID", req.id)
+			return req, nil
+		}
+
+		select {
+		case <-s.quit:
+			return Request{}, ErrPendingChannelClosed
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *RedisSequencer) popMsg() (req Request, ok bool, err error) {
+	select {
+	case <-s.quit:
+		return Request{}, false, ErrPendingChannelClosed
+	default:
+	}
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	now := time.Now()
+	reply, err := conn.Eval(popSeqMsgScript, s.key("reqs"), s.key("ready"), s.key("inflight"), s.key("claims"), s.key("consumers"),
+		now.UnixMilli(), now.Add(s.visibilityTimeout).UnixMilli(), s.consumer)
+	if err != nil || reply == nil {
+		return
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return Request{}, false, fmt.Errorf("unexpected reply of popped msg: %v", reply)
+	}
+	if id, ok := items[0].(string); ok {
+		s.leasesMu.Lock()
+		s.leases[common.HexToHash(id)] = struct{}{}
+		s.leasesMu.Unlock()
+	}
+	data, ok := items[1].(string)
+	if !ok {
+		return Request{}, false, fmt.Errorf("msg %v popped without request", items[0])
+	}
+
+	req, err = decodeSeqRequest(data)
+	return req, err == nil, err
+}
+
+// AckMsg removes msgId once handled, releasing the msgs coming after it.
+func (s *RedisSequencer) AckMsg(msgId common.Hash) error {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Eval(ackSeqMsgScript, s.key("reqs"), s.key("waiting"), s.key("ready"), s.key("inflight"), s.key("claims"),
+		s.key("children-"), msgId.Hex())
+	if err != nil {
+		return err
+	}
+
+	s.leasesMu.Lock()
+	delete(s.leases, msgId)
+	s.leasesMu.Unlock()
+
+	return nil
+}
+
+// PeekMsg returns the next msg pending without popping it, or ErrNoPendingMsg.
+func (s *RedisSequencer) PeekMsg() (Request, error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return Request{}, err
+	}
+	defer conn.Close()
+
+	reply, err := conn.Eval(peekSeqMsgScript, s.key("ready"), s.key("reqs"))
+	if err != nil {
+		return Request{}, err
+	}
+
+	data, ok := reply.(string)
+	if !ok {
+		return Request{}, ErrNoPendingMsg
+	}
+
+	return decodeSeqRequest(data)
+}
+
+// QueuedMsgCount returns the number of msgs waiting for the msgs they come after.
+func (s *RedisSequencer) QueuedMsgCount() (int, error) {
+	queued, _, err := s.msgCounts()
+	return queued, err
+}
+
+// PendingMsgCount returns the number of msgs ready to be popped.
+func (s *RedisSequencer) PendingMsgCount() (int, error) {
+	_, pending, err := s.msgCounts()
+	return pending, err
+}
+
+func (s *RedisSequencer) msgCounts() (queued int, pending int, err error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reply, err := conn.Eval(seqMsgCountsScript, s.key("waiting"), s.key("ready"))
+	if err != nil {
+		return
+	}
+
+	counts, ok := reply.([]interface{})
+	if !ok || len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected reply of msg counts: %v", reply)
+	}
+	q, _ := counts[0].(int64)
+	p, _ := counts[1].(int64)
+
+	return int(q), int(p), nil
+}
+
+// Close stops popping msgs. Msgs sequenced are kept for the next consumer,
+// and the ones popped are kept hidden until acked. It must be called for the goroutines of the sequencer to exit.
+func (s *RedisSequencer) Close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// keepLeases extends the visibility deadlines of the msgs popped until they are acked,
+// a third of the visibility timeout before they expire. It stops once closed with no msgs held.
+func (s *RedisSequencer) keepLeases() {
+	ticker := time.NewTicker(s.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	quit := s.quit
+	for {
+		select {
+		case <-quit:
+			quit = nil
+		case <-ticker.C:
+		}
+
+		s.leasesMu.Lock()
+		held := len(s.leases)
+		s.leasesMu.Unlock()
+		if quit == nil && held == 0 {
+			return
+		}
+
+		if err := s.extendLeases(); err != nil {
+			log.Warn("failed to extend msgs held in redis sequencer", "err", err)
+		}
+	}
+}
+
+func (s *RedisSequencer) extendLeases() error {
+	s.leasesMu.Lock()
+	now := time.Now()
+	args := []interface{}{s.key("inflight"), s.key("consumers"), now.UnixMilli(), now.Add(s.visibilityTimeout).UnixMilli(), s.consumer}
+	for id := range s.leases {
+		args = append(args, id.Hex())
+	}
+	s.leasesMu.Unlock()
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Eval(extendSeqMsgsScript, args...)
+	return err
+}
+
+// resolveOrphans releases the msgs waiting for msgs not sequenced once these are sent.
+func (s *RedisSequencer) resolveOrphans() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.resolveOrphansOnce(); err != nil {
+			log.Warn("failed to resolve msgs waiting in redis sequencer", "err", err)
+		}
+	}
+}
+
+func (s *RedisSequencer) resolveOrphansOnce() error {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	reply, err := conn.Eval(seqOrphansScript, s.key("orphans"), s.key("waiting"))
+	if err != nil {
+		return err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok {
+		return fmt.Errorf("unexpected reply of orphans: %T", reply)
+	}
+
+	for i := 0; i+1 < len(items); i += 2 {
+		id, _ := items[i].(string)
+		after, _ := items[i+1].(string)
+
+		sent := "0"
+		if msg, err := s.msgStorage.GetMsg(common.HexToHash(after)); err == nil && msg.Resp != nil {
+			sent = "1"
+		}
+
+		_, err := conn.Eval(resolveSeqMsgScript, s.key("reqs"), s.key("waiting"), s.key("orphans"), s.key("ready"),
+			s.key("children-"), id, sent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RedisSequencer) key(name string) string {
+	return fmt.Sprintf("seq-chain-%s-%s", s.chainId.String(), name)
+}
+
+func decodeSeqRequest(data string) (Request, error) {
+	var stored storedRequest
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return Request{}, err
+	}
+
+	req := stored.Request
+	req.id = stored.Id
+	return req, nil
+}
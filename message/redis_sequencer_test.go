@@ -0,0 +1,279 @@
+package message
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	goredislib "github.com/redis/go-redis/v9"
+)
+
+// newTestRedisSequencer returns a sequencer with the redis client it uses, closed to simulate a crash.
+func newTestRedisSequencer(t *testing.T, mr *miniredis.Miniredis, storage Storage, visibilityTimeout time.Duration) (*RedisSequencer, *goredislib.Client) {
+	t.Helper()
+	client := goredislib.NewClient(&goredislib.Options{Addr: mr.Addr()})
+	s := NewRedisSequencer(big.NewInt(1337), goredis.NewPool(client), storage, visibilityTimeout)
+	t.Cleanup(s.Close)
+	return s, client
+}
+
+func popTestMsgAsync(s *RedisSequencer) <-chan Request {
+	popped := make(chan Request, 1)
+	go func() {
+		req, err := s.PopMsg()
+		if err == nil {
+			popped <- req
+		}
+	}()
+	return popped
+}
+
+func popTestMsg(t *testing.T, s *RedisSequencer) Request {
+	t.Helper()
+
+	select {
+	case req := <-popTestMsgAsync(s):
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no msg popped")
+		return Request{}
+	}
+}
+
+func Test_RedisSequencer(t *testing.T) {
+	mr := miniredis.RunT(t)
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestRedisSequencer(t, mr, storage, time.Minute)
+
+	id1, id2, id3, id4 := common.HexToHash("0x1"), common.HexToHash("0x2"), common.HexToHash("0x3"), common.HexToHash("0x4")
+	reqs := []Request{
+		{id: id3, AfterMsg: &id2, Value: big.NewInt(3)},
+		{id: id2, AfterMsg: &id1},
+		{id: id1},
+		{id: id4},
+	}
+	for _, req := range reqs {
+		if err := storage.AddMsg(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PushMsg(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pushed again on recovery
+	if err := s.PushMsg(reqs[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// msg 3 waits for msg 2 sequenced after it
+	if err := s.resolveOrphansOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if queued, _ := s.QueuedMsgCount(); queued != 2 {
+		t.Fatalf("want 2 msgs queued, got %v", queued)
+	}
+	if pending, _ := s.PendingMsgCount(); pending != 2 {
+		t.Fatalf("want 2 msgs pending, got %v", pending)
+	}
+
+	peeked, err := s.PeekMsg()
+	if err != nil || peeked.Id() != id1 {
+		t.Fatalf("unexpected msg peeked %v: %v", peeked.Id(), err)
+	}
+
+	var got []common.Hash
+	for i := 0; i < len(reqs); i++ {
+		req := popTestMsg(t, s)
+		got = append(got, req.Id())
+		if err := s.AckMsg(req.Id()); err != nil {
+			t.Fatal(err)
+		}
+		if req.Id() == id3 && req.Value.Cmp(big.NewInt(3)) != 0 {
+			t.Fatalf("request was not restored: %+v", req)
+		}
+	}
+
+	want := []common.Hash{id1, id4, id2, id3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want sequence %v, got %v", want, got)
+		}
+	}
+
+	if _, err := s.PeekMsg(); !errors.Is(err, ErrNoPendingMsg) {
+		t.Fatalf("want no pending msg, got %v", err)
+	}
+}
+
+func Test_RedisSequencer_AfterMsgSent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestRedisSequencer(t, mr, storage, time.Minute)
+
+	parent, child := (&Request{}).SetRandomId(), (&Request{}).SetRandomId()
+	parentId := parent.Id()
+	child.AfterMsg = &parentId
+	if err := storage.AddMsg(*child); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PushMsg(*child); err != nil {
+		t.Fatal(err)
+	}
+
+	// the msg it comes after was sent without the sequencer
+	if err := s.resolveOrphansOnce(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := s.PendingMsgCount(); pending != 0 {
+		t.Fatal("msg should wait for the one it comes after")
+	}
+
+	if err := storage.AddMsg(*parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateResponse(parentId, Response{Id: parentId}); err != nil {
+		t.Fatal(err)
+	}
+
+	if req := popTestMsg(t, s); req.Id() != child.Id() {
+		t.Fatalf("unexpected msg popped: %v", req.Id())
+	}
+}
+
+func Test_RedisSequencer_Consumers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer1, client1 := newTestRedisSequencer(t, mr, storage, 200*time.Millisecond)
+	consumer2, _ := newTestRedisSequencer(t, mr, storage, 200*time.Millisecond)
+
+	req1, req2 := (&Request{}).SetRandomId(), (&Request{}).SetRandomId()
+	for _, req := range []*Request{req1, req2} {
+		if err := storage.AddMsg(*req); err != nil {
+			t.Fatal(err)
+		}
+		if err := consumer1.PushMsg(*req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// msgs popped are hidden from other consumers
+	if req := popTestMsg(t, consumer1); req.Id() != req1.Id() {
+		t.Fatalf("unexpected msg popped: %v", req.Id())
+	}
+	if req := popTestMsg(t, consumer2); req.Id() != req2.Id() {
+		t.Fatalf("unexpected msg popped: %v", req.Id())
+	}
+	if err := consumer2.AckMsg(req2.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	// consumer1 crashed after sending its msg, which is redelivered after the visibility timeout
+	// for the consumer to ack it once it found its response
+	if err := storage.UpdateResponse(req1.Id(), Response{Id: req1.Id()}); err != nil {
+		t.Fatal(err)
+	}
+	consumer1.Close()
+	client1.Close()
+	if req := popTestMsg(t, consumer2); req.Id() != req1.Id() {
+		t.Fatalf("unexpected msg redelivered: %v", req.Id())
+	}
+	if err := consumer2.AckMsg(req1.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	// msgs acked are not redelivered
+	time.Sleep(300 * time.Millisecond)
+	req3 := (&Request{}).SetRandomId()
+	if err := consumer2.PushMsg(*req3); err != nil {
+		t.Fatal(err)
+	}
+	if req := popTestMsg(t, consumer2); req.Id() != req3.Id() {
+		t.Fatalf("unexpected msg popped: %v", req.Id())
+	}
+	if err := consumer2.AckMsg(req3.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	// msgs are kept across restarts
+	req4 := (&Request{}).SetRandomId()
+	if err := consumer2.PushMsg(*req4); err != nil {
+		t.Fatal(err)
+	}
+	consumer2.Close()
+	if _, err := consumer2.PopMsg(); !errors.Is(err, ErrPendingChannelClosed) {
+		t.Fatalf("want closed, got %v", err)
+	}
+
+	restarted, _ := newTestRedisSequencer(t, mr, storage, time.Minute)
+	if req := popTestMsg(t, restarted); req.Id() != req4.Id() {
+		t.Fatalf("unexpected msg popped after restart: %v", req.Id())
+	}
+}
+
+func Test_RedisSequencer_HeldLongerThanVisibilityTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer1, _ := newTestRedisSequencer(t, mr, storage, 200*time.Millisecond)
+	consumer2, _ := newTestRedisSequencer(t, mr, storage, 200*time.Millisecond)
+
+	req1, req2 := (&Request{}).SetRandomId(), (&Request{}).SetRandomId()
+	if err := storage.AddMsg(*req1); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer1.PushMsg(*req1); err != nil {
+		t.Fatal(err)
+	}
+	if req := popTestMsg(t, consumer1); req.Id() != req1.Id() {
+		t.Fatalf("unexpected msg popped: %v", req.Id())
+	}
+
+	// consumer1 retries the msg for longer than the visibility timeout, even after closed
+	consumer1.Close()
+	popped := popTestMsgAsync(consumer2)
+	select {
+	case req := <-popped:
+		t.Fatalf("msg held redelivered: %v", req.Id())
+	case <-time.After(time.Second):
+	}
+
+	// the msg is claimed by consumer1, so it's not redelivered even if its deadline is extended late
+	mr.ZAdd(consumer1.key("inflight"), 0, req1.Id().Hex())
+	select {
+	case req := <-popped:
+		t.Fatalf("msg claimed redelivered: %v", req.Id())
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if err := consumer1.AckMsg(req1.Id()); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer2.PushMsg(*req2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case req := <-popped:
+		if req.Id() != req2.Id() {
+			t.Fatalf("unexpected msg popped: %v", req.Id())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no msg popped")
+	}
+}
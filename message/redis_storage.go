@@ -0,0 +1,517 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/go-redsync/redsync/v4/redis"
+)
+
+var (
+	_ Storage              = (*RedisStorage)(nil)
+	_ StorageLister        = (*RedisStorage)(nil)
+	_ StorageQuerier       = (*RedisStorage)(nil)
+	_ StorageStatusSwapper = (*RedisStorage)(nil)
+)
+
+// Adds the msg only if it does not exist yet, and indexes it by status, creation time and sender.
+// KEYS[1]: msg key, KEYS[2]: status set key, KEYS[3]: creation index key, KEYS[4]: sender index key.
+// ARGV[1]: encoded msg, ARGV[2]: msg id, ARGV[3]: creation time in microseconds.
+var addMsgScript = redis.NewScript(4, `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1])
+redis.call("SADD", KEYS[2], ARGV[2])
+redis.call("ZADD", KEYS[3], ARGV[3], ARGV[2])
+redis.call("ZADD", KEYS[4], ARGV[3], ARGV[2])
+return 1
+`)
+
+// Overwrites the msg and moves it from the previous status set to the new one.
+// KEYS[1]: msg key, KEYS[2]: previous status set key, KEYS[3]: status set key.
+// ARGV[1]: encoded msg, ARGV[2]: msg id.
+var updateMsgScript = redis.NewScript(3, `
+redis.call("SET", KEYS[1], ARGV[1])
+redis.call("SREM", KEYS[2], ARGV[2])
+redis.call("SADD", KEYS[3], ARGV[2])
+return 1
+`)
+
+// KEYS[1]: status set key.
+var msgIdsByStatusScript = redis.NewScript(1, `return redis.call("SMEMBERS", KEYS[1])`)
+
+// Returns a page of msg ids of an index by creation time, ordered by time then id.
+// KEYS[1]: index key. ARGV[1], ARGV[2]: min and max creation time, ARGV[3]: offset, ARGV[4]: count.
+var rangeMsgIdsScript = redis.NewScript(1, `
+return redis.call("ZRANGEBYSCORE", KEYS[1], ARGV[1], ARGV[2], "LIMIT", ARGV[3], ARGV[4])
+`)
+
+// Scripts below take a variable number of keys, so they are created for each call with the number of keys.
+
+// Counts the msgs of an index by creation time within bounds, and the ones of status sets.
+// KEYS[1]: index key, KEYS[2:]: status set keys. ARGV[1], ARGV[2]: min and max creation time.
+const countCandidatesSrc = `
+local inStatuses = 0
+for i = 2, #KEYS do
+	inStatuses = inStatuses + redis.call("SCARD", KEYS[i])
+end
+return {redis.call("ZCOUNT", KEYS[1], ARGV[1], ARGV[2]), inStatuses}
+`
+
+// Returns the msg ids of any of the status sets of KEYS.
+const msgIdsByStatusesSrc = `return redis.call("SUNION", unpack(KEYS))`
+
+// Returns the msg ids of ARGV in any of the status sets of KEYS.
+const msgIdsInStatusesSrc = `
+local ids = {}
+for _, id in ipairs(ARGV) do
+	for _, key in ipairs(KEYS) do
+		if redis.call("SISMEMBER", key, id) == 1 then
+			table.insert(ids, id)
+			break
+		end
+	end
+end
+return ids
+`
+
+// Returns the msgs of the msg keys of KEYS, false for the ones not found.
+const getMsgsSrc = `return redis.call("MGET", unpack(KEYS))`
+
+// redisQueryPageSize is the number of msg ids loaded at a time by QueryMsgs.
+const redisQueryPageSize = 100
+
+// RedisStorage stores messages in redis, so that they survive restarts
+// and can be shared and inspected by multiple processes.
+type RedisStorage struct {
+	chainId   *big.Int
+	redisPool redis.Pool
+	rsync     *redsync.Redsync
+}
+
+func NewRedisStorage(chainId *big.Int, pool redis.Pool) *RedisStorage {
+	return &RedisStorage{
+		chainId:   chainId,
+		redisPool: pool,
+		rsync:     redsync.New(pool),
+	}
+}
+
+func (s *RedisStorage) AddMsg(req Request) error {
+	log.Debug("RedisStorage AddMsg", "req", req)
+
+	msg := Message{
+		Req:    &req,
+		Status: MessageStatusSubmitted,
+	}
+	msg.History = []HistoryRecord{newHistoryRecord(0, msg)}
+
+	data, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	added, err := conn.Eval(addMsgScript, s.msgKey(req.id), s.statusKey(msg.Status), s.createdKey(), s.senderKey(req.From),
+		string(data), req.id.Hex(), createdScore(msg.CreatedAt()))
+	if err != nil {
+		return err
+	}
+
+	if n, ok := added.(int64); !ok || n == 0 {
+		return fmt.Errorf("duplicated msg not allowed")
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) HasMsg(msgId common.Hash) bool {
+	_, err := s.GetMsg(msgId)
+	return err == nil
+}
+
+func (s *RedisStorage) GetMsg(msgId common.Hash) (Message, error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return Message{}, err
+	}
+	defer conn.Close()
+
+	data, err := conn.Get(s.msgKey(msgId))
+	if err != nil {
+		return Message{}, err
+	}
+
+	if data == "" {
+		return Message{}, fmt.Errorf("not found")
+	}
+
+	return decodeMessage([]byte(data))
+}
+
+func (s *RedisStorage) UpdateMsg(msg Message) error {
+	return s.update(msg.Req.id, func(*Message) (Message, error) {
+		return msg, nil
+	})
+}
+
+func (s *RedisStorage) UpdateResponse(msgId common.Hash, resp Response) error {
+	log.Debug("RedisStorage UpdateResponse", "msgId", msgId.Hex(), "resp", resp)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		if msg.Resp != nil {
+			return Message{}, fmt.Errorf("same msg not allowed updating response twice: %v", msgId.Hex())
+		}
+
+		msg.Resp = &resp
+		return *msg, nil
+	})
+}
+
+func (s *RedisStorage) UpdateReceipt(msgId common.Hash, receipt Receipt) error {
+	log.Debug("RedisStorage UpdateReceipt", "msgId", msgId.Hex(),
+		"txHash", receipt.TxReceipt.TxHash.Hex(), "receipt", receipt)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Receipt = &receipt
+		return *msg, nil
+	})
+}
+
+func (s *RedisStorage) UpdateMsgStatus(msgId common.Hash, status MessageStatus) error {
+	log.Debug("RedisStorage UpdateMsgStatus", "msgId", msgId.Hex(), "status", status)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Status = status
+		return *msg, nil
+	})
+}
+
+func (s *RedisStorage) SwapMsgStatus(msgId common.Hash, from []MessageStatus, status MessageStatus) error {
+	log.Debug("RedisStorage SwapMsgStatus", "msgId", msgId.Hex(), "from", from, "status", status)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		return swapStatus(msg, from, status)
+	})
+}
+
+func (s *RedisStorage) GetNonce(msgId common.Hash) (nonce uint64, err error) {
+	msg, err := s.GetMsg(msgId)
+	if err != nil {
+		return
+	}
+
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		return 0, fmt.Errorf("no nonce assigned")
+	}
+
+	return msg.Resp.Tx.Nonce(), nil
+}
+
+func (s *RedisStorage) MsgIdsByStatus(status MessageStatus) ([]common.Hash, error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := conn.Eval(msgIdsByStatusScript, s.statusKey(status))
+	if err != nil {
+		return nil, err
+	}
+
+	members, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply of status index: %T", reply)
+	}
+
+	msgIds := make([]common.Hash, 0, len(members))
+	for _, m := range members {
+		id, ok := m.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected member of status index: %T", m)
+		}
+		msgIds = append(msgIds, common.HexToHash(id))
+	}
+
+	return msgIds, nil
+}
+
+// QueryMsgs loads the msgs of the statuses queried if fewer than the ones of the index by creation time, of the sender
+// queried if only one, within the creation time queried. Otherwise it pages through the index and loads the msgs
+// of the statuses queried until the page of the query is filled.
+func (s *RedisStorage) QueryMsgs(query MsgQuery) ([]Message, error) {
+	index := s.createdKey()
+	if len(query.From) == 1 {
+		index = s.senderKey(query.From[0])
+	}
+
+	// bounds are inclusive as scores are rounded to microseconds, msgs are matched exactly after loaded
+	lower, upper := "-inf", "+inf"
+	if !query.CreatedAfter.IsZero() {
+		lower = createdScore(query.CreatedAfter)
+	}
+	if !query.CreatedBefore.IsZero() {
+		upper = createdScore(query.CreatedBefore)
+	}
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if len(query.Statuses) > 0 {
+		inIndex, inStatuses, err := s.countCandidates(conn, index, lower, upper, query.Statuses)
+		if err != nil {
+			return nil, err
+		}
+		if inStatuses <= inIndex {
+			return s.queryByStatuses(conn, query)
+		}
+	}
+
+	offset := max(query.Offset, 0)
+	var msgs []Message
+	for start := 0; ; start += redisQueryPageSize {
+		ids, err := evalStrings(conn, rangeMsgIdsScript, index, lower, upper, start, redisQueryPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := s.loadMsgs(conn, ids, query.Statuses)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range page {
+			if !query.Match(msg) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+
+			msgs = append(msgs, msg)
+			if query.Limit > 0 && len(msgs) == query.Limit {
+				return msgs, nil
+			}
+		}
+
+		if len(ids) < redisQueryPageSize {
+			return msgs, nil
+		}
+	}
+}
+
+// countCandidates returns the number of msgs of index within lower and upper, and the number of msgs in statuses.
+func (s *RedisStorage) countCandidates(conn redis.Conn, index, lower, upper string, statuses []MessageStatus) (inIndex, inStatuses int64, err error) {
+	keysAndArgs := []interface{}{index}
+	for _, status := range statuses {
+		keysAndArgs = append(keysAndArgs, s.statusKey(status))
+	}
+	keysAndArgs = append(keysAndArgs, lower, upper)
+
+	reply, err := conn.Eval(redis.NewScript(1+len(statuses), countCandidatesSrc), keysAndArgs...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	counts, ok := reply.([]interface{})
+	if !ok || len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected reply of counts: %v", reply)
+	}
+	inIndex, ok1 := counts[0].(int64)
+	inStatuses, ok2 := counts[1].(int64)
+	if !ok1 || !ok2 {
+		return 0, 0, fmt.Errorf("unexpected counts: %T, %T", counts[0], counts[1])
+	}
+
+	return inIndex, inStatuses, nil
+}
+
+// queryByStatuses loads the msgs in the statuses queried, then matches and sorts them.
+func (s *RedisStorage) queryByStatuses(conn redis.Conn, query MsgQuery) ([]Message, error) {
+	keys := make([]interface{}, len(query.Statuses))
+	for i, status := range query.Statuses {
+		keys[i] = s.statusKey(status)
+	}
+
+	ids, err := evalStrings(conn, redis.NewScript(len(keys), msgIdsByStatusesSrc), keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []Message
+	for start := 0; start < len(ids); start += redisQueryPageSize {
+		page, err := s.loadMsgs(conn, ids[start:min(start+redisQueryPageSize, len(ids))], nil)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, page...)
+	}
+
+	// statuses may have changed meanwhile, so they are matched again
+	return queryMsgs(msgs, query), nil
+}
+
+// loadMsgs loads the msgs of ids in any of statuses, or all of them if none, keeping the order of ids.
+func (s *RedisStorage) loadMsgs(conn redis.Conn, ids []string, statuses []MessageStatus) ([]Message, error) {
+	if len(ids) > 0 && len(statuses) > 0 {
+		keysAndArgs := make([]interface{}, 0, len(statuses)+len(ids))
+		for _, status := range statuses {
+			keysAndArgs = append(keysAndArgs, s.statusKey(status))
+		}
+		for _, id := range ids {
+			keysAndArgs = append(keysAndArgs, id)
+		}
+
+		var err error
+		ids, err = evalStrings(conn, redis.NewScript(len(statuses), msgIdsInStatusesSrc), keysAndArgs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = s.msgKeyPrefix() + id
+	}
+	reply, err := conn.Eval(redis.NewScript(len(keys), getMsgsSrc), keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply of msgs: %T", reply)
+	}
+
+	msgs := make([]Message, 0, len(items))
+	for _, item := range items {
+		// dropped meanwhile
+		if item == nil {
+			continue
+		}
+
+		data, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected msg: %T", item)
+		}
+
+		msg, err := decodeMessage([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+func evalStrings(conn redis.Conn, script *redis.Script, keysAndArgs ...interface{}) ([]string, error) {
+	reply, err := conn.Eval(script, keysAndArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toStrings(reply)
+}
+
+func toStrings(reply interface{}) ([]string, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply: %T", reply)
+	}
+
+	strs := make([]string, len(items))
+	for i, item := range items {
+		if strs[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("unexpected item of reply: %T", item)
+		}
+	}
+
+	return strs, nil
+}
+
+// createdScore is the score of msgs created at t in the index by creation time.
+func createdScore(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+// update applies fn to the stored msg while holding its lock, so that concurrent updates
+// from this or other processes are not lost, and records the change in its history.
+func (s *RedisStorage) update(msgId common.Hash, fn func(msg *Message) (Message, error)) error {
+	mutex := s.rsync.NewMutex(s.lockKey(msgId))
+	if err := mutex.Lock(); err != nil {
+		return err
+	}
+	defer mutex.Unlock()
+
+	old, err := s.GetMsg(msgId)
+	if err != nil {
+		return err
+	}
+	prev := old
+
+	msg, err := fn(&old)
+	if err != nil {
+		return err
+	}
+	msg = withHistory(prev, msg)
+
+	data, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Eval(updateMsgScript, s.msgKey(msgId), s.statusKey(prev.Status), s.statusKey(msg.Status), string(data), msgId.Hex())
+	return err
+}
+
+func (s *RedisStorage) msgKey(msgId common.Hash) string {
+	return s.msgKeyPrefix() + msgId.Hex()
+}
+
+func (s *RedisStorage) msgKeyPrefix() string {
+	return fmt.Sprintf("msg-chain-%s-id-", s.chainId.String())
+}
+
+func (s *RedisStorage) statusKey(status MessageStatus) string {
+	return fmt.Sprintf("msg-chain-%s-status-%d", s.chainId.String(), status)
+}
+
+func (s *RedisStorage) createdKey() string {
+	return fmt.Sprintf("msg-chain-%s-created", s.chainId.String())
+}
+
+func (s *RedisStorage) senderKey(from common.Address) string {
+	return fmt.Sprintf("msg-chain-%s-from-%s", s.chainId.String(), from.Hex())
+}
+
+func (s *RedisStorage) lockKey(msgId common.Hash) string {
+	return fmt.Sprintf("msg-lock-chain-%s-id-%s", s.chainId.String(), msgId.Hex())
+}
@@ -0,0 +1,244 @@
+package message
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	goredislib "github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStorage(t *testing.T) (*miniredis.Miniredis, *RedisStorage) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	pool := goredis.NewPool(goredislib.NewClient(&goredislib.Options{Addr: mr.Addr()}))
+	return mr, NewRedisStorage(big.NewInt(1337), pool)
+}
+
+func newTestSignedTx(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x2")
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(2e9),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+type testRpcError struct{}
+
+func (testRpcError) Error() string          { return "nonce too low" }
+func (testRpcError) ErrorCode() int         { return -32000 }
+func (testRpcError) ErrorData() interface{} { return "0x01" }
+
+func Test_RedisStorage(t *testing.T) {
+	mr, s := newTestRedisStorage(t)
+
+	to := common.HexToAddress("0x2")
+	gasOnEstimationFailed := uint64(100000)
+	req := Request{
+		From:                  common.HexToAddress("0x1"),
+		To:                    &to,
+		Value:                 big.NewInt(10),
+		GasOnEstimationFailed: &gasOnEstimationFailed,
+		GasFeeCap:             big.NewInt(2e9),
+		Data:                  []byte{1, 2, 3},
+		AccessList:            types.AccessList{{Address: to, StorageKeys: []common.Hash{{1}}}},
+	}
+	req.SetRandomId()
+	id := req.Id()
+
+	if s.HasMsg(id) {
+		t.Fatal("msg should not exist yet")
+	}
+	if err := s.AddMsg(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddMsg(req); err == nil {
+		t.Fatal("duplicated msg should be rejected")
+	}
+
+	msg, err := s.GetMsg(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Id() != id || msg.Status != MessageStatusSubmitted || msg.Req.Value.Cmp(req.Value) != 0 ||
+		*msg.Req.GasOnEstimationFailed != gasOnEstimationFailed || string(msg.Req.Data) != string(req.Data) ||
+		len(msg.Req.AccessList) != 1 {
+		t.Fatalf("unexpected msg: %+v", msg.Req)
+	}
+
+	if _, err := s.GetNonce(id); err == nil {
+		t.Fatal("no nonce should be assigned yet")
+	}
+
+	tx := newTestSignedTx(t, 7)
+	if err := s.UpdateResponse(id, Response{Id: id, Tx: tx, Err: testRpcError{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateResponse(id, Response{Id: id}); err == nil {
+		t.Fatal("updating response twice should be rejected")
+	}
+
+	nonce, err := s.GetNonce(id)
+	if err != nil || nonce != 7 {
+		t.Fatalf("unexpected nonce %v: %v", nonce, err)
+	}
+
+	msg, err = s.GetMsg(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Resp.Tx.Hash() != tx.Hash() {
+		t.Fatal("tx was not restored")
+	}
+	var rpcErr rpc.DataError
+	if !errors.As(msg.Resp.Err, &rpcErr) || msg.Resp.Err.Error() != "nonce too low" || rpcErr.ErrorData() != "0x01" {
+		t.Fatalf("error was not restored: %v", msg.Resp.Err)
+	}
+
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), BlockNumber: big.NewInt(5), Logs: []*types.Log{}}
+	if err := s.UpdateReceipt(id, Receipt{Id: id, TxReceipt: receipt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateMsgStatus(id, MessageStatusOnChain); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = s.GetMsg(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != MessageStatusOnChain || msg.Receipt.TxReceipt.TxHash != tx.Hash() || msg.Receipt.TxReceipt.BlockNumber.Uint64() != 5 {
+		t.Fatalf("unexpected msg: %+v", msg)
+	}
+
+	submitted, _ := mr.SIsMember(s.statusKey(MessageStatusSubmitted), id.Hex())
+	onChain, _ := mr.SIsMember(s.statusKey(MessageStatusOnChain), id.Hex())
+	if submitted || !onChain {
+		t.Fatal("status index was not updated")
+	}
+
+	ids, err := s.MsgIdsByStatus(MessageStatusOnChain)
+	if err != nil || len(ids) != 1 || ids[0] != id {
+		t.Fatalf("unexpected msgs on chain %v: %v", ids, err)
+	}
+	ids, err = s.MsgIdsByStatus(MessageStatusSubmitted)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("unexpected msgs submitted %v: %v", ids, err)
+	}
+}
+
+func Test_RedisStorage_QueryMsgs(t *testing.T) {
+	_, s := newTestRedisStorage(t)
+
+	addr1, addr2 := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	n := 2*redisQueryPageSize + 50
+	for i := 0; i < n; i++ {
+		req := (&Request{From: addr1}).SetRandomId()
+		if i%100 == 0 {
+			req.From = addr2
+		}
+		if err := s.AddMsg(*req); err != nil {
+			t.Fatal(err)
+		}
+		if i%50 == 0 {
+			if err := s.UpdateMsgStatus(req.Id(), MessageStatusInflight); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	all, err := s.QueryMsgs(MsgQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != n {
+		t.Fatalf("want %v msgs, got %v", n, len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].CreatedAt().Before(all[i-1].CreatedAt()) {
+			t.Fatal("want msgs by creation time")
+		}
+	}
+
+	query := func(q MsgQuery, want []Message) {
+		t.Helper()
+
+		msgs, err := s.QueryMsgs(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != len(want) {
+			t.Fatalf("want %v msgs, got %v", len(want), len(msgs))
+		}
+		for i := range msgs {
+			if msgs[i].Id() != want[i].Id() {
+				t.Fatalf("want msg %v at %v, got %v", want[i].Id(), i, msgs[i].Id())
+			}
+		}
+	}
+
+	// pages span the pages of ids loaded
+	query(MsgQuery{Offset: redisQueryPageSize - 5, Limit: 10}, all[redisQueryPageSize-5:redisQueryPageSize+5])
+	query(MsgQuery{Offset: n - 3, Limit: 10}, all[n-3:])
+
+	var inflight, inflightFromAddr1, fromAddr2 []Message
+	for _, msg := range all {
+		if msg.Status == MessageStatusInflight {
+			inflight = append(inflight, msg)
+			if msg.Req.From == addr1 {
+				inflightFromAddr1 = append(inflightFromAddr1, msg)
+			}
+		}
+		if msg.Req.From == addr2 {
+			fromAddr2 = append(fromAddr2, msg)
+		}
+	}
+	query(MsgQuery{From: []common.Address{addr2}}, fromAddr2)
+	query(MsgQuery{CreatedAfter: all[9].CreatedAt(), Limit: 1}, all[10:11])
+
+	// loaded from the status sets, fewer than the msgs of the index
+	query(MsgQuery{Statuses: []MessageStatus{MessageStatusInflight}, Offset: 1, Limit: 2}, inflight[1:3])
+	query(MsgQuery{From: []common.Address{addr1}, Statuses: []MessageStatus{MessageStatusInflight}}, inflightFromAddr1)
+	// paged through the index, with fewer msgs than the status sets
+	query(MsgQuery{From: []common.Address{addr2}, Statuses: []MessageStatus{MessageStatusInflight}}, fromAddr2)
+	query(MsgQuery{Statuses: []MessageStatus{MessageStatusSubmitted, MessageStatusInflight}, CreatedAfter: all[n-3].CreatedAt()}, all[n-2:])
+}
+
+func Test_Codec_JsonRpcError(t *testing.T) {
+	req := Request{}
+	req.SetRandomId()
+
+	data, err := encodeMessage(Message{Req: &req, Resp: &Response{Id: req.Id(), Err: &consts.JsonRpcError{Code: 3, Message: "execution reverted", Data: "0x"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := decodeMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonRpcErr *consts.JsonRpcError
+	if !errors.As(msg.Resp.Err, &jsonRpcErr) || jsonRpcErr.Code != 3 || jsonRpcErr.Message != "execution reverted" {
+		t.Fatalf("unexpected error: %v", msg.Resp.Err)
+	}
+}
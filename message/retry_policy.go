@@ -0,0 +1,186 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/gas"
+)
+
+// RetryAction is what the broadcaster does before sending a msg again after failing with some class of errors.
+type RetryAction int
+
+const (
+	// RetryActionNone fails the msg
+	RetryActionNone RetryAction = iota
+	// RetryActionRetry sends the msg again as is, e.g. after being rate limited
+	RetryActionRetry
+	// RetryActionResyncNonce syncs the nonce of the sender with the node before sending the msg again
+	RetryActionResyncNonce
+	// RetryActionBumpFee sends the msg again with fees bumped
+	RetryActionBumpFee
+	// RetryActionTrackTx considers the tx sent, since the node knows it already
+	RetryActionTrackTx
+	// RetryActionWaitForFunds parks the msg until the balance of the sender recovers
+	RetryActionWaitForFunds
+)
+
+func (a RetryAction) String() string {
+	switch a {
+	case RetryActionNone:
+		return "none"
+	case RetryActionRetry:
+		return "retry"
+	case RetryActionResyncNonce:
+		return "resync nonce"
+	case RetryActionBumpFee:
+		return "bump fee"
+	case RetryActionTrackTx:
+		return "track tx"
+	case RetryActionWaitForFunds:
+		return "wait for funds"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(a))
+	}
+}
+
+// RetryPolicy decides how msgs failed to send are retried, based on the class of their errors.
+type RetryPolicy struct {
+	// Actions maps error classes of consts, e.g. consts.ErrNonceTooLow, to what's done on them.
+	// Errors of other classes or not classified are not retried.
+	Actions map[error]RetryAction
+	// MaxAttempts is the max number of sends of a msg, the first one included.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled on each retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FundsTimeout is how long a msg is parked waiting for the balance of its sender to recover, 0 for no limit.
+	FundsTimeout time.Duration
+}
+
+// DefaultRetryPolicy never retries reverts, which are deterministic, nor errors not classified,
+// since the tx may have been sent.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Actions: map[error]RetryAction{
+			consts.ErrNonceTooLow:            RetryActionResyncNonce,
+			consts.ErrReplacementUnderpriced: RetryActionBumpFee,
+			consts.ErrFeeTooLow:              RetryActionBumpFee,
+			consts.ErrAlreadyKnown:           RetryActionTrackTx,
+			consts.ErrInsufficientFunds:      RetryActionWaitForFunds,
+			consts.ErrRateLimited:            RetryActionRetry,
+			consts.ErrExecutionReverted:      RetryActionNone,
+		},
+		MaxAttempts:  5,
+		Backoff:      time.Second,
+		MaxBackoff:   30 * time.Second,
+		FundsTimeout: 10 * time.Minute,
+	}
+}
+
+// Action returns what's done on err.
+func (p RetryPolicy) Action(err error) RetryAction {
+	class := consts.ClassifyError(err)
+	if class == nil {
+		return RetryActionNone
+	}
+
+	return p.Actions[class]
+}
+
+// backoff returns the delay before the retry following attempt sends.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	return backoff
+}
+
+// RetryRecord records a retry of a msg failed to send.
+type RetryRecord struct {
+	Attempt int           `json:"attempt"` // sends failed so far
+	Time    time.Time     `json:"time"`
+	Err     string        `json:"err"`
+	Action  RetryAction   `json:"action"`
+	Backoff time.Duration `json:"backoff"`
+}
+
+// retryBackend is implemented by managers supporting every retry action, e.g. SimpleManager.
+type retryBackend interface {
+	SyncNonce(ctx context.Context, account common.Address) error
+	SuggestFees(ctx context.Context, urgency gas.Urgency) (gas.Fees, error)
+	PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error)
+}
+
+// bumpRequestFees bumps the fees of req, filling the ones not set with the current suggestions first.
+func bumpRequestFees(ctx context.Context, backend retryBackend, req *Request) error {
+	if req.GasPrice == nil && (req.GasTipCap == nil || req.GasFeeCap == nil) {
+		fees, err := backend.SuggestFees(ctx, req.Urgency)
+		if err != nil {
+			return err
+		}
+
+		if fees.GasFeeCap == nil {
+			req.GasPrice = fees.GasPrice
+		} else {
+			req.GasTipCap = maxFee(req.GasTipCap, fees.GasTipCap)
+			req.GasFeeCap = maxFee(req.GasFeeCap, fees.GasFeeCap)
+		}
+	}
+
+	if req.GasPrice != nil {
+		req.GasPrice = bumpFee(req.GasPrice)
+		return nil
+	}
+
+	req.GasTipCap = bumpFee(req.GasTipCap)
+	req.GasFeeCap = bigMax(bumpFee(req.GasFeeCap), req.GasTipCap)
+
+	return nil
+}
+
+// waitForFunds waits until the balance of account gets higher, polling it every interval.
+func waitForFunds(ctx context.Context, backend retryBackend, account common.Address, interval, timeout time.Duration) error {
+	balance, err := backend.PendingBalanceAt(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		interval = consts.RetryInterval
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("balance of %v not recovered in %v", account.Hex(), timeout)
+		case <-time.After(interval):
+		}
+
+		current, err := backend.PendingBalanceAt(ctx, account)
+		if err != nil {
+			continue
+		}
+
+		if current.Cmp(balance) > 0 {
+			return nil
+		}
+	}
+}
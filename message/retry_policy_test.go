@@ -0,0 +1,197 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/gas"
+)
+
+type nodeError struct {
+	msg string
+}
+
+func (e nodeError) Error() string  { return e.msg }
+func (e nodeError) ErrorCode() int { return -32000 }
+
+// fakeRetryManager fails the sends of msgs with errs in order.
+type fakeRetryManager struct {
+	Storage
+	ScheduleManager
+
+	errs     []error
+	sent     []Request
+	synced   int
+	balances []*big.Int
+}
+
+func (m *fakeRetryManager) SendMsg(ctx context.Context, msg Request) (resp Response) {
+	m.sent = append(m.sent, msg)
+	resp = Response{Id: msg.Id(), Tx: types.NewTx(&types.LegacyTx{Nonce: uint64(len(m.sent))})}
+	if len(m.errs) > 0 {
+		resp.Err, m.errs = m.errs[0], m.errs[1:]
+	}
+	return
+}
+
+func (m *fakeRetryManager) WaitMsgResponse(msgId common.Hash, timeout time.Duration) (*Response, bool) {
+	return nil, false
+}
+
+func (m *fakeRetryManager) SyncNonce(ctx context.Context, account common.Address) error {
+	m.synced++
+	return nil
+}
+
+func (m *fakeRetryManager) SuggestFees(ctx context.Context, urgency gas.Urgency) (gas.Fees, error) {
+	return gas.Fees{GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100)}, nil
+}
+
+func (m *fakeRetryManager) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
+	balance := m.balances[0]
+	if len(m.balances) > 1 {
+		m.balances = m.balances[1:]
+	}
+	return balance, nil
+}
+
+func newTestRetry(t *testing.T, errs ...error) (*SimpleBroadcaster, *fakeRetryManager, Request, Response) {
+	storage, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &fakeRetryManager{Storage: storage, errs: errs, balances: []*big.Int{big.NewInt(0)}}
+	broadcaster := NewSimpleBroadcaster(manager)
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	policy.MaxBackoff = 4 * time.Millisecond
+	broadcaster.SetRetryPolicy(policy)
+
+	req := (&Request{From: common.HexToAddress("0x1"), To: &common.Address{}}).SetRandomId()
+	if err := storage.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := manager.SendMsg(context.Background(), *req)
+	return broadcaster, manager, *req, resp
+}
+
+func TestRetryMsg_ResyncNonce(t *testing.T) {
+	broadcaster, manager, req, resp := newTestRetry(t, nodeError{"nonce too low"}, nodeError{"nonce too low"})
+
+	if !broadcaster.ShouldRetry(resp.Err) {
+		t.Fatal("want nonce too low retried")
+	}
+
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if len(manager.sent) != 3 || manager.synced != 2 {
+		t.Fatalf("want 3 sends and 2 nonce syncs, got %v and %v", len(manager.sent), manager.synced)
+	}
+
+	msg, err := manager.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Retries) != 2 || msg.Retries[1].Attempt != 2 || msg.Retries[1].Action != RetryActionResyncNonce {
+		t.Fatalf("unexpected retries recorded: %+v", msg.Retries)
+	}
+	if msg.Retries[1].Backoff != 2*msg.Retries[0].Backoff {
+		t.Fatalf("backoff was not doubled: %+v", msg.Retries)
+	}
+}
+
+func TestRetryMsg_BumpFee(t *testing.T) {
+	broadcaster, manager, req, resp := newTestRetry(t, nodeError{"replacement transaction underpriced"})
+
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	retried := manager.sent[1]
+	if retried.GasTipCap.Cmp(big.NewInt(12)) != 0 || retried.GasFeeCap.Cmp(big.NewInt(120)) != 0 {
+		t.Fatalf("want fees bumped from suggestions, got tip %v fee cap %v", retried.GasTipCap, retried.GasFeeCap)
+	}
+
+	msg, err := manager.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Req.GasFeeCap.Cmp(big.NewInt(120)) != 0 {
+		t.Fatalf("fees bumped were not persisted: %v", msg.Req.GasFeeCap)
+	}
+}
+
+func TestRetryMsg_TrackTx(t *testing.T) {
+	broadcaster, manager, req, resp := newTestRetry(t, nodeError{"already known"})
+
+	retried := broadcaster.RetryMsg(context.Background(), req, resp)
+	if retried.Err != nil {
+		t.Fatal(retried.Err)
+	}
+	if len(manager.sent) != 1 || retried.Tx.Hash() != resp.Tx.Hash() {
+		t.Fatal("want the tx known tracked without sending again")
+	}
+
+	msg, err := manager.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != MessageStatusInflight {
+		t.Fatalf("want msg inflight, got %v", msg.Status)
+	}
+}
+
+func TestRetryMsg_WaitForFunds(t *testing.T) {
+	broadcaster, manager, req, resp := newTestRetry(t, nodeError{"insufficient funds for gas * price + value"})
+	manager.balances = []*big.Int{big.NewInt(1), big.NewInt(1), big.NewInt(2)}
+
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if len(manager.sent) != 2 || len(manager.balances) != 1 {
+		t.Fatalf("want msg sent again once the balance recovered, got %v sends", len(manager.sent))
+	}
+
+	broadcaster, _, req, resp = newTestRetry(t, nodeError{"insufficient funds for gas * price + value"})
+	broadcaster.retryPolicy.FundsTimeout = 10 * time.Millisecond
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err == nil {
+		t.Fatal("want error if the balance does not recover")
+	}
+}
+
+func TestRetryMsg_NotRetried(t *testing.T) {
+	broadcaster, manager, req, resp := newTestRetry(t, nodeError{"execution reverted"})
+	if broadcaster.ShouldRetry(resp.Err) {
+		t.Fatal("want reverts not retried")
+	}
+	if broadcaster.ShouldRetry(errors.New("connection reset by peer")) {
+		t.Fatal("want errors not classified not retried")
+	}
+
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err == nil || len(manager.sent) != 1 {
+		t.Fatal("want reverts not retried")
+	}
+
+	errs := make([]error, 10)
+	for i := range errs {
+		errs[i] = nodeError{"429 Too Many Requests"}
+	}
+	broadcaster, manager, req, resp = newTestRetry(t, errs...)
+	resp = broadcaster.RetryMsg(context.Background(), req, resp)
+	if resp.Err == nil || len(manager.sent) != broadcaster.retryPolicy.MaxAttempts {
+		t.Fatalf("want %v sends at most, got %v", broadcaster.retryPolicy.MaxAttempts, len(manager.sent))
+	}
+}
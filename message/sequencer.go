@@ -1,5 +1,7 @@
 package message
 
+import "github.com/ethereum/go-ethereum/common"
+
 type Sequencer interface {
 	PushMsg(msg Request) error
 	// block if no any msgs return
@@ -9,3 +11,9 @@ type Sequencer interface {
 	PendingMsgCount() (int, error)
 	Close()
 }
+
+// SequencerAcker is implemented by sequencers redelivering the msgs popped
+// unless acked in time, e.g. as their consumer crashed.
+type SequencerAcker interface {
+	AckMsg(msgId common.Hash) error
+}
@@ -2,37 +2,91 @@ package message
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ivanzzeth/ethclient/account"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/gas"
 	"github.com/ivanzzeth/ethclient/nonce"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var _ Manager = (*SimpleManager)(nil)
 
 type SimpleManager struct {
-	backend ethBackend
-	nm      nonce.Manager
+	backend   ethBackend
+	nm        nonce.Manager
+	gasOracle gas.Oracle
+	onStatus  StatusHandler
 	account.Registry
 	Storage
 }
 
+// StatusHandler is called each time the status of a msg is updated.
+type StatusHandler func(msgId common.Hash, status MessageStatus)
+
 func NewSimpleManager(backend ethBackend, nm nonce.Manager, accountRegistry account.Registry, storage Storage) *SimpleManager {
 	return &SimpleManager{
-		backend:  backend,
-		nm:       nm,
-		Registry: accountRegistry,
-		Storage:  storage,
+		backend:   backend,
+		nm:        nm,
+		gasOracle: gas.NewNodeOracle(backend),
+		Registry:  accountRegistry,
+		Storage:   storage,
 	}
 }
 
+func (c *SimpleManager) SetGasOracle(oracle gas.Oracle) {
+	c.gasOracle = oracle
+}
+
+func (c *SimpleManager) GetGasOracle() gas.Oracle {
+	return c.gasOracle
+}
+
+// SetStatusHandler sets the handler called when the manager updates the status of msgs, before sending any msg.
+func (c *SimpleManager) SetStatusHandler(handler StatusHandler) {
+	c.onStatus = handler
+}
+
+// UpdateMsgStatus updates the status of msgId in the storage, then calls the status handler.
+func (c SimpleManager) UpdateMsgStatus(msgId common.Hash, status MessageStatus) error {
+	err := c.Storage.UpdateMsgStatus(msgId, status)
+	if err == nil && c.onStatus != nil {
+		c.onStatus(msgId, status)
+	}
+	return err
+}
+
+// SwapMsgStatus sets the status of msgId to status if it's one of from, see StorageStatusSwapper.
+func (c SimpleManager) SwapMsgStatus(msgId common.Hash, from []MessageStatus, status MessageStatus) error {
+	err := SwapMsgStatus(c.Storage, msgId, from, status)
+	if err == nil && c.onStatus != nil {
+		c.onStatus(msgId, status)
+	}
+	return err
+}
+
+// SuggestFees returns the fees suggested by the gas oracle for urgency, within its ceilings.
+func (c SimpleManager) SuggestFees(ctx context.Context, urgency gas.Urgency) (gas.Fees, error) {
+	fees, err := c.gasOracle.SuggestFees(ctx, urgency)
+	if err != nil {
+		return gas.Fees{}, err
+	}
+
+	return gas.Limit(c.gasOracle, fees), nil
+}
+
 func (c *SimpleManager) CallAndSendMsg(ctx context.Context, msg Request) (resp Response) {
 	// err := c.AddMsg(msg)
 	// if err != nil {
@@ -56,6 +110,8 @@ func (c *SimpleManager) CallMsg(ctx context.Context, msg Request, blockNumber *b
 		To:         msg.To,
 		Gas:        msg.Gas,
 		GasPrice:   msg.GasPrice,
+		GasFeeCap:  msg.GasFeeCap,
+		GasTipCap:  msg.GasTipCap,
 		Value:      msg.Value,
 		Data:       msg.Data,
 		AccessList: msg.AccessList,
@@ -78,6 +134,8 @@ func (m SimpleManager) SendMsg(ctx context.Context, msg Request) (resp Response)
 
 	signedTx, err := m.sendMsg(ctx, msg)
 	if err != nil {
+		// The tx is kept if signed, e.g. it may be known by the node already.
+		resp.Tx = signedTx
 		resp.Err = err
 		return
 	}
@@ -110,12 +168,46 @@ func (m SimpleManager) ReplaceMsgWithHigherGasPrice(ctx context.Context, msgId c
 	return
 }
 
-// func (m SimpleManager) ReplaceMsg(msgId common.Hash, newMsg Request) (resp Response) {
-// 	return Response{}
-// }
+func (m SimpleManager) ReplaceMsg(ctx context.Context, msgId common.Hash, newMsg Request) (resp Response) {
+	log.Info("replace message", "msgId", msgId, "newMsgId", newMsg.Id())
+	resp.Id = newMsg.Id()
+
+	signedTx, err := m.replaceMsg(ctx, msgId, newMsg)
+	if err != nil {
+		resp.Err = err
+		return
+	}
+
+	resp = Response{
+		Id:  newMsg.Id(),
+		Tx:  signedTx,
+		Err: err,
+	}
+
+	return
+}
+
+func (m SimpleManager) CancelMsg(ctx context.Context, msgId common.Hash) (resp Response) {
+	log.Info("cancel message", "msgId", msgId)
+	resp.Id = msgId
+
+	signedTx, err := m.cancelMsg(ctx, msgId)
+	if err != nil {
+		resp.Err = err
+		return
+	}
+
+	resp = Response{
+		Id:  msgId,
+		Tx:  signedTx,
+		Err: err,
+	}
+
+	return
+}
 
 func (c SimpleManager) NewTransaction(ctx context.Context, msg Request) (*types.Transaction, error) {
-	return c.newTransactionWithNonce(ctx, msg, 0)
+	return c.newTransactionWithNonce(ctx, msg, nil)
 }
 
 func (c SimpleManager) MessageToTransactOpts(ctx context.Context, msg Request) (*bind.TransactOpts, error) {
@@ -132,42 +224,71 @@ func (c SimpleManager) MessageToTransactOpts(ctx context.Context, msg Request) (
 	auth.Value = msg.Value
 	auth.GasLimit = msg.Gas
 	auth.GasPrice = msg.GasPrice
+	auth.GasFeeCap = msg.GasFeeCap
+	auth.GasTipCap = msg.GasTipCap
+
+	if auth.GasPrice == nil && auth.GasFeeCap == nil && auth.GasTipCap == nil {
+		fees, err := c.SuggestFees(ctx, msg.Urgency)
+		if err != nil {
+			return nil, err
+		}
+
+		if fees.GasFeeCap != nil {
+			auth.GasFeeCap = fees.GasFeeCap
+			auth.GasTipCap = fees.GasTipCap
+		} else {
+			auth.GasPrice = fees.GasPrice
+		}
+	}
 
 	return auth, nil
 }
 
 func (c SimpleManager) WaitTxReceipt(txHash common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool) {
-	startTime := time.Now()
-	retryCount := 0
-	for ; ; retryCount++ {
-		log.Debug("wait tx receipt", "txHash", txHash.Hex(), "retryCount", retryCount)
-		currTime := time.Now()
-		elapsedTime := currTime.Sub(startTime)
-		if elapsedTime >= timeout {
-			return nil, false
-		}
+	return c.WaitTxsReceipt(context.Background(), []common.Hash{txHash}, confirmations, timeout)
+}
 
-		receipt, err := c.backend.TransactionReceipt(context.Background(), txHash)
-		// log.Debug("WaitTxReceipt.TransactionReceipt", "receipt", receipt, "err", err)
-		if err != nil {
-			time.Sleep(1 * time.Second)
-			continue
-		}
+// WaitTxsReceipt waits for the receipt of any of txHashes, e.g. the txs sent for a msg replaced with higher fees,
+// until timeout or ctx is done.
+func (c SimpleManager) WaitTxsReceipt(ctx context.Context, txHashes []common.Hash, confirmations uint64, timeout time.Duration) (*types.Receipt, bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-		block, err := c.backend.BlockNumber(context.Background())
-		// log.Debug("WaitTxReceipt.BlockNumber", "block", block, "err", err)
+	for retryCount := 0; ; retryCount++ {
+		log.Debug("wait tx receipt", "txHashes", txHashes, "retryCount", retryCount)
 
-		if err != nil {
-			time.Sleep(1 * time.Second)
-			continue
+		if receipt, ok := c.confirmedReceipt(ctx, txHashes, confirmations); ok {
+			return receipt, true
 		}
 
-		if block >= receipt.BlockNumber.Uint64()+confirmations {
-			return receipt, true
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(1 * time.Second):
 		}
+	}
+}
 
-		time.Sleep(1 * time.Second)
+// confirmedReceipt returns the receipt of any of txHashes if confirmed.
+func (c SimpleManager) confirmedReceipt(ctx context.Context, txHashes []common.Hash, confirmations uint64) (*types.Receipt, bool) {
+	var receipt *types.Receipt
+	for _, txHash := range txHashes {
+		r, err := c.backend.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			receipt = r
+			break
+		}
+	}
+	if receipt == nil {
+		return nil, false
+	}
+
+	block, err := c.backend.BlockNumber(ctx)
+	if err != nil {
+		return nil, false
 	}
+
+	return receipt, block >= receipt.BlockNumber.Uint64()+confirmations
 }
 
 func (c SimpleManager) WaitMsgResponse(msgId common.Hash, timeout time.Duration) (*Response, bool) {
@@ -258,30 +379,181 @@ func (c *SimpleManager) callAndSendMsg(ctx context.Context, msg Request) (resp R
 	return
 }
 
+// sendableStatuses are the statuses msgs are sent from, i.e. any but cancelled.
+var sendableStatuses = []MessageStatus{
+	MessageStatusSubmitted, MessageStatusScheduled, MessageStatusQueued, MessageStatusNonceAssigned, MessageStatusInflight,
+	MessageStatusOnChain, MessageStatusFinalized, MessageStatusNonceReleased, MessageStatusExpired, MessageStatusFailed,
+}
+
 func (m SimpleManager) sendMsg(ctx context.Context, msg Request) (signedTx *types.Transaction, err error) {
 	log.Debug("broadcast msg", "msg", msg)
-	tx, err := m.NewTransaction(ctx, msg)
+	nonce, err := m.nm.ReserveNonce(ctx, msg.From)
 	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.newTransactionWithNonce(ctx, msg, &nonce)
+	if err != nil {
+		m.releaseNonce(msg.Id(), msg.From, nonce)
 		return nil, fmt.Errorf("NewTransaction err: %v", err)
 	}
 
-	err = m.UpdateMsgStatus(msg.Id(), MessageStatusNonceAssigned)
+	// Atomically, so that a msg cancelled meanwhile is not sent.
+	err = m.SwapMsgStatus(msg.Id(), sendableStatuses, MessageStatusNonceAssigned)
+	if errors.Is(err, ErrMsgStatusChanged) {
+		log.Info("msg cancelled before sent", "msgId", msg.Id().Hex())
+		if err := m.nm.ReleaseNonce(msg.From, nonce); err != nil {
+			log.Error("release nonce failed", "msgId", msg.Id().Hex(), "from", msg.From.Hex(), "nonce", nonce, "err", err)
+		}
+		return nil, ErrMsgCancelled
+	}
 	if err != nil {
+		m.releaseNonce(msg.Id(), msg.From, nonce)
 		return nil, err
 	}
 
 	signedTx, err = m.signMsgAndBroadcast(ctx, msg.Id(), msg.From, tx)
-
 	if err != nil {
-		return nil, err
+		if txMaybeSent(signedTx, err) {
+			m.nm.CommitNonce(msg.From, nonce)
+		} else {
+			m.releaseNonce(msg.Id(), msg.From, nonce)
+		}
+		return signedTx, err
 	}
 
+	m.nm.CommitNonce(msg.From, nonce)
+
 	log.Info("Send Message successfully", "msgId", msg.Id(), "txHash", signedTx.Hash().Hex(), "from", msg.From.Hex(),
 		"to", msg.To.Hex(), "value", msg.Value, "nonce", signedTx.Nonce())
 
 	return signedTx, nil
 }
 
+// SyncNonce syncs the nonce of account with the node, see nonce.Manager.
+func (m SimpleManager) SyncNonce(ctx context.Context, account common.Address) error {
+	return m.nm.SyncNonce(ctx, account)
+}
+
+func (m SimpleManager) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
+	return m.backend.PendingBalanceAt(ctx, account)
+}
+
+// FillNonceGaps sends zero-value self-transfers with the nonces of account left unused,
+// which block the txs with higher nonces otherwise. Nonces held by msgs in the storage are not filled,
+// e.g. of txs sent by other processes or evicted from the mempool of the node and to be sent again.
+func (m SimpleManager) FillNonceGaps(ctx context.Context, account common.Address) (txs []*types.Transaction, err error) {
+	inUse, err := m.noncesInUse(account)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps, err := m.nm.ReserveNonceGaps(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, nonce := range gaps {
+		if _, ok := inUse[nonce]; ok {
+			log.Info("Nonce gap held by a msg, not filled", "account", account.Hex(), "nonce", nonce)
+			m.nm.CommitNonce(account, nonce)
+			continue
+		}
+
+		var signedTx *types.Transaction
+		req := &Request{From: account, To: &account, Value: big.NewInt(0), Gas: params.TxGas}
+		tx, err := m.newTransactionWithNonce(ctx, *req, &nonce)
+		if err == nil {
+			signedTx, err = m.signAndBroadcast(ctx, account, tx)
+		}
+
+		if err != nil {
+			if txMaybeSent(signedTx, err) {
+				m.nm.CommitNonce(account, nonce)
+			} else {
+				m.nm.ReleaseNonce(account, nonce)
+			}
+			for _, n := range gaps[i+1:] {
+				m.nm.ReleaseNonce(account, n)
+			}
+			return txs, fmt.Errorf("fill nonce gap %v err: %v", nonce, err)
+		}
+
+		m.nm.CommitNonce(account, nonce)
+		txs = append(txs, signedTx)
+
+		log.Info("Fill nonce gap successfully", "account", account.Hex(), "nonce", nonce, "txHash", signedTx.Hash().Hex())
+	}
+
+	return txs, nil
+}
+
+// noncesInUse returns the nonces of the txs sent for the msgs of account not finished yet.
+// It fails if a msg was assigned a nonce not stored yet, which may then be any gap.
+func (m SimpleManager) noncesInUse(account common.Address) (map[uint64]struct{}, error) {
+	querier, ok := m.Storage.(StorageQuerier)
+	if !ok {
+		return nil, errors.New("msg storage does not support queries, nonces in use are unknown")
+	}
+
+	msgs, err := querier.QueryMsgs(MsgQuery{
+		Statuses: []MessageStatus{MessageStatusNonceAssigned, MessageStatusInflight, MessageStatusCancelled},
+		From:     []common.Address{account},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := make(map[uint64]struct{})
+	for _, msg := range msgs {
+		if msg.Resp != nil && msg.Resp.Tx != nil {
+			inUse[msg.Resp.Tx.Nonce()] = struct{}{}
+			continue
+		}
+
+		if msg.Status == MessageStatusNonceAssigned && msg.Resp == nil {
+			return nil, fmt.Errorf("nonce of msg %v being sent is unknown", msg.Id().Hex())
+		}
+	}
+
+	return inUse, nil
+}
+
+// releaseNonce gives back the nonce of a msg not sent, marking it as MessageStatusFailed.
+func (m SimpleManager) releaseNonce(msgId common.Hash, from common.Address, nonce uint64) {
+	err := m.nm.ReleaseNonce(from, nonce)
+	if err != nil {
+		log.Error("release nonce failed", "msgId", msgId.Hex(), "from", from.Hex(), "nonce", nonce, "err", err)
+		return
+	}
+
+	err = m.UpdateMsgStatus(msgId, MessageStatusFailed)
+	if err != nil {
+		log.Error("update msg status failed", "msgId", msgId.Hex(), "err", err)
+	}
+}
+
+// txMaybeSent reports whether a tx failed to send may have reached the node anyway,
+// in which case its nonce can not be given back.
+func txMaybeSent(signedTx *types.Transaction, err error) bool {
+	if signedTx == nil {
+		// Not signed.
+		return false
+	}
+
+	if errors.Is(err, consts.ErrAlreadyKnown) {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		// Rejected by the node.
+		return false
+	}
+
+	return true
+}
+
 func (m SimpleManager) replaceMsgWithHigherGasPrice(ctx context.Context, msgId common.Hash) (signedTx *types.Transaction, err error) {
 	log.Debug("replace msg with higher gas price", "msgId", msgId)
 	msg, err := m.GetMsg(msgId)
@@ -293,10 +565,22 @@ func (m SimpleManager) replaceMsgWithHigherGasPrice(ctx context.Context, msgId c
 		return nil, fmt.Errorf("no nonce assigned")
 	}
 
-	msg.Req.GasPrice = big.NewInt(0).Mul(msg.Resp.Tx.GasPrice(), big.NewInt(12))
-	msg.Req.GasPrice.Div(msg.Req.GasPrice, big.NewInt(10))
+	switch msg.Status {
+	case MessageStatusNonceReleased:
+		return nil, fmt.Errorf("nonce of msg was released")
+	case MessageStatusCancelled:
+		// Bump the self-transfer cancelling it instead.
+		return m.cancelMsg(ctx, msgId)
+	}
+
+	req := msg.Req.Copy()
+	err = m.bumpFees(ctx, req, msg.Resp.Tx)
+	if err != nil {
+		return nil, err
+	}
 
-	tx, err := m.newTransactionWithNonce(ctx, *msg.Req, msg.Resp.Tx.Nonce())
+	nonce := msg.Resp.Tx.Nonce()
+	tx, err := m.newTransactionWithNonce(ctx, *req, &nonce)
 	if err != nil {
 		return nil, fmt.Errorf("NewTransaction err: %v", err)
 	}
@@ -312,12 +596,222 @@ func (m SimpleManager) replaceMsgWithHigherGasPrice(ctx context.Context, msgId c
 		return nil, err
 	}
 
+	// Persist the replacement, so that it's the one being watched from now on.
+	msg, err = m.GetMsg(msgId)
+	if err != nil {
+		return nil, err
+	}
+	msg.Req = req
+	msg.Resp = &Response{Id: msgId, Tx: signedTx}
+	err = m.UpdateMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Info("Replace and send Message successfully", "msgId", msgId, "txHash", signedTx.Hash().Hex(), "from", msg.Req.From.Hex(),
 		"to", msg.Req.To.Hex(), "value", msg.Req.Value)
 
 	return signedTx, nil
 }
 
+func (m SimpleManager) replaceMsg(ctx context.Context, msgId common.Hash, newMsg Request) (signedTx *types.Transaction, err error) {
+	msg, err := m.GetMsg(msgId)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Status != MessageStatusNonceAssigned && msg.Status != MessageStatusInflight {
+		return nil, fmt.Errorf("msg can not be replaced in status %v", msg.Status)
+	}
+
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		return nil, fmt.Errorf("no nonce assigned")
+	}
+
+	if newMsg.From != msg.Req.From {
+		return nil, fmt.Errorf("msg can only be replaced by one from the same account %v", msg.Req.From.Hex())
+	}
+
+	if newMsg.Id() == (common.Hash{}) {
+		return nil, fmt.Errorf("no msgId provided")
+	}
+
+	// Fees set on the new msg are kept if higher than the ones required for replacing.
+	req := newMsg.Copy()
+	err = m.bumpFees(ctx, req, msg.Resp.Tx)
+	if err != nil {
+		return nil, err
+	}
+	req.GasPrice = maxFee(req.GasPrice, newMsg.GasPrice)
+	req.GasTipCap = maxFee(req.GasTipCap, newMsg.GasTipCap)
+	req.GasFeeCap = maxFee(req.GasFeeCap, newMsg.GasFeeCap)
+
+	nonce := msg.Resp.Tx.Nonce()
+	tx, err := m.newTransactionWithNonce(ctx, *req, &nonce)
+	if err != nil {
+		return nil, fmt.Errorf("NewTransaction err: %v", err)
+	}
+
+	err = m.AddMsg(*req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.UpdateMsgStatus(req.Id(), MessageStatusNonceAssigned)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err = m.signMsgAndBroadcast(ctx, req.Id(), req.From, tx)
+	if err != nil {
+		m.UpdateResponse(req.Id(), Response{Id: req.Id(), Err: err})
+		return nil, err
+	}
+
+	err = m.UpdateResponse(req.Id(), Response{Id: req.Id(), Tx: signedTx})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.UpdateMsgStatus(msgId, MessageStatusNonceReleased)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Replace Message successfully", "msgId", msgId, "newMsgId", req.Id(), "txHash", signedTx.Hash().Hex(),
+		"from", req.From.Hex(), "nonce", nonce)
+
+	return signedTx, nil
+}
+
+func (m SimpleManager) cancelMsg(ctx context.Context, msgId common.Hash) (signedTx *types.Transaction, err error) {
+	msg, err := m.GetMsg(msgId)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Status != MessageStatusNonceAssigned && msg.Status != MessageStatusInflight && msg.Status != MessageStatusCancelled {
+		return nil, fmt.Errorf("msg can not be cancelled in status %v", msg.Status)
+	}
+
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		return nil, fmt.Errorf("no nonce assigned")
+	}
+
+	req := cancelRequest(msg.Req)
+	err = m.bumpFees(ctx, req, msg.Resp.Tx)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := msg.Resp.Tx.Nonce()
+	tx, err := m.newTransactionWithNonce(ctx, *req, &nonce)
+	if err != nil {
+		return nil, fmt.Errorf("NewTransaction err: %v", err)
+	}
+
+	signedTx, err = m.signAndBroadcast(ctx, req.From, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The original request is kept, the self-transfer is the tx being watched from now on.
+	msg, err = m.GetMsg(msgId)
+	if err != nil {
+		return nil, err
+	}
+	msg.Resp = &Response{Id: msgId, Tx: signedTx}
+	msg.Status = MessageStatusCancelled
+	err = m.UpdateMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Cancel Message successfully", "msgId", msgId, "txHash", signedTx.Hash().Hex(), "from", req.From.Hex(), "nonce", nonce)
+
+	return signedTx, nil
+}
+
+// cancelRequest returns the zero-value self-transfer replacing the tx of req.
+func cancelRequest(req *Request) *Request {
+	return (&Request{
+		From:    req.From,
+		To:      &req.From,
+		Value:   big.NewInt(0),
+		Gas:     params.TxGas,
+		Urgency: req.Urgency,
+	}).SetId(req.Id())
+}
+
+// ErrReplacementCapped is returned when the fee ceilings of the gas oracle prevent bumping
+// the fees of a tx enough for nodes to accept its replacement.
+var ErrReplacementCapped = errors.New("fee ceilings prevent replacing tx")
+
+// bumpFees sets the fees of req for replacing tx. Nodes only accept a replacement bumping
+// every fee field by at least 10%, so tip and fee cap are both bumped by 20% for dynamic fee txs,
+// and kept at least as high as the current suggestions.
+// It returns ErrReplacementCapped if the ceilings of the gas oracle keep the fees under 10% more.
+func (m SimpleManager) bumpFees(ctx context.Context, req *Request, tx *types.Transaction) error {
+	suggested, err := m.SuggestFees(ctx, req.Urgency)
+	if err != nil {
+		return err
+	}
+
+	if tx.Type() != types.DynamicFeeTxType {
+		req.GasPrice = maxFee(bumpFee(tx.GasPrice()), suggested.GasPrice)
+
+		limited := gas.Limit(m.gasOracle, gas.Fees{GasPrice: req.GasPrice})
+		if limited.GasPrice.Cmp(minReplacementFee(tx.GasPrice())) < 0 {
+			return fmt.Errorf("%w %v: gasPrice %v capped to %v", ErrReplacementCapped, tx.Hash().Hex(), req.GasPrice, limited.GasPrice)
+		}
+		return nil
+	}
+
+	req.GasPrice = nil
+	req.GasTipCap = maxFee(bumpFee(tx.GasTipCap()), suggested.GasTipCap)
+	req.GasFeeCap = maxFee(bumpFee(tx.GasFeeCap()), suggested.GasFeeCap)
+	req.GasFeeCap = bigMax(req.GasFeeCap, req.GasTipCap)
+
+	limited := gas.Limit(m.gasOracle, gas.Fees{GasTipCap: req.GasTipCap, GasFeeCap: req.GasFeeCap})
+	if limited.GasTipCap.Cmp(minReplacementFee(tx.GasTipCap())) < 0 || limited.GasFeeCap.Cmp(minReplacementFee(tx.GasFeeCap())) < 0 {
+		return fmt.Errorf("%w %v: gasTipCap %v and gasFeeCap %v capped to %v and %v", ErrReplacementCapped, tx.Hash().Hex(),
+			req.GasTipCap, req.GasFeeCap, limited.GasTipCap, limited.GasFeeCap)
+	}
+
+	return nil
+}
+
+// minReplacementFee returns fee * 1.1, the lowest fee nodes accept for replacing a tx paying fee.
+func minReplacementFee(fee *big.Int) *big.Int {
+	threshold := big.NewInt(0).Mul(fee, big.NewInt(11))
+	return threshold.Div(threshold, big.NewInt(10))
+}
+
+// bumpFee returns fee * 1.2, rounded up so that tiny fees (e.g. a tip of 1 wei) are bumped too.
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := big.NewInt(0).Mul(fee, big.NewInt(12))
+	bumped.Add(bumped, big.NewInt(9))
+	return bumped.Div(bumped, big.NewInt(10))
+}
+
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// maxFee is bigMax where nil means not set.
+func maxFee(a, b *big.Int) *big.Int {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return bigMax(a, b)
+}
+
 func (m SimpleManager) signMsgAndBroadcast(ctx context.Context, msgId common.Hash, from common.Address, tx *types.Transaction) (signedTx *types.Transaction, err error) {
 	// chainID, err := c.Client.ChainID(ctx)
 	// if err != nil {
@@ -329,43 +823,82 @@ func (m SimpleManager) signMsgAndBroadcast(ctx context.Context, msgId common.Has
 	// 	return nil, fmt.Errorf("SignTx err: %v", err)
 	// }
 
-	signerFn := m.GetSigner()
-	signedTx, err = signerFn(from, tx)
+	signedTx, err = m.signAndBroadcast(ctx, from, tx)
 	if err != nil {
-		return nil, err
+		return signedTx, err
 	}
 
-	err = m.backend.SendTransaction(ctx, signedTx)
+	err = m.UpdateMsgStatus(msgId, MessageStatusInflight)
 	if err != nil {
-		return nil, fmt.Errorf("SendTransaction err: %v", err)
+		return signedTx, err
 	}
-	log.Info("broadcasted transaction", "txHash", signedTx.Hash().Hex(), "from", from, "nonce", tx.Nonce())
 
-	err = m.UpdateMsgStatus(msgId, MessageStatusInflight)
+	return
+}
+
+// signAndBroadcast returns the signed tx along with the error if it's not sent.
+func (m SimpleManager) signAndBroadcast(ctx context.Context, from common.Address, tx *types.Transaction) (signedTx *types.Transaction, err error) {
+	_, span := startSpan(ctx, "ethclient.sign")
+	signerFn := m.GetSigner()
+	signedTx, err = signerFn(from, tx)
+	endSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
 
-	return
+	sendCtx, span := startSpan(ctx, "ethclient.send", TxAttributes(signedTx)...)
+	err = m.backend.SendTransaction(sendCtx, signedTx)
+	endSpan(span, err)
+	if err != nil {
+		// Decoded, so that errors.Is(err, consts.ErrNonceTooLow) and the like can be used.
+		return signedTx, fmt.Errorf("SendTransaction err: %w", consts.DecodeJsonRpcError(err, abi.ABI{}))
+	}
+	log.Info("broadcasted transaction", "txHash", signedTx.Hash().Hex(), "from", from, "nonce", tx.Nonce())
+
+	return signedTx, nil
 }
 
-func (c SimpleManager) newTransactionWithNonce(ctx context.Context, msg Request, nonce uint64) (tx *types.Transaction, err error) {
+// newTransactionWithNonce builds the tx of msg, assigning the next nonce of msg.From if nonce is nil.
+// A legacy tx is built if msg.GasPrice is set or London is not activated, otherwise a dynamic fee tx.
+func (c SimpleManager) newTransactionWithNonce(ctx context.Context, msg Request, nonce *uint64) (tx *types.Transaction, err error) {
 	if msg.To == nil {
 		to := common.HexToAddress("0x0")
 		msg.To = &to
 	}
 
+	legacy := msg.GasPrice != nil && msg.GasPrice.Sign() > 0
+
+	var head *types.Header
+	if !legacy {
+		head, err = c.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if head.BaseFee == nil {
+			if msg.GasFeeCap != nil || msg.GasTipCap != nil {
+				return nil, fmt.Errorf("maxFeePerGas or maxPriorityFeePerGas specified but london is not active yet")
+			}
+			legacy = true
+		}
+	}
+
 	if msg.Gas == 0 {
 		ethMesg := ethereum.CallMsg{
 			From:       msg.From,
 			To:         msg.To,
 			GasPrice:   msg.GasPrice,
+			GasFeeCap:  msg.GasFeeCap,
+			GasTipCap:  msg.GasTipCap,
 			Value:      msg.Value,
 			Data:       msg.Data,
 			AccessList: msg.AccessList,
 		}
 
-		gas, err := c.nm.EstimateGas(ctx, ethMesg)
+		estimateCtx, span := startSpan(ctx, "ethclient.estimate_gas")
+		gas, err := c.nm.EstimateGas(estimateCtx, ethMesg)
+		span.SetAttributes(attribute.Int64("gas", int64(gas)))
+		endSpan(span, err)
 		if err != nil {
 			if msg.GasOnEstimationFailed == nil {
 				return nil, err
@@ -373,8 +906,8 @@ func (c SimpleManager) newTransactionWithNonce(ctx context.Context, msg Request,
 
 			msg.Gas = *msg.GasOnEstimationFailed
 		} else {
-			// Multiplier 1.5
-			msg.Gas = gas * 1500 / 1000
+			// The nonce manager already leaves a margin on estimations.
+			msg.Gas = gas
 			// reach out max gas, then replace gas estimated with GasOnEstimationFailed
 			if msg.GasOnEstimationFailed != nil && msg.Gas > *msg.GasOnEstimationFailed {
 				log.Warn("reach out max gas, then replace gas estimated with GasOnEstimationFailed", "msgId",
@@ -384,24 +917,93 @@ func (c SimpleManager) newTransactionWithNonce(ctx context.Context, msg Request,
 		}
 	}
 
-	if msg.GasPrice == nil || msg.GasPrice.Uint64() == 0 {
-		var err error
-		msg.GasPrice, err = c.nm.SuggestGasPrice(ctx)
+	if (legacy && (msg.GasPrice == nil || msg.GasPrice.Sign() == 0)) || (!legacy && (msg.GasTipCap == nil || msg.GasFeeCap == nil)) {
+		fees, err := c.gasOracle.SuggestFees(ctx, msg.Urgency)
+		if err != nil {
+			return nil, err
+		}
+
+		if legacy {
+			if fees.GasPrice == nil {
+				return nil, fmt.Errorf("gas oracle suggested no gasPrice")
+			}
+			msg.GasPrice = fees.GasPrice
+		} else {
+			if msg.GasTipCap == nil {
+				msg.GasTipCap = fees.GasTipCap
+			}
+			if msg.GasTipCap == nil {
+				// The oracle has no EIP-1559 suggestion, e.g. a FixedOracle configured with a gas price only.
+				if fees.GasPrice == nil {
+					return nil, fmt.Errorf("gas oracle suggested neither gasTipCap nor gasPrice")
+				}
+				msg.GasTipCap = new(big.Int).Sub(fees.GasPrice, head.BaseFee)
+				if msg.GasTipCap.Sign() < 0 {
+					msg.GasTipCap.SetInt64(0)
+				}
+			}
+			if msg.GasFeeCap == nil {
+				msg.GasFeeCap = fees.GasFeeCap
+			}
+			if msg.GasFeeCap == nil {
+				msg.GasFeeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+				msg.GasFeeCap.Add(msg.GasFeeCap, msg.GasTipCap)
+			}
+		}
+	}
+
+	limited := gas.Limit(c.gasOracle, gas.Fees{GasPrice: msg.GasPrice, GasTipCap: msg.GasTipCap, GasFeeCap: msg.GasFeeCap})
+	msg.GasPrice, msg.GasTipCap, msg.GasFeeCap = limited.GasPrice, limited.GasTipCap, limited.GasFeeCap
+
+	if !legacy && msg.GasFeeCap.Cmp(msg.GasTipCap) < 0 {
+		return nil, fmt.Errorf("maxFeePerGas (%v) < maxPriorityFeePerGas (%v)", msg.GasFeeCap, msg.GasTipCap)
+	}
+
+	var chainId *big.Int
+	if !legacy || len(msg.AccessList) > 0 {
+		chainId, err = c.backend.ChainID(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if nonce == 0 {
-		nonce, err = c.nm.PendingNonceAt(ctx, msg.From)
+	if nonce == nil {
+		n, err := c.nm.PendingNonceAt(ctx, msg.From)
 		if err != nil {
 			return nil, err
 		}
+		nonce = &n
 	}
 
-	log.Debug("nonce assign msg", "nonce", nonce, "ID", msg.Id())
+	log.Debug("nonce assign msg", "nonce", *nonce, "ID", msg.Id())
 
-	tx = types.NewTransaction(nonce, *msg.To, msg.Value, msg.Gas, msg.GasPrice, msg.Data)
+	switch {
+	case !legacy:
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainId,
+			Nonce:      *nonce,
+			GasTipCap:  msg.GasTipCap,
+			GasFeeCap:  msg.GasFeeCap,
+			Gas:        msg.Gas,
+			To:         msg.To,
+			Value:      msg.Value,
+			Data:       msg.Data,
+			AccessList: msg.AccessList,
+		})
+	case len(msg.AccessList) > 0:
+		tx = types.NewTx(&types.AccessListTx{
+			ChainID:    chainId,
+			Nonce:      *nonce,
+			GasPrice:   msg.GasPrice,
+			Gas:        msg.Gas,
+			To:         msg.To,
+			Value:      msg.Value,
+			Data:       msg.Data,
+			AccessList: msg.AccessList,
+		})
+	default:
+		tx = types.NewTransaction(*nonce, *msg.To, msg.Value, msg.Gas, msg.GasPrice, msg.Data)
+	}
 
 	return
 }
@@ -0,0 +1,504 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	_ Storage              = (*SQLStorage)(nil)
+	_ StorageLister        = (*SQLStorage)(nil)
+	_ StorageQuerier       = (*SQLStorage)(nil)
+	_ StorageStatusSwapper = (*SQLStorage)(nil)
+)
+
+// SQLDialect is the flavor of SQL spoken by the database of a SQLStorage.
+type SQLDialect uint8
+
+const (
+	SQLDialectSQLite SQLDialect = iota
+	SQLDialectPostgres
+)
+
+// rebind replaces the ? placeholders of query with the ones of the dialect.
+func (d SQLDialect) rebind(query string) string {
+	if d != SQLDialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forUpdate locks the rows selected until the end of the transaction.
+// SQLite has no row locks, its transactions take the database write lock when they start instead, see inTx.
+func (d SQLDialect) forUpdate() string {
+	if d == SQLDialectPostgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// noLimit is the LIMIT clause needed by an OFFSET without limit.
+func (d SQLDialect) noLimit() string {
+	if d == SQLDialectPostgres {
+		return " LIMIT ALL"
+	}
+	return " LIMIT -1"
+}
+
+// sqlMigrations are applied in order, the version of each being its index + 1.
+// Never edit the ones released, append new ones instead.
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS ethclient_messages (
+	chain_id VARCHAR(78) NOT NULL,
+	id CHAR(66) NOT NULL,
+	status SMALLINT NOT NULL,
+	from_address CHAR(42) NOT NULL,
+	to_address CHAR(42),
+	nonce BIGINT,
+	tx_hash CHAR(66),
+	root_id CHAR(66),
+	parent_id CHAR(66),
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (chain_id, id)
+);
+CREATE INDEX IF NOT EXISTS ethclient_messages_status ON ethclient_messages (chain_id, status);
+CREATE INDEX IF NOT EXISTS ethclient_messages_from_nonce ON ethclient_messages (chain_id, from_address, nonce);
+CREATE INDEX IF NOT EXISTS ethclient_messages_tx_hash ON ethclient_messages (tx_hash);
+CREATE INDEX IF NOT EXISTS ethclient_messages_root ON ethclient_messages (chain_id, root_id);
+CREATE INDEX IF NOT EXISTS ethclient_messages_created_at ON ethclient_messages (chain_id, created_at);`,
+}
+
+// SQLStorage stores messages in a SQL database through database/sql, so that they can be joined
+// with business data. The msg is stored encoded along with indexed columns of its status, sender,
+// nonce and tx hash. Addresses and hashes are stored as lowercase hex, times as unix nanoseconds.
+type SQLStorage struct {
+	chainId *big.Int
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+// NewSQLStorage returns the storage of msgs of chainId in db, migrating its schema.
+// With SQLite, set a busy timeout on the connections of db so that concurrent writes wait for each other
+// rather than fail with SQLITE_BUSY, e.g. _busy_timeout of mattn/go-sqlite3, 5s by default.
+func NewSQLStorage(chainId *big.Int, db *sql.DB, dialect SQLDialect) (*SQLStorage, error) {
+	s := &SQLStorage{
+		chainId: chainId,
+		db:      db,
+		dialect: dialect,
+	}
+
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("failed to migrate msg storage: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *SQLStorage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS ethclient_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM ethclient_migrations`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		err := s.inTx(func(tx sqlTx) error {
+			for _, stmt := range strings.Split(sqlMigrations[i], ";") {
+				if strings.TrimSpace(stmt) == "" {
+					continue
+				}
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+
+			_, err := tx.Exec(s.dialect.rebind(`INSERT INTO ethclient_migrations (version, applied_at) VALUES (?, ?)
+ON CONFLICT (version) DO NOTHING`), i+1, time.Now().UnixNano())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLStorage) AddMsg(req Request) error {
+	log.Debug("SQLStorage AddMsg", "req", req)
+
+	msg := Message{
+		Req:    &req,
+		Status: MessageStatusSubmitted,
+	}
+	msg.History = []HistoryRecord{newHistoryRecord(0, msg)}
+
+	cols, err := s.columns(msg)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(s.dialect.rebind(`INSERT INTO ethclient_messages
+(chain_id, id, status, from_address, to_address, nonce, tx_hash, root_id, parent_id, created_at, updated_at, data)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (chain_id, id) DO NOTHING`), append([]interface{}{s.chainId.String(), req.id.Hex()}, cols...)...)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("duplicated msg not allowed")
+	}
+
+	return nil
+}
+
+func (s *SQLStorage) HasMsg(msgId common.Hash) bool {
+	var n int
+	err := s.db.QueryRow(s.dialect.rebind(`SELECT 1 FROM ethclient_messages WHERE chain_id = ? AND id = ?`),
+		s.chainId.String(), msgId.Hex()).Scan(&n)
+	return err == nil
+}
+
+func (s *SQLStorage) GetMsg(msgId common.Hash) (Message, error) {
+	return s.getMsg(s.db, msgId, "")
+}
+
+type sqlQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlTx is a transaction of inTx.
+type sqlTx interface {
+	sqlQueryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLStorage) getMsg(db sqlQueryer, msgId common.Hash, suffix string) (Message, error) {
+	var data string
+	err := db.QueryRow(s.dialect.rebind(`SELECT data FROM ethclient_messages WHERE chain_id = ? AND id = ?`+suffix),
+		s.chainId.String(), msgId.Hex()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Message{}, fmt.Errorf("not found")
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	return decodeMessage([]byte(data))
+}
+
+func (s *SQLStorage) UpdateMsg(msg Message) error {
+	return s.update(msg.Req.id, func(*Message) (Message, error) {
+		return msg, nil
+	})
+}
+
+func (s *SQLStorage) UpdateResponse(msgId common.Hash, resp Response) error {
+	log.Debug("SQLStorage UpdateResponse", "msgId", msgId.Hex(), "resp", resp)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		if msg.Resp != nil {
+			return Message{}, fmt.Errorf("same msg not allowed updating response twice: %v", msgId.Hex())
+		}
+
+		msg.Resp = &resp
+		return *msg, nil
+	})
+}
+
+func (s *SQLStorage) UpdateReceipt(msgId common.Hash, receipt Receipt) error {
+	log.Debug("SQLStorage UpdateReceipt", "msgId", msgId.Hex(),
+		"txHash", receipt.TxReceipt.TxHash.Hex(), "receipt", receipt)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Receipt = &receipt
+		return *msg, nil
+	})
+}
+
+func (s *SQLStorage) UpdateMsgStatus(msgId common.Hash, status MessageStatus) error {
+	log.Debug("SQLStorage UpdateMsgStatus", "msgId", msgId.Hex(), "status", status)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		msg.Status = status
+		return *msg, nil
+	})
+}
+
+func (s *SQLStorage) SwapMsgStatus(msgId common.Hash, from []MessageStatus, status MessageStatus) error {
+	log.Debug("SQLStorage SwapMsgStatus", "msgId", msgId.Hex(), "from", from, "status", status)
+
+	return s.update(msgId, func(msg *Message) (Message, error) {
+		return swapStatus(msg, from, status)
+	})
+}
+
+func (s *SQLStorage) GetNonce(msgId common.Hash) (nonce uint64, err error) {
+	msg, err := s.GetMsg(msgId)
+	if err != nil {
+		return
+	}
+
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		return 0, fmt.Errorf("no nonce assigned")
+	}
+
+	return msg.Resp.Tx.Nonce(), nil
+}
+
+func (s *SQLStorage) MsgIdsByStatus(status MessageStatus) ([]common.Hash, error) {
+	rows, err := s.db.Query(s.dialect.rebind(`SELECT id FROM ethclient_messages WHERE chain_id = ? AND status = ?`),
+		s.chainId.String(), int(status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgIds []common.Hash
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		msgIds = append(msgIds, common.HexToHash(id))
+	}
+
+	return msgIds, rows.Err()
+}
+
+// QueryMsgs filters and pages msgs in the database, using the indexed columns.
+func (s *SQLStorage) QueryMsgs(query MsgQuery) ([]Message, error) {
+	where := []string{"chain_id = ?"}
+	args := []interface{}{s.chainId.String()}
+
+	in := func(col string, values []interface{}) {
+		if len(values) == 0 {
+			return
+		}
+		where = append(where, col+" IN (?"+strings.Repeat(", ?", len(values)-1)+")")
+		args = append(args, values...)
+	}
+	cmp := func(cond string, arg interface{}) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+
+	statuses := make([]interface{}, 0, len(query.Statuses))
+	for _, status := range query.Statuses {
+		statuses = append(statuses, int(status))
+	}
+	in("status", statuses)
+
+	from := make([]interface{}, 0, len(query.From))
+	for _, addr := range query.From {
+		from = append(from, sqlAddress(addr))
+	}
+	in("from_address", from)
+
+	to := make([]interface{}, 0, len(query.To))
+	for _, addr := range query.To {
+		to = append(to, sqlAddress(addr))
+	}
+	in("to_address", to)
+
+	if query.Root != nil {
+		cmp("root_id = ?", query.Root.Hex())
+	}
+	if query.Parent != nil {
+		cmp("parent_id = ?", query.Parent.Hex())
+	}
+	if !query.CreatedAfter.IsZero() {
+		cmp("created_at > ?", query.CreatedAfter.UnixNano())
+	}
+	if !query.CreatedBefore.IsZero() {
+		cmp("created_at < ?", query.CreatedBefore.UnixNano())
+	}
+	if !query.UpdatedAfter.IsZero() {
+		cmp("updated_at > ?", query.UpdatedAfter.UnixNano())
+	}
+	if !query.UpdatedBefore.IsZero() {
+		cmp("updated_at < ?", query.UpdatedBefore.UnixNano())
+	}
+
+	stmt := "SELECT data FROM ethclient_messages WHERE " + strings.Join(where, " AND ") + " ORDER BY created_at, id"
+	if query.Limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, query.Limit)
+	} else if query.Offset > 0 {
+		stmt += s.dialect.noLimit()
+	}
+	if query.Offset > 0 {
+		stmt += " OFFSET ?"
+		args = append(args, query.Offset)
+	}
+
+	rows, err := s.db.Query(s.dialect.rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		msg, err := decodeMessage([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, rows.Err()
+}
+
+// update applies fn to the stored msg in a transaction, so that concurrent updates
+// from this or other processes are not lost, and records the change in its history.
+func (s *SQLStorage) update(msgId common.Hash, fn func(msg *Message) (Message, error)) error {
+	return s.inTx(func(tx sqlTx) error {
+		old, err := s.getMsg(tx, msgId, s.dialect.forUpdate())
+		if err != nil {
+			return err
+		}
+		prev := old
+
+		msg, err := fn(&old)
+		if err != nil {
+			return err
+		}
+		msg = withHistory(prev, msg)
+
+		cols, err := s.columns(msg)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(s.dialect.rebind(`UPDATE ethclient_messages SET
+status = ?, from_address = ?, to_address = ?, nonce = ?, tx_hash = ?, root_id = ?, parent_id = ?, created_at = ?, updated_at = ?, data = ?
+WHERE chain_id = ? AND id = ?`), append(cols, s.chainId.String(), msgId.Hex())...)
+		return err
+	})
+}
+
+func (s *SQLStorage) inTx(fn func(tx sqlTx) error) error {
+	if s.dialect == SQLDialectSQLite {
+		return s.inImmediateTx(fn)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inImmediateTx runs fn in a SQLite transaction taking the write lock when it starts. A deferred one reading
+// before writing fails with SQLITE_BUSY, rather than waiting, if another connection wrote meanwhile.
+func (s *SQLStorage) inImmediateTx(fn func(tx sqlTx) error) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+
+	if err := fn(sqliteTx{conn}); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	return nil
+}
+
+// sqliteTx is a transaction begun on conn by inImmediateTx.
+type sqliteTx struct {
+	conn *sql.Conn
+}
+
+func (tx sqliteTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+func (tx sqliteTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.conn.ExecContext(context.Background(), query, args...)
+}
+
+// columns returns the values of the columns of msg, from status to data.
+func (s *SQLStorage) columns(msg Message) ([]interface{}, error) {
+	data, err := encodeMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var to, nonce, txHash, root, parent interface{}
+	if msg.Req.To != nil {
+		to = sqlAddress(*msg.Req.To)
+	}
+	if msg.Resp != nil && msg.Resp.Tx != nil {
+		nonce = int64(msg.Resp.Tx.Nonce())
+		txHash = msg.Resp.Tx.Hash().Hex()
+	}
+	if msg.Root != nil {
+		root = msg.Root.Hex()
+	}
+	if msg.Parent != nil {
+		parent = msg.Parent.Hex()
+	}
+
+	return []interface{}{
+		int(msg.Status), sqlAddress(msg.Req.From), to, nonce, txHash, root, parent,
+		msg.CreatedAt().UnixNano(), msg.UpdatedAt().UnixNano(), string(data),
+	}, nil
+}
+
+func sqlAddress(addr common.Address) string {
+	return strings.ToLower(addr.Hex())
+}
@@ -1,6 +1,10 @@
 package message
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -15,6 +19,49 @@ type StorageReader interface {
 	GetNonce(msgId common.Hash) (uint64, error)
 }
 
+// StorageLister is implemented by storages able to list messages,
+// which is needed to recover unfinished messages after restarts.
+type StorageLister interface {
+	MsgIdsByStatus(status MessageStatus) ([]common.Hash, error)
+}
+
+// ErrMsgStatusChanged is returned by SwapMsgStatus if the msg is not in any of the statuses expected.
+var ErrMsgStatusChanged = errors.New("msg status changed")
+
+// StorageStatusSwapper is implemented by storages changing the status of msgs atomically,
+// which is needed for cancelling msgs racing with their broadcasting.
+type StorageStatusSwapper interface {
+	// SwapMsgStatus sets the status of msgId to status if it's one of from, returning ErrMsgStatusChanged otherwise.
+	SwapMsgStatus(msgId common.Hash, from []MessageStatus, status MessageStatus) error
+}
+
+// SwapMsgStatus sets the status of msgId to status if it's one of from, returning ErrMsgStatusChanged otherwise.
+// It's atomic only if storage implements StorageStatusSwapper.
+func SwapMsgStatus(storage Storage, msgId common.Hash, from []MessageStatus, status MessageStatus) error {
+	if swapper, ok := storage.(StorageStatusSwapper); ok {
+		return swapper.SwapMsgStatus(msgId, from, status)
+	}
+
+	msg, err := storage.GetMsg(msgId)
+	if err != nil {
+		return err
+	}
+	if _, err := swapStatus(&msg, from, status); err != nil {
+		return err
+	}
+
+	return storage.UpdateMsgStatus(msgId, status)
+}
+
+func swapStatus(msg *Message, from []MessageStatus, status MessageStatus) (Message, error) {
+	if !slices.Contains(from, msg.Status) {
+		return Message{}, fmt.Errorf("%w: %v is %v", ErrMsgStatusChanged, msg.Id().Hex(), msg.Status)
+	}
+
+	msg.Status = status
+	return *msg, nil
+}
+
 type StorageWriter interface {
 	AddMsg(req Request) error
 	UpdateMsg(msg Message) error
@@ -0,0 +1,50 @@
+package message
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestStorage_SwapMsgStatus(t *testing.T) {
+	memory, err := NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, redis := newTestRedisStorage(t)
+	storages := map[string]Storage{"memory": memory, "redis": redis}
+	for name, s := range testSQLStorages(t) {
+		storages[name] = s
+	}
+
+	for name, s := range storages {
+		t.Run(name, func(t *testing.T) {
+			req := (&Request{From: common.HexToAddress("0x1")}).SetRandomId()
+			if err := s.AddMsg(*req); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.UpdateMsgStatus(req.Id(), MessageStatusQueued); err != nil {
+				t.Fatal(err)
+			}
+
+			err := SwapMsgStatus(s, req.Id(), []MessageStatus{MessageStatusSubmitted, MessageStatusQueued}, MessageStatusCancelled)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = SwapMsgStatus(s, req.Id(), []MessageStatus{MessageStatusQueued}, MessageStatusNonceAssigned)
+			if !errors.Is(err, ErrMsgStatusChanged) {
+				t.Fatalf("want ErrMsgStatusChanged, got %v", err)
+			}
+
+			msg, err := s.GetMsg(req.Id())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Status != MessageStatusCancelled {
+				t.Fatalf("want msg cancelled, got %v", msg.Status)
+			}
+		})
+	}
+}
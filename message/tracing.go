@@ -0,0 +1,51 @@
+package message
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ivanzzeth/ethclient/message"
+
+// MsgAttributes are the span attributes identifying msg.
+func MsgAttributes(msg Request) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("msg.id", msg.Id().Hex()),
+		attribute.String("msg.from", msg.From.Hex()),
+	}
+	if msg.To != nil {
+		attrs = append(attrs, attribute.String("msg.to", msg.To.Hex()))
+	}
+	return attrs
+}
+
+// TxAttributes are the span attributes of the tx sent for a msg.
+func TxAttributes(tx *types.Transaction) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("tx.hash", tx.Hash().Hex()),
+		attribute.Int64("tx.nonce", int64(tx.Nonce())),
+	}
+}
+
+// startSpan starts a child of the span in ctx. Msgs are traced by the client, so nothing is recorded
+// unless ctx carries the span of a msg.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+
+	return parent.TracerProvider().Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,57 @@
+package ethclient
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/metrics"
+	"github.com/ivanzzeth/ethclient/subscriber"
+)
+
+// SetMetrics reports the msgs, the sequencer and the subscriptions of the client to m.
+// RPC requests are reported too if the client was dialed with WithMetrics.
+func (c *Client) SetMetrics(m *metrics.Metrics) {
+	m.Watch(c.msgSequencer, c.msgStore)
+	c.metrics.Store(m)
+
+	if cs, ok := c.Subscriber.(*subscriber.ChainSubscriber); ok {
+		cs.SetProgressHandler(m.ObserveSubscriberProgress)
+	}
+}
+
+func (c *Client) observeStatus(msgId common.Hash, status message.MessageStatus) {
+	if m := c.metrics.Load(); m != nil {
+		m.ObserveStatus(msgId, status)
+	}
+}
+
+// observeReceipt reports the gas paid for the tx of a msg once it can't be reorged out.
+func (c *Client) observeReceipt(receipt message.Receipt) {
+	m := c.metrics.Load()
+	if m == nil {
+		return
+	}
+
+	// Cancellations got on chain are counted as well, they are not tracked until finalized.
+	if receipt.TxReceipt == nil || (receipt.Status != message.MessageStatusFinalized && receipt.Status != message.MessageStatusCancelled) {
+		return
+	}
+
+	msg, err := c.msgStore.GetMsg(receipt.Id)
+	if err != nil {
+		return
+	}
+	m.ObserveGas(msg.Req.From, receipt.TxReceipt)
+}
+
+func (c *Client) observeReplacement(msgId common.Hash, resp message.Response) {
+	m := c.metrics.Load()
+	if m == nil {
+		return
+	}
+
+	msg, err := c.msgStore.GetMsg(msgId)
+	if err != nil {
+		return
+	}
+	m.ObserveReplacement(msg.Req.From, resp.Err)
+}
@@ -0,0 +1,242 @@
+// Package metrics exposes Prometheus collectors of the message pipeline
+// (scheduler, sequencer, broadcaster), the RPC requests and the subscriber.
+package metrics
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "ethclient"
+
+// trackedMsgs is the max number of msgs whose current status is remembered for timing their stages.
+const trackedMsgs = 100000
+
+// liveStatuses are reported by the messages gauge. Msgs in final statuses are only counted by transitions,
+// since they pile up.
+var liveStatuses = []message.MessageStatus{
+	message.MessageStatusSubmitted,
+	message.MessageStatusScheduled,
+	message.MessageStatusQueued,
+	message.MessageStatusNonceAssigned,
+	message.MessageStatusInflight,
+	message.MessageStatusOnChain,
+}
+
+type stage struct {
+	status message.MessageStatus
+	since  time.Time
+}
+
+// Metrics holds the collectors, registered on the registry given to NewMetrics.
+// Its methods are called by the client, see Client.SetMetrics.
+type Metrics struct {
+	transitions   *prometheus.CounterVec
+	stageDuration *prometheus.HistogramVec
+	replacements  *prometheus.CounterVec
+	gasUsed       *prometheus.CounterVec
+	fees          *prometheus.CounterVec
+	rpcDuration   *prometheus.HistogramVec
+	rpcErrors     *prometheus.CounterVec
+	subscriberLag *prometheus.GaugeVec
+
+	mu        sync.Mutex
+	sequencer message.Sequencer
+	lister    message.StorageLister
+	stages    *lru.Cache[common.Hash, stage]
+}
+
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "message_transitions_total",
+			Help:      "Number of msgs entering each status.",
+		}, []string{"status"}),
+		stageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "message_stage_duration_seconds",
+			Help:      "Time msgs spent in each status before moving on.",
+			Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 3600},
+		}, []string{"status"}),
+		replacements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "message_replacements_total",
+			Help:      "Number of txs replaced with higher fees as not mined in time, by sender and result.",
+		}, []string{"from", "result"}),
+		gasUsed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "message_gas_used_total",
+			Help:      "Gas used by the txs of msgs finalized or cancelled, by sender.",
+		}, []string{"from"}),
+		fees: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "message_fees_wei_total",
+			Help:      "Fees paid for the txs of msgs finalized or cancelled in wei, by sender.",
+		}, []string{"from"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rpc_request_duration_seconds",
+			Help:      "Latency of JSON-RPC requests, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_errors_total",
+			Help:      "Number of JSON-RPC requests failed, by method.",
+		}, []string{"method"}),
+		subscriberLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "subscriber_lag_blocks",
+			Help:      "Blocks between the head and the last block scanned, by query hash.",
+		}, []string{"query"}),
+		stages: lru.NewCache[common.Hash, stage](trackedMsgs),
+	}
+
+	collectors := []prometheus.Collector{
+		m.transitions, m.stageDuration, m.replacements, m.gasUsed, m.fees,
+		m.rpcDuration, m.rpcErrors, m.subscriberLag,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sequencer_queued_msgs",
+			Help:      "Msgs queued in the sequencer, waiting for the msgs they depend on.",
+		}, func() float64 { return m.sequencerCount(message.Sequencer.QueuedMsgCount) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sequencer_pending_msgs",
+			Help:      "Msgs ready in the sequencer, waiting for being broadcasted.",
+		}, func() float64 { return m.sequencerCount(message.Sequencer.PendingMsgCount) }),
+	}
+
+	for _, status := range liveStatuses {
+		status := status
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "messages",
+			Help:        "Msgs in each status not final yet.",
+			ConstLabels: prometheus.Labels{"status": status.String()},
+		}, func() float64 { return m.msgCount(status) }))
+	}
+
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Watch sets the sequencer and the storage whose msgs are counted, the storage needs to implement
+// message.StorageLister.
+func (m *Metrics) Watch(sequencer message.Sequencer, storage message.Storage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sequencer = sequencer
+	m.lister, _ = storage.(message.StorageLister)
+}
+
+func (m *Metrics) sequencerCount(count func(message.Sequencer) (int, error)) float64 {
+	m.mu.Lock()
+	sequencer := m.sequencer
+	m.mu.Unlock()
+
+	if sequencer == nil {
+		return 0
+	}
+
+	n, err := count(sequencer)
+	if err != nil {
+		log.Warn("count msgs of sequencer failed", "err", err)
+		return 0
+	}
+	return float64(n)
+}
+
+func (m *Metrics) msgCount(status message.MessageStatus) float64 {
+	m.mu.Lock()
+	lister := m.lister
+	m.mu.Unlock()
+
+	if lister == nil {
+		return 0
+	}
+
+	msgIds, err := lister.MsgIdsByStatus(status)
+	if err != nil {
+		log.Warn("count msgs failed", "status", status, "err", err)
+		return 0
+	}
+	return float64(len(msgIds))
+}
+
+// ObserveStatus records msgId entering status, and the time it spent in its previous status.
+func (m *Metrics) ObserveStatus(msgId common.Hash, status message.MessageStatus) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.stages.Get(msgId)
+	if ok && prev.status == status {
+		return
+	}
+	if ok {
+		m.stageDuration.WithLabelValues(prev.status.String()).Observe(now.Sub(prev.since).Seconds())
+	}
+	m.transitions.WithLabelValues(status.String()).Inc()
+
+	switch status {
+	case message.MessageStatusFinalized, message.MessageStatusExpired, message.MessageStatusNonceReleased, message.MessageStatusFailed:
+		m.stages.Remove(msgId)
+	default:
+		m.stages.Add(msgId, stage{status: status, since: now})
+	}
+}
+
+// ObserveReplacement records the replacement of a tx of from with higher fees.
+func (m *Metrics) ObserveReplacement(from common.Address, err error) {
+	result := "replaced"
+	if err != nil {
+		result = "failed"
+	}
+	m.replacements.WithLabelValues(from.Hex(), result).Inc()
+}
+
+// ObserveGas records the gas used and the fees paid by the tx of from mined.
+func (m *Metrics) ObserveGas(from common.Address, receipt *types.Receipt) {
+	m.gasUsed.WithLabelValues(from.Hex()).Add(float64(receipt.GasUsed))
+
+	if receipt.EffectiveGasPrice != nil {
+		fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		f, _ := new(big.Float).SetInt(fee).Float64()
+		m.fees.WithLabelValues(from.Hex()).Add(f)
+	}
+}
+
+// ObserveRPC records a JSON-RPC request, see transport.RequestObserver.
+func (m *Metrics) ObserveRPC(method string, duration time.Duration, err error) {
+	m.rpcDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if err != nil {
+		m.rpcErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// ObserveSubscriberProgress records how far a query subscribed is behind the head,
+// see subscriber.ProgressHandler.
+func (m *Metrics) ObserveSubscriberProgress(queryHash common.Hash, block uint64, head uint64) {
+	var lag uint64
+	if head > block {
+		lag = head - block
+	}
+	m.subscriberLag.WithLabelValues(queryHash.Hex()).Set(float64(lag))
+}
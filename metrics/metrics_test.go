@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_ObserveStatus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgId := common.HexToHash("0x1")
+	m.ObserveStatus(msgId, message.MessageStatusSubmitted)
+	m.ObserveStatus(msgId, message.MessageStatusSubmitted)
+	time.Sleep(10 * time.Millisecond)
+	m.ObserveStatus(msgId, message.MessageStatusInflight)
+	m.ObserveStatus(msgId, message.MessageStatusFinalized)
+
+	if n := testutil.ToFloat64(m.transitions.WithLabelValues(message.MessageStatusSubmitted.String())); n != 1 {
+		t.Fatalf("want repeated status counted once, got %v", n)
+	}
+	if n := testutil.CollectAndCount(m.stageDuration); n != 2 {
+		t.Fatalf("want durations of submitted and inflight, got %v", n)
+	}
+	if m.stages.Len() != 0 {
+		t.Fatal("want finalized msg no longer tracked")
+	}
+
+	if _, err := NewMetrics(reg); err == nil {
+		t.Fatal("want collectors registered twice rejected")
+	}
+}
+
+func TestMetrics_ObserveGas(t *testing.T) {
+	m, err := NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := common.HexToAddress("0x1")
+	m.ObserveGas(from, &types.Receipt{GasUsed: 21000, EffectiveGasPrice: big.NewInt(2)})
+	m.ObserveGas(from, &types.Receipt{GasUsed: 21000})
+
+	if n := testutil.ToFloat64(m.gasUsed.WithLabelValues(from.Hex())); n != 42000 {
+		t.Fatalf("want gas used 42000, got %v", n)
+	}
+	if n := testutil.ToFloat64(m.fees.WithLabelValues(from.Hex())); n != 42000 {
+		t.Fatalf("want fees 42000, got %v", n)
+	}
+}
+
+func TestMetrics_Watch(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := message.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := (&message.Request{From: common.HexToAddress("0x1")}).SetRandomId()
+	if err := storage.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+	m.Watch(nil, storage)
+
+	want := `
+# HELP ethclient_messages Msgs in each status not final yet.
+# TYPE ethclient_messages gauge
+ethclient_messages{status="inflight"} 0
+ethclient_messages{status="nonceAssigned"} 0
+ethclient_messages{status="onChain"} 0
+ethclient_messages{status="queued"} 0
+ethclient_messages{status="scheduled"} 0
+ethclient_messages{status="submitted"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "ethclient_messages"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package multicall
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// batch is the calls at the same block held for being coalesced.
+type batch struct {
+	blockNumber *big.Int
+	calls       []Call
+	waiters     []chan callResult
+}
+
+type callResult struct {
+	ret []byte
+	err error
+	// fallback is true if the multicall failed, then the call is done on its own
+	fallback bool
+}
+
+// CallContract calls msg, coalescing it with the other calls at the same block in the window of the config into
+// one multicall. Calls with a sender, value, gas or fees specified are not coalesced, since their context differs
+// in a multicall. If the multicall fails as a whole, e.g. Multicall3 not deployed, calls are done on their own.
+func (m *Multicaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	config := m.Config()
+	if config.Window <= 0 || !coalescable(msg) {
+		return m.caller.CallContract(ctx, msg, blockNumber)
+	}
+
+	waiter := make(chan callResult, 1)
+	m.enqueue(Call{Target: *msg.To, CallData: msg.Data, AllowFailure: true}, blockNumber, waiter, config.Window)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-waiter:
+		if result.fallback {
+			return m.caller.CallContract(ctx, msg, blockNumber)
+		}
+		return result.ret, result.err
+	}
+}
+
+func (m *Multicaller) enqueue(call Call, blockNumber *big.Int, waiter chan callResult, window time.Duration) {
+	key := "latest"
+	if blockNumber != nil {
+		key = blockNumber.String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.batches[key]
+	if !ok {
+		b = &batch{blockNumber: blockNumber}
+		m.batches[key] = b
+		time.AfterFunc(window, func() { m.flush(key) })
+	}
+
+	b.calls = append(b.calls, call)
+	b.waiters = append(b.waiters, waiter)
+}
+
+func (m *Multicaller) flush(key string) {
+	m.mu.Lock()
+	b := m.batches[key]
+	delete(m.batches, key)
+	m.mu.Unlock()
+
+	if len(b.calls) == 1 {
+		b.waiters[0] <- callResult{fallback: true}
+		return
+	}
+
+	timeout := m.Config().CoalesceTimeout
+	if timeout <= 0 {
+		timeout = DefaultCoalesceTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results, err := m.Aggregate3(ctx, b.calls, b.blockNumber)
+	for i, waiter := range b.waiters {
+		if err != nil {
+			waiter <- callResult{fallback: true}
+			continue
+		}
+
+		// errors are decoded by callers, as the ones of calls done on their own
+		result := results[i]
+		if result.Success {
+			waiter <- callResult{ret: result.ReturnData}
+		} else {
+			waiter <- callResult{err: newRevertError(result.ReturnData)}
+		}
+	}
+}
+
+func coalescable(msg ethereum.CallMsg) bool {
+	return msg.To != nil &&
+		msg.From == (ethereum.CallMsg{}).From &&
+		(msg.Value == nil || msg.Value.Sign() == 0) &&
+		msg.Gas == 0 &&
+		msg.GasPrice == nil && msg.GasFeeCap == nil && msg.GasTipCap == nil &&
+		len(msg.AccessList) == 0 && len(msg.BlobHashes) == 0
+}
@@ -0,0 +1,278 @@
+package multicall
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/abiregistry"
+)
+
+// DefaultAddress is the address of Multicall3 on most chains, see https://www.multicall3.com.
+var DefaultAddress = common.HexToAddress("0xcA11bde05779BA9Ab73C3F2f15D0cE2CBA1dDA11")
+
+const multicall3ABI = `[{"type":"function","name":"aggregate3","stateMutability":"payable",
+	"inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],
+	"outputs":[{"name":"returnData","type":"tuple[]","components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}]`
+
+var Multicall3ABI abi.ABI
+
+func init() {
+	var err error
+	Multicall3ABI, err = abi.JSON(strings.NewReader(multicall3ABI))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Call is a call aggregated by aggregate3 of Multicall3.
+type Call struct {
+	Target   common.Address
+	CallData []byte
+	// AllowFailure is false if the whole multicall fails on this call failing.
+	AllowFailure bool
+	// Gas is the gas estimated for the call when chunking calls, 0 for Config.CallGas.
+	Gas uint64
+}
+
+// Result is the result of a call.
+type Result struct {
+	Success    bool
+	ReturnData []byte
+	// Outputs are ReturnData decoded with the ABI registered for the target, nil if not decoded.
+	Outputs []interface{}
+	// Err is the revert of the call failed, decoded with the ABI registered for the target.
+	Err error
+}
+
+type Config struct {
+	// Address is the address of Multicall3.
+	Address common.Address
+	// MaxCalldataSize is the max size of calldata of a multicall, calls beyond it are split into more multicalls.
+	MaxCalldataSize int
+	// MaxGas is the max gas of a multicall, estimated by the gas of its calls.
+	MaxGas uint64
+	// CallGas is the gas estimated for calls without gas specified.
+	CallGas uint64
+	// Window is how long CallContract holds calls for coalescing them into one multicall, 0 disables it.
+	Window time.Duration
+	// CoalesceTimeout bounds multicalls of calls coalesced, which are not bound to the context of any caller.
+	// 0 for DefaultCoalesceTimeout.
+	CoalesceTimeout time.Duration
+}
+
+// DefaultCoalesceTimeout is the timeout of multicalls of calls coalesced if Config.CoalesceTimeout is 0.
+const DefaultCoalesceTimeout = 30 * time.Second
+
+func DefaultConfig() Config {
+	return Config{
+		Address:         DefaultAddress,
+		MaxCalldataSize: 128 * 1024,
+		MaxGas:          25_000_000,
+		CallGas:         100_000,
+		CoalesceTimeout: DefaultCoalesceTimeout,
+	}
+}
+
+type Caller interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Multicaller aggregates calls by Multicall3.
+type Multicaller struct {
+	caller   Caller
+	registry *abiregistry.Registry
+
+	mu      sync.Mutex
+	config  Config
+	batches map[string]*batch
+}
+
+// NewMulticaller creates a multicaller calling caller, decoding results with registry if not nil.
+func NewMulticaller(caller Caller, registry *abiregistry.Registry, config Config) *Multicaller {
+	return &Multicaller{
+		caller:   caller,
+		registry: registry,
+		config:   config,
+		batches:  make(map[string]*batch),
+	}
+}
+
+func (m *Multicaller) SetConfig(config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = config
+}
+
+func (m *Multicaller) Config() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.config
+}
+
+// Aggregate3 calls calls by aggregate3 of Multicall3 at blockNumber, split into multicalls by calldata size and gas.
+// Results are in the same order as calls. It fails if any multicall fails, e.g. on a call not allowed to fail.
+func (m *Multicaller) Aggregate3(ctx context.Context, calls []Call, blockNumber *big.Int) ([]Result, error) {
+	config := m.Config()
+	results := make([]Result, len(calls))
+
+	chunks := chunkCalls(calls, config)
+	errs := make([]error, len(chunks))
+
+	var wg sync.WaitGroup
+	offset := 0
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i, offset int, chunk []Call) {
+			defer wg.Done()
+			errs[i] = m.aggregate3(ctx, config.Address, chunk, blockNumber, results[offset:offset+len(chunk)])
+		}(i, offset, chunk)
+		offset += len(chunk)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+type call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type result3 struct {
+	Success    bool
+	ReturnData []byte
+}
+
+func (m *Multicaller) aggregate3(ctx context.Context, multicall common.Address, calls []Call, blockNumber *big.Int, results []Result) error {
+	args := make([]call3, len(calls))
+	for i, call := range calls {
+		args[i] = call3{Target: call.Target, AllowFailure: call.AllowFailure, CallData: call.CallData}
+	}
+
+	data, err := Multicall3ABI.Pack("aggregate3", args)
+	if err != nil {
+		return fmt.Errorf("pack aggregate3 err: %v", err)
+	}
+
+	ret, err := m.caller.CallContract(ctx, ethereum.CallMsg{To: &multicall, Data: data}, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := Multicall3ABI.Unpack("aggregate3", ret)
+	if err != nil {
+		return fmt.Errorf("unpack aggregate3 err: %v, is Multicall3 deployed at %v?", err, multicall.Hex())
+	}
+
+	returned := *abi.ConvertType(outputs[0], new([]result3)).(*[]result3)
+	if len(returned) != len(calls) {
+		return fmt.Errorf("aggregate3 returned %d results for %d calls", len(returned), len(calls))
+	}
+
+	for i, r := range returned {
+		results[i] = m.decodeResult(ctx, calls[i], r)
+	}
+
+	return nil
+}
+
+func (m *Multicaller) decodeResult(ctx context.Context, call Call, r result3) Result {
+	result := Result{Success: r.Success, ReturnData: r.ReturnData}
+
+	if !r.Success {
+		result.Err = newRevertError(r.ReturnData)
+		if m.registry != nil {
+			result.Err = m.registry.DecodeError(ctx, &call.Target, result.Err)
+		}
+		return result
+	}
+
+	if m.registry == nil || len(call.CallData) < 4 || !m.registry.CanDecodeCall(call.Target, call.CallData[:4]) {
+		return result
+	}
+
+	// merged ABIs are cached by the registry until ABIs are added
+	intf := m.registry.ABIOf(ctx, &call.Target)
+	method, err := intf.MethodById(call.CallData[:4])
+	if err != nil {
+		return result
+	}
+
+	result.Outputs, err = method.Outputs.Unpack(r.ReturnData)
+	if err != nil {
+		log.Debug("unpack outputs of call failed", "target", call.Target.Hex(), "method", method.Name, "err", err)
+		result.Outputs = nil
+	}
+
+	return result
+}
+
+// chunkCalls splits calls into chunks under the max calldata size and gas of config.
+// A call exceeding them on its own gets a chunk of its own.
+func chunkCalls(calls []Call, config Config) [][]Call {
+	var chunks [][]Call
+	var chunk []Call
+	size, gas := 0, uint64(0)
+
+	for _, call := range calls {
+		// target, allowFailure, offset and length of callData, and the offset of the call itself
+		callSize := 5*32 + (len(call.CallData)+31)/32*32
+		callGas := call.Gas
+		if callGas == 0 {
+			callGas = config.CallGas
+		}
+
+		exceeded := (config.MaxCalldataSize > 0 && size+callSize > config.MaxCalldataSize) ||
+			(config.MaxGas > 0 && gas+callGas > config.MaxGas)
+		if len(chunk) > 0 && exceeded {
+			chunks = append(chunks, chunk)
+			chunk, size, gas = nil, 0, 0
+		}
+
+		chunk = append(chunk, call)
+		size += callSize
+		gas += callGas
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// revertError is the revert of a call failed in a multicall, which is decoded as json rpc errors.
+type revertError struct {
+	data []byte
+}
+
+func newRevertError(data []byte) revertError {
+	return revertError{data: data}
+}
+
+func (e revertError) Error() string  { return "execution reverted" }
+func (e revertError) ErrorCode() int { return 3 }
+func (e revertError) ErrorData() interface{} {
+	if len(e.data) == 0 {
+		return nil
+	}
+	return hexutil.Encode(e.data)
+}
@@ -0,0 +1,249 @@
+package multicall
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient/abiregistry"
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+const tokenABI = `[
+	{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"error","name":"Paused","inputs":[]}
+]`
+
+var (
+	token  = common.HexToAddress("0x20")
+	paused = common.HexToAddress("0x21")
+)
+
+// fakeMulticall3 runs aggregate3 against tokens returning the owner as its balance,
+// and reverting with Paused() if paused.
+type fakeMulticall3 struct {
+	token     abi.ABI
+	multicall common.Address
+	calls     atomic.Int32
+	// hang makes multicalls hang until their contexts are done
+	hang bool
+}
+
+func (f *fakeMulticall3) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	f.calls.Add(1)
+
+	if *msg.To != f.multicall {
+		return f.call(call3{Target: *msg.To, CallData: msg.Data})
+	}
+
+	if f.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	args, err := Multicall3ABI.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	var results []result3
+	for _, call := range *abi.ConvertType(args[0], new([]call3)).(*[]call3) {
+		ret, err := f.call(call)
+		if err != nil && !call.AllowFailure {
+			return nil, errors.New("execution reverted: Multicall3: call failed")
+		}
+		results = append(results, result3{Success: err == nil, ReturnData: ret})
+	}
+
+	return Multicall3ABI.Methods["aggregate3"].Outputs.Pack(results)
+}
+
+func (f *fakeMulticall3) call(call call3) ([]byte, error) {
+	if call.Target == paused {
+		id := f.token.Errors["Paused"].ID
+		return id[:4], errors.New("execution reverted")
+	}
+
+	args, err := f.token.Methods["balanceOf"].Inputs.Unpack(call.CallData[4:])
+	if err != nil {
+		return nil, err
+	}
+	return f.token.Methods["balanceOf"].Outputs.Pack(new(big.Int).SetBytes(args[0].(common.Address).Bytes()))
+}
+
+func newTestMulticaller(t *testing.T, config Config) (*Multicaller, *fakeMulticall3, abi.ABI) {
+	intf, err := abi.JSON(strings.NewReader(tokenABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := abiregistry.NewRegistry(nil)
+	registry.AddABI(intf)
+
+	backend := &fakeMulticall3{token: intf, multicall: config.Address}
+	return NewMulticaller(backend, registry, config), backend, intf
+}
+
+func balanceOf(t *testing.T, intf abi.ABI, owner int64) []byte {
+	data, err := intf.Pack("balanceOf", common.BigToAddress(big.NewInt(owner)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAggregate3(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxGas = 10 * config.CallGas
+	m, backend, intf := newTestMulticaller(t, config)
+
+	var calls []Call
+	for i := 1; i <= 25; i++ {
+		calls = append(calls, Call{Target: token, CallData: balanceOf(t, intf, int64(i))})
+	}
+	calls = append(calls, Call{Target: paused, CallData: balanceOf(t, intf, 1), AllowFailure: true})
+	unknown := append([]byte{0xde, 0xad, 0xbe, 0xef}, balanceOf(t, intf, 1)[4:]...)
+	calls = append(calls, Call{Target: token, CallData: unknown})
+
+	results, err := m.Aggregate3(context.Background(), calls, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.calls.Load() != 3 {
+		t.Fatalf("want calls split into 3 multicalls by gas, got %v", backend.calls.Load())
+	}
+
+	for i, result := range results[:25] {
+		if !result.Success || len(result.Outputs) != 1 || result.Outputs[0].(*big.Int).Int64() != int64(i+1) {
+			t.Fatalf("unexpected result %v: %+v", i, result)
+		}
+	}
+
+	failed := results[25]
+	if failed.Success || !errors.Is(failed.Err, consts.ErrExecutionReverted) {
+		t.Fatalf("want revert of the call allowed to fail, got %+v", failed)
+	}
+	var jsonErr *consts.JsonRpcError
+	if !errors.As(failed.Err, &jsonErr) || jsonErr.DecodedData.(consts.RevertError).FuncSignature != "Paused()" {
+		t.Fatalf("want revert decoded, got %v", failed.Err)
+	}
+
+	if !results[26].Success || results[26].Outputs != nil {
+		t.Fatalf("want unknown call not decoded, got %+v", results[26])
+	}
+
+	calls[25].AllowFailure = false
+	if _, err := m.Aggregate3(context.Background(), calls, nil); err == nil {
+		t.Fatal("want multicall failed on a call not allowed to fail")
+	}
+}
+
+func TestChunkCalls(t *testing.T) {
+	config := Config{MaxCalldataSize: 1024}
+	calls := []Call{
+		{CallData: make([]byte, 4)},    // 192 bytes
+		{CallData: make([]byte, 600)},  // 768 bytes
+		{CallData: make([]byte, 2000)}, // exceeds on its own
+		{CallData: make([]byte, 4)},
+	}
+
+	chunks := chunkCalls(calls, config)
+	if len(chunks) != 3 || len(chunks[0]) != 2 || len(chunks[1]) != 1 || len(chunks[2]) != 1 {
+		t.Fatalf("unexpected chunks: %v", len(chunks))
+	}
+}
+
+func TestCallContract_Coalesced(t *testing.T) {
+	config := DefaultConfig()
+	config.Window = 50 * time.Millisecond
+	m, backend, intf := newTestMulticaller(t, config)
+
+	var wg sync.WaitGroup
+	rets := make([][]byte, 10)
+	errs := make([]error, 10)
+	for i := range rets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			target := token
+			if i == 9 {
+				target = paused
+			}
+			rets[i], errs[i] = m.CallContract(context.Background(), ethereum.CallMsg{To: &target, Data: balanceOf(t, intf, int64(i))}, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	if backend.calls.Load() != 1 {
+		t.Fatalf("want calls coalesced into one multicall, got %v", backend.calls.Load())
+	}
+	for i := 0; i < 9; i++ {
+		if errs[i] != nil || new(big.Int).SetBytes(rets[i]).Int64() != int64(i) {
+			t.Fatalf("unexpected result %v: %x %v", i, rets[i], errs[i])
+		}
+	}
+	if !errors.Is(consts.DecodeJsonRpcError(errs[9], abi.ABI{}), consts.ErrExecutionReverted) {
+		t.Fatalf("want call reverted, got %v", errs[9])
+	}
+
+	// calls with a sender are not coalesced
+	from := common.HexToAddress("0x1")
+	if _, err := m.CallContract(context.Background(), ethereum.CallMsg{From: from, To: &token, Data: balanceOf(t, intf, 1)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if backend.calls.Load() != 2 {
+		t.Fatal("want call with a sender done on its own")
+	}
+}
+
+func TestCallContract_Fallback(t *testing.T) {
+	config := DefaultConfig()
+	config.Window = 50 * time.Millisecond
+	m, backend, intf := newTestMulticaller(t, config)
+	// Multicall3 not deployed
+	backend.multicall = common.HexToAddress("0x3")
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ret, err := m.CallContract(context.Background(), ethereum.CallMsg{To: &token, Data: balanceOf(t, intf, int64(i))}, nil)
+			if err != nil || !bytes.Equal(ret, common.LeftPadBytes(big.NewInt(int64(i)).Bytes(), 32)) {
+				t.Errorf("unexpected result %v: %x %v", i, ret, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCallContract_CoalesceTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.Window = 50 * time.Millisecond
+	config.CoalesceTimeout = 100 * time.Millisecond
+	m, backend, intf := newTestMulticaller(t, config)
+	backend.hang = true
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// calls are done on their own once the multicall times out
+			ret, err := m.CallContract(context.Background(), ethereum.CallMsg{To: &token, Data: balanceOf(t, intf, int64(i))}, nil)
+			if err != nil || new(big.Int).SetBytes(ret).Int64() != int64(i) {
+				t.Errorf("unexpected result %v: %x %v", i, ret, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -16,9 +16,22 @@ type ethBackend interface {
 }
 
 type Manager interface {
+	// PendingNonceAt assigns the next nonce of account, which is considered used right away.
 	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	// ReserveNonce assigns the next nonce of account, reusing the lowest one released if any.
+	// The nonce must be committed once its tx is sent, or released if the tx is not sent.
+	ReserveNonce(ctx context.Context, account common.Address) (uint64, error)
+	CommitNonce(account common.Address, nonce uint64) error
+	// ReleaseNonce gives back a nonce reserved, so that it's reused by the next reservation.
+	ReleaseNonce(account common.Address, nonce uint64) error
+	// ReserveNonceGaps reserves the nonces of account left unused, which block the txs
+	// with higher nonces. They must be filled (e.g. with no-op txs) and committed, or released.
+	ReserveNonceGaps(ctx context.Context, account common.Address) ([]uint64, error)
 	PeekNonce(account common.Address) (uint64, error)
 	ResetNonce(ctx context.Context, account common.Address) error
+	// SyncNonce raises the next nonce of account to the pending nonce of the node if it's behind,
+	// e.g. after txs sent by other processes.
+	SyncNonce(ctx context.Context, account common.Address) error
 	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
 	SuggestGasPrice(ctx context.Context) (*big.Int, error)
 	SetNonceAt(nonceAt NonceAtFunc)
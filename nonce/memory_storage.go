@@ -9,14 +9,16 @@ import (
 var _ Storage = &MemoryStorage{}
 
 type MemoryStorage struct {
-	lockMap  sync.Map
-	nonceMap map[common.Address]uint64
+	lockMap     sync.Map
+	nonceMap    map[common.Address]uint64
+	releasedMap map[common.Address][]uint64
 }
 
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		nonceMap: make(map[common.Address]uint64),
-		lockMap:  sync.Map{},
+		nonceMap:    make(map[common.Address]uint64),
+		releasedMap: make(map[common.Address][]uint64),
+		lockMap:     sync.Map{},
 	}
 }
 
@@ -36,3 +38,15 @@ func (s *MemoryStorage) SetNonce(account common.Address, nonce uint64) error {
 
 	return nil
 }
+
+func (s *MemoryStorage) GetReleasedNonces(account common.Address) ([]uint64, error) {
+	nonces := s.releasedMap[account]
+
+	return append([]uint64{}, nonces...), nil
+}
+
+func (s *MemoryStorage) SetReleasedNonces(account common.Address, nonces []uint64) error {
+	s.releasedMap[account] = append([]uint64{}, nonces...)
+
+	return nil
+}
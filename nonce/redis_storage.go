@@ -2,6 +2,7 @@ package nonce
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strconv"
@@ -82,3 +83,53 @@ func (s *RedisStorage) SetNonce(account common.Address, nonce uint64) error {
 
 	return nil
 }
+
+func (s *RedisStorage) GetReleasedNonces(account common.Address) ([]uint64, error) {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	releasedKey := fmt.Sprintf("nonce-released-chain-%s-account-%s", s.chainId.String(), strings.ToLower(account.Hex()))
+	releasedStr, err := conn.Get(releasedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if releasedStr == "" {
+		return nil, nil
+	}
+
+	var nonces []uint64
+	err = json.Unmarshal([]byte(releasedStr), &nonces)
+	if err != nil {
+		return nil, err
+	}
+
+	return nonces, nil
+}
+
+func (s *RedisStorage) SetReleasedNonces(account common.Address, nonces []uint64) error {
+	conn, err := s.redisPool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+
+	releasedKey := fmt.Sprintf("nonce-released-chain-%s-account-%s", s.chainId.String(), strings.ToLower(account.Hex()))
+
+	releasedJs, err := json.Marshal(nonces)
+	if err != nil {
+		return err
+	}
+
+	ok, err := conn.Set(releasedKey, string(releasedJs))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("set released nonces failed")
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package nonce
 import (
 	"context"
 	"math/big"
+	"slices"
 	"sync"
 
 	"github.com/ethereum/go-ethereum"
@@ -16,6 +17,12 @@ type SimpleManager struct {
 	Storage
 	backend ethBackend
 	NonceAt NonceAtFunc
+
+	mu sync.Mutex
+	// nonces reserved by this process, neither committed nor released yet
+	reserved map[common.Address]map[uint64]struct{}
+	// gaps found by the last ReserveNonceGaps
+	gaps map[common.Address]map[uint64]struct{}
 }
 
 var snm *SimpleManager
@@ -45,33 +52,189 @@ func (nm *SimpleManager) PendingNonceAt(ctx context.Context, account common.Addr
 	locker.Lock()
 	defer locker.Unlock()
 
-	var (
-		nonce uint64
-		err   error
-	)
+	return nm.nextNonce(ctx, account)
+}
+
+func (nm *SimpleManager) ReserveNonce(ctx context.Context, account common.Address) (uint64, error) {
+	locker := nm.NonceLockFrom(account)
+	locker.Lock()
+	defer locker.Unlock()
 
-	nonce, err = nm.GetNonce(account)
+	nonce, err := nm.nextNonce(ctx, account)
 	if err != nil {
 		return 0, err
 	}
 
-	var nonceInLatest uint64
-	if nm.NonceAt == nil {
-		nonceInLatest, err = nm.backend.NonceAt(ctx, account, nil)
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		nonceInLatest, err = nm.NonceAt(ctx, account, nil)
-		if err != nil {
-			return 0, err
+	nm.setReserved(account, nonce, true)
+
+	return nonce, nil
+}
+
+func (nm *SimpleManager) CommitNonce(account common.Address, nonce uint64) error {
+	nm.setReserved(account, nonce, false)
+
+	log.Debug("commit nonce", "account", account.Hex(), "nonce", nonce)
+
+	return nil
+}
+
+func (nm *SimpleManager) ReleaseNonce(account common.Address, nonce uint64) error {
+	locker := nm.NonceLockFrom(account)
+	locker.Lock()
+	defer locker.Unlock()
+
+	nm.setReserved(account, nonce, false)
+
+	next, err := nm.GetNonce(account)
+	if err != nil {
+		return err
+	}
+
+	if nonce >= next {
+		// The nonce was reset meanwhile.
+		log.Warn("release nonce not assigned", "account", account.Hex(), "nonce", nonce, "next", next)
+		return nil
+	}
+
+	released, err := nm.GetReleasedNonces(account)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(released, nonce) {
+		released = append(released, nonce)
+	}
+
+	// Nonces released at the end are simply assigned again, leaving no gap behind.
+	for next > 0 && slices.Contains(released, next-1) {
+		released = slices.DeleteFunc(released, func(n uint64) bool { return n == next-1 })
+		next--
+	}
+
+	err = nm.SetNonce(account, next)
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(released)
+	err = nm.SetReleasedNonces(account, released)
+	if err != nil {
+		return err
+	}
+
+	log.Info("release nonce", "account", account.Hex(), "nonce", nonce, "next", next, "released", released)
+
+	return nil
+}
+
+// ReserveNonceGaps finds the nonces released but not reused, and the pending nonce of the node
+// if it's neither reserved nor sent. A gap is reserved only if it was found by the previous call too,
+// which gives the txs being sent time to reach the node.
+func (nm *SimpleManager) ReserveNonceGaps(ctx context.Context, account common.Address) ([]uint64, error) {
+	locker := nm.NonceLockFrom(account)
+	locker.Lock()
+	defer locker.Unlock()
+
+	nonceInLatest, err := nm.latestNonce(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceInPending, err := nm.backend.PendingNonceAt(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := nm.GetNonce(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if next == 0 || nonceInLatest > next {
+		next = nonceInLatest
+	}
+
+	released, err := nm.releasedNonces(account, nonceInLatest, next)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint64]struct{})
+	for _, nonce := range released {
+		found[nonce] = struct{}{}
+	}
+	if nonceInPending < next && !nm.isReserved(account, nonceInPending) {
+		found[nonceInPending] = struct{}{}
+	}
+
+	nm.mu.Lock()
+	if nm.gaps == nil {
+		nm.gaps = make(map[common.Address]map[uint64]struct{})
+	}
+	last := nm.gaps[account]
+	nm.gaps[account] = found
+	nm.mu.Unlock()
+
+	var gaps []uint64
+	for nonce := range found {
+		if _, ok := last[nonce]; ok {
+			gaps = append(gaps, nonce)
 		}
 	}
 
+	if len(gaps) == 0 {
+		return nil, nil
+	}
+
+	slices.Sort(gaps)
+
+	released = slices.DeleteFunc(released, func(n uint64) bool { return slices.Contains(gaps, n) })
+	err = nm.SetReleasedNonces(account, released)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nonce := range gaps {
+		nm.setReserved(account, nonce, true)
+	}
+
+	log.Warn("nonce gaps found", "account", account.Hex(), "gaps", gaps, "nonceInLatest", nonceInLatest,
+		"nonceInPending", nonceInPending, "next", next)
+
+	return gaps, nil
+}
+
+// nextNonce assigns the lowest nonce released, or the next one. It's not locked.
+func (nm *SimpleManager) nextNonce(ctx context.Context, account common.Address) (uint64, error) {
+	nonce, err := nm.GetNonce(account)
+	if err != nil {
+		return 0, err
+	}
+
+	nonceInLatest, err := nm.latestNonce(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+
 	if nonce == 0 || nonceInLatest > nonce {
 		nonce = nonceInLatest
 	}
 
+	released, err := nm.releasedNonces(account, nonceInLatest, nonce)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(released) > 0 {
+		err = nm.SetReleasedNonces(account, released[1:])
+		if err != nil {
+			return 0, err
+		}
+
+		log.Info("reuse released nonce", "account", account.Hex(), "nonce", released[0], "nonceInLatest", nonceInLatest)
+		return released[0], nil
+	}
+
 	log.Info("pending nonce at", "account", account.Hex(), "nonce", nonce, "nonceInLatest", nonceInLatest)
 
 	err = nm.SetNonce(account, nonce+1)
@@ -82,6 +245,54 @@ func (nm *SimpleManager) PendingNonceAt(ctx context.Context, account common.Addr
 	return nonce, nil
 }
 
+// releasedNonces returns the nonces released in [nonceInLatest, next) in order. The others are
+// either used on chain already or assigned again.
+func (nm *SimpleManager) releasedNonces(account common.Address, nonceInLatest, next uint64) ([]uint64, error) {
+	released, err := nm.GetReleasedNonces(account)
+	if err != nil {
+		return nil, err
+	}
+
+	released = slices.DeleteFunc(released, func(n uint64) bool { return n < nonceInLatest || n >= next })
+	slices.Sort(released)
+
+	return released, nil
+}
+
+func (nm *SimpleManager) latestNonce(ctx context.Context, account common.Address) (uint64, error) {
+	if nm.NonceAt == nil {
+		return nm.backend.NonceAt(ctx, account, nil)
+	}
+
+	return nm.NonceAt(ctx, account, nil)
+}
+
+func (nm *SimpleManager) isReserved(account common.Address, nonce uint64) bool {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	_, ok := nm.reserved[account][nonce]
+	return ok
+}
+
+func (nm *SimpleManager) setReserved(account common.Address, nonce uint64, reserved bool) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if !reserved {
+		delete(nm.reserved[account], nonce)
+		return
+	}
+
+	if nm.reserved == nil {
+		nm.reserved = make(map[common.Address]map[uint64]struct{})
+	}
+	if nm.reserved[account] == nil {
+		nm.reserved[account] = make(map[uint64]struct{})
+	}
+	nm.reserved[account][nonce] = struct{}{}
+}
+
 func (nm *SimpleManager) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
 	gas, err := nm.backend.EstimateGas(ctx, msg)
 	if err != nil {
@@ -124,24 +335,60 @@ func (nm *SimpleManager) ResetNonce(ctx context.Context, account common.Address)
 	locker.Lock()
 	defer locker.Unlock()
 
-	var nonceInLatest uint64
-	if nm.NonceAt == nil {
-		nonceInLatest, err = nm.backend.NonceAt(ctx, account, nil)
-		if err != nil {
-			return err
-		}
-	} else {
-		nonceInLatest, err = nm.NonceAt(ctx, account, nil)
+	nonceInLatest, err := nm.latestNonce(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	err = nm.SetNonce(account, nonceInLatest)
+	if err != nil {
+		return err
+	}
+
+	err = nm.SetReleasedNonces(account, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (nm *SimpleManager) SyncNonce(ctx context.Context, account common.Address) error {
+	locker := nm.NonceLockFrom(account)
+	locker.Lock()
+	defer locker.Unlock()
+
+	nonceInPending, err := nm.backend.PendingNonceAt(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	next, err := nm.GetNonce(account)
+	if err != nil {
+		return err
+	}
+
+	if next < nonceInPending {
+		err = nm.SetNonce(account, nonceInPending)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = nm.SetNonce(account, nonceInLatest)
+	// Nonces released below are used by the txs in the pool.
+	released, err := nm.GetReleasedNonces(account)
 	if err != nil {
 		return err
 	}
 
+	released = slices.DeleteFunc(released, func(n uint64) bool { return n < nonceInPending })
+	err = nm.SetReleasedNonces(account, released)
+	if err != nil {
+		return err
+	}
+
+	log.Info("sync nonce", "account", account.Hex(), "next", max(next, nonceInPending), "nonceInPending", nonceInPending)
+
 	return nil
 }
 
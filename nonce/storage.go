@@ -12,4 +12,8 @@ type Storage interface {
 	GetNonce(account common.Address) (uint64, error)
 	// without locks
 	SetNonce(account common.Address, nonce uint64) error
+	// without locks
+	GetReleasedNonces(account common.Address) ([]uint64, error)
+	// without locks
+	SetReleasedNonces(account common.Address, nonces []uint64) error
 }
@@ -0,0 +1,192 @@
+package ethclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ivanzzeth/ethclient/message"
+)
+
+// RecoveryReport describes what was done with the unfinished messages found in storage
+// when the client was created.
+type RecoveryReport struct {
+	// Submitted msgs sent to the scheduler again, including future StartTime and Interval ticks.
+	Rescheduled []common.Hash
+	// Scheduled or queued msgs sent to the sequencer again.
+	Requeued []common.Hash
+	// Broadcasted msgs found on chain, their finality is tracked from now on.
+	Confirmed []common.Hash
+	// Broadcasted msgs not on chain yet, protected again.
+	Protected []common.Hash
+	// Msgs left as they are, they need a human decision.
+	Unreconciled []UnreconciledMsg
+}
+
+type UnreconciledMsg struct {
+	MsgId  common.Hash
+	Status message.MessageStatus
+	Reason string
+}
+
+func (r *RecoveryReport) unreconciled(msg message.Message, reason string) {
+	log.Warn("unable to recover msg", "msgId", msg.Id().Hex(), "status", msg.Status, "reason", reason)
+	r.Unreconciled = append(r.Unreconciled, UnreconciledMsg{MsgId: msg.Id(), Status: msg.Status, Reason: reason})
+}
+
+// RecoveryReport returns what was recovered when the client was created.
+func (c *Client) RecoveryReport() RecoveryReport {
+	return c.recoveryReport
+}
+
+// recoverMsgs feeds the unfinished msgs left in storage by a previous process back into the pipeline.
+// It's a no-op if the storage cannot list msgs.
+func (c *Client) recoverMsgs(ctx context.Context) (report RecoveryReport, err error) {
+	lister, ok := c.msgStore.(message.StorageLister)
+	if !ok {
+		log.Debug("msg storage does not support listing msgs, skip recovery")
+		return
+	}
+
+	msgs := make(map[message.MessageStatus][]message.Message)
+	for _, status := range []message.MessageStatus{
+		message.MessageStatusSubmitted,
+		message.MessageStatusScheduled,
+		message.MessageStatusQueued,
+		message.MessageStatusNonceAssigned,
+		message.MessageStatusInflight,
+		message.MessageStatusCancelled,
+	} {
+		msgIds, err := lister.MsgIdsByStatus(status)
+		if err != nil {
+			return report, err
+		}
+
+		for _, msgId := range msgIds {
+			msg, err := c.msgStore.GetMsg(msgId)
+			if err != nil {
+				return report, err
+			}
+
+			// Msgs which failed keep the status they failed at.
+			if msg.Resp != nil && msg.Resp.Err != nil {
+				continue
+			}
+
+			msgs[status] = append(msgs[status], msg)
+		}
+	}
+
+	// Recurring tasks having their next tick submitted already.
+	ticking := make(map[common.Hash]bool)
+	for _, msg := range msgs[message.MessageStatusSubmitted] {
+		if msg.Req.Interval != 0 && msg.Root != nil {
+			ticking[*msg.Root] = true
+		}
+	}
+
+	for _, msg := range msgs[message.MessageStatusSubmitted] {
+		c.reqChannel <- *msg.Req
+		report.Rescheduled = append(report.Rescheduled, msg.Id())
+	}
+
+	for _, status := range []message.MessageStatus{message.MessageStatusScheduled, message.MessageStatusQueued} {
+		for _, msg := range msgs[status] {
+			root := msg.Id()
+			if msg.Root != nil {
+				root = *msg.Root
+			}
+
+			// The next tick of a recurring task is created by the scheduler, so go through it
+			// again if the previous process stopped before creating it.
+			if msg.Req.Interval != 0 && !ticking[root] {
+				c.reqChannel <- *msg.Req
+				report.Rescheduled = append(report.Rescheduled, msg.Id())
+				continue
+			}
+
+			c.scheduleChannel <- *msg.Req
+			report.Requeued = append(report.Requeued, msg.Id())
+		}
+	}
+
+	for _, status := range []message.MessageStatus{message.MessageStatusNonceAssigned, message.MessageStatusInflight, message.MessageStatusCancelled} {
+		for _, msg := range msgs[status] {
+			if msg.Receipt != nil {
+				continue
+			}
+
+			// Only cancellations broadcasted need to get on chain.
+			if status == message.MessageStatusCancelled && (msg.Resp == nil || msg.Resp.Tx == nil) {
+				continue
+			}
+
+			if err := c.recoverBroadcastedMsg(ctx, msg, &report); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	log.Info("recovered msgs", "rescheduled", len(report.Rescheduled), "requeued", len(report.Requeued),
+		"confirmed", len(report.Confirmed), "protected", len(report.Protected), "unreconciled", len(report.Unreconciled))
+
+	return report, nil
+}
+
+func (c *Client) recoverBroadcastedMsg(ctx context.Context, msg message.Message, report *RecoveryReport) error {
+	if msg.Resp == nil || msg.Resp.Tx == nil {
+		// The tx may or may not have been broadcasted, sending it again could execute it twice.
+		report.unreconciled(msg, "nonce assigned but no tx recorded")
+		return nil
+	}
+
+	tx := msg.Resp.Tx
+	receipt, err := c.TransactionReceipt(ctx, tx.Hash())
+	if err == nil {
+		status := message.MessageStatusOnChain
+		if msg.Status == message.MessageStatusCancelled {
+			status = message.MessageStatusCancelled
+		}
+
+		onChain := message.Receipt{Id: msg.Id(), TxReceipt: receipt, Status: status}
+		err = c.msgStore.UpdateReceipt(msg.Id(), onChain)
+		if err != nil {
+			return err
+		}
+
+		err = c.msgStore.UpdateMsgStatus(msg.Id(), status)
+		if err != nil {
+			return err
+		}
+		c.emitReceipt(onChain)
+
+		report.Confirmed = append(report.Confirmed, msg.Id())
+		return nil
+	}
+
+	if err != ethereum.NotFound {
+		return err
+	}
+
+	nonce, err := c.NonceAt(ctx, msg.Req.From, nil)
+	if err != nil {
+		return err
+	}
+
+	if nonce > tx.Nonce() {
+		report.unreconciled(msg, fmt.Sprintf("nonce %v was consumed by another tx than %v", tx.Nonce(), tx.Hash().Hex()))
+		return nil
+	}
+
+	if next, err := c.nonceManager.PeekNonce(msg.Req.From); err == nil && next <= tx.Nonce() {
+		log.Warn("nonce storage is behind recovered msgs, use a persistent nonce storage along with the msg storage",
+			"msgId", msg.Id().Hex(), "account", msg.Req.From.Hex(), "nonce", tx.Nonce(), "nextNonce", next)
+	}
+
+	c.broadcaster.ProtectMsg(ctx, msg.Id())
+	report.Protected = append(report.Protected, msg.Id())
+
+	return nil
+}
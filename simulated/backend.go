@@ -32,6 +32,8 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -171,6 +173,8 @@ func newWithNode(stack *node.Node, conf *eth.Config, blockPeriod uint64) (*Backe
 		Namespace: "eth",
 		Service:   filters.NewFilterAPI(filterSystem),
 	}})
+	// Register the debug namespace for tracing txs
+	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
 	// Start the node
 	if err := stack.Start(); err != nil {
 		return nil, err
@@ -45,6 +45,7 @@ type ChainSubscriber struct {
 	currBlocksPerScan                uint64 // adjust dynamiclly
 	maxBlocksPerScan                 uint64
 	blockConfirmationsOnSubscription uint64
+	reorgWindow                      uint64
 	storage                          SubscriberStorage
 
 	queryCtx           context.Context
@@ -52,14 +53,21 @@ type ChainSubscriber struct {
 	queryHandler       QueryHandler
 	queryMap           sync.Map
 	globalLogsChannels sync.Map
+	reorgTrackers      sync.Map
 
 	realtimeMu                    sync.Mutex
 	realtimeQueries               map[common.Hash][]*realtimeEntry // same query can have multiple subscribers (channels)
 	realtimeScannerStart          sync.Once
 	maxQueriesPerMerge            int // if > 0, split partition into batches of this size to avoid RPC returning 0 (e.g. BSC); 0 = no limit
 	consecutiveZeroLogThreshold   int // number of consecutive 0-log scans before forcing FromBlock advance (default: 3)
+
+	progressMu sync.Mutex
+	onProgress ProgressHandler
 }
 
+// ProgressHandler is called each time a query subscribed was scanned up to block, head being the latest block.
+type ProgressHandler func(queryHash common.Hash, block uint64, head uint64)
+
 // NewChainSubscriber .
 func NewChainSubscriber(rpcCli *rpc.Client, storage SubscriberStorage) (*ChainSubscriber, error) {
 	c := ethclient.NewClient(rpcCli)
@@ -81,6 +89,7 @@ func NewChainSubscriber(rpcCli *rpc.Client, storage SubscriberStorage) (*ChainSu
 		currBlocksPerScan: consts.DefaultBlocksPerScan,
 		maxBlocksPerScan:  consts.MaxBlocksPerScan,
 		retryInterval:     consts.RetryInterval,
+		reorgWindow:       DefaultReorgWindow,
 		storage:           storage,
 		queryCtx:          queryCtx,
 		cancelQueryCtx:    cancel,
@@ -137,6 +146,23 @@ func (cs *ChainSubscriber) SetConsecutiveZeroLogThreshold(n int) {
 	cs.consecutiveZeroLogThreshold = n
 }
 
+// SetProgressHandler sets the handler called with the progress of queries subscribed, e.g. for monitoring their lag.
+func (cs *ChainSubscriber) SetProgressHandler(handler ProgressHandler) {
+	cs.progressMu.Lock()
+	defer cs.progressMu.Unlock()
+	cs.onProgress = handler
+}
+
+func (cs *ChainSubscriber) reportProgress(queryHash common.Hash, block uint64, head uint64) {
+	cs.progressMu.Lock()
+	onProgress := cs.onProgress
+	cs.progressMu.Unlock()
+
+	if onProgress != nil {
+		onProgress(queryHash, block, head)
+	}
+}
+
 type subscription struct {
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -164,6 +190,69 @@ func (cs *ChainSubscriber) SetBlockConfirmationsOnSubscription(confirmations uin
 	cs.blockConfirmationsOnSubscription = confirmations
 }
 
+// SetReorgWindow sets how many blocks below the head are checked for reorgs, 0 disables the detection.
+// Logs delivered from blocks dropped by a reorg are delivered again with Removed set,
+// followed by the logs of the new canonical blocks.
+func (cs *ChainSubscriber) SetReorgWindow(window uint64) {
+	cs.reorgWindow = window
+}
+
+func (cs *ChainSubscriber) getReorgTracker(queryHash common.Hash) *reorgTracker {
+	tracker, _ := cs.reorgTrackers.LoadOrStore(queryHash, newReorgTracker(cs.reorgWindow))
+	return tracker.(*reorgTracker)
+}
+
+// scannedHeader returns the header of the block scanned up to if it may still be reorged, nil otherwise.
+func (cs *ChainSubscriber) scannedHeader(ctx context.Context, head uint64, endBlock uint64) *etypes.Header {
+	if cs.reorgWindow == 0 || endBlock+cs.reorgWindow < head {
+		return nil
+	}
+
+	header, err := cs.c.HeaderByNumber(ctx, big.NewInt(0).SetUint64(endBlock))
+	if err != nil {
+		log.Warn("get header of scanned block failed", "err", err, "block", endBlock)
+		return nil
+	}
+
+	return header
+}
+
+// handleReorg delivers the logs of blocks dropped by a reorg with Removed set, then rolls
+// the query state back to the fork block, through the query handler if it's a QueryRollbacker,
+// otherwise in storage if not nil.
+func (cs *ChainSubscriber) handleReorg(ctx context.Context, tracker *reorgTracker, q ethereum.FilterQuery,
+	storage SubscriberStorage, chs ...chan<- etypes.Log) (*chainReorg, error) {
+	reorg, err := tracker.check(ctx, cs.c)
+	if err != nil || reorg == nil {
+		return nil, err
+	}
+
+	log.Warn("chain reorg detected", "queryHash", GetQueryHash(cs.chainId, q),
+		"forkBlock", reorg.ForkBlock, "removedLogs", len(reorg.RemovedLogs))
+
+	for _, l := range reorg.RemovedLogs {
+		for _, ch := range chs {
+			select {
+			case ch <- l:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	// Roll the handler back even if no logs were removed.
+	if rollbacker, ok := cs.queryHandler.(QueryRollbacker); ok {
+		err = rollbacker.RollbackQuery(ctx, NewQuery(cs.chainId, q), reorg.ForkBlock)
+	} else if storage != nil {
+		err = RollbackQuery(ctx, storage, q, reorg.ForkBlock)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reorg, nil
+}
+
 func (cs *ChainSubscriber) SetBuffer(buffer int) {
 	cs.buffer = buffer
 }
@@ -325,9 +414,11 @@ func (cs *ChainSubscriber) runRealtimeScanner() {
 
 	queryStateReader := cs.storage
 	queryStateWriter := cs.storage
+	var queryStorage SubscriberStorage = cs.storage
 	if cs.isQueryHandlerSet() {
 		queryStateReader = cs.queryHandler
 		queryStateWriter = cs.queryHandler
+		queryStorage = cs.queryHandler
 	}
 
 	for {
@@ -363,8 +454,20 @@ func (cs *ChainSubscriber) runRealtimeScanner() {
 			time.Sleep(cs.retryInterval)
 			continue
 		}
+		head := lastBlock
 		lastBlock -= cs.blockConfirmationsOnSubscription
 
+		for _, g := range groups {
+			chs := make([]chan<- etypes.Log, len(g.entries))
+			for i, e := range g.entries {
+				chs[i] = e.ch
+			}
+			_, err := cs.handleReorg(ctx, cs.getReorgTracker(g.hash), g.query, queryStorage, chs...)
+			if err != nil {
+				log.Warn("realtime scanner handle reorg failed", "err", err, "queryHash", g.hash)
+			}
+		}
+
 		minStart := uint64(0)
 		for _, g := range groups {
 			latest, err := queryStateReader.LatestBlockForQuery(ctx, g.query)
@@ -394,6 +497,8 @@ func (cs *ChainSubscriber) runRealtimeScanner() {
 		startBlock := minStart
 		fromBlock := big.NewInt(0).SetUint64(startBlock)
 		toBlock := big.NewInt(0).SetUint64(endBlock)
+		// Fetched before logs, so that a reorg during the scan is found on the next cycle.
+		endHeader := cs.scannedHeader(ctx, head, endBlock)
 
 		// Greedy partition by (BlockHash, FromBlock, ToBlock): same key → one merged eth_getLogs.
 		// Realtime queries have nil range; fallback fromBlock/toBlock gives one range partition.
@@ -627,6 +732,11 @@ func (cs *ChainSubscriber) runRealtimeScanner() {
 						}
 					}
 				}
+				tracker := cs.getReorgTracker(g.hash)
+				tracker.trackLogs(head, logsForGroup)
+				if endHeader != nil {
+					tracker.track(head, endBlock, endHeader.Hash(), nil)
+				}
 				// Diagnose missed dispatch: only warn when this group's filter (topic0/address) matches a log in the batch but got 0 → LogMatchesQuery bug. Applies to any event type (Split/Merge/Redeem/OrderFilled).
 				if len(mergedLogs) > 0 && len(logsForGroup) == 0 {
 					var queryTopic0 common.Hash
@@ -675,6 +785,9 @@ func (cs *ChainSubscriber) runRealtimeScanner() {
 						log.Error("realtime scanner SaveLatestBlockForQuery failed", "err", err, "queryHash", g.hash)
 					}
 				}
+				if advanceProgress {
+					cs.reportProgress(g.hash, endBlock, head)
+				}
 			}
 			// Log any log returned by RPC that matched no subscription (dispatch miss). Applies to all event types (Split/Merge/Redeem/OrderFilled).
 			for i := range mergedLogs {
@@ -759,8 +872,6 @@ func (cs *ChainSubscriber) FilterLogs(ctx context.Context, q ethereum.FilterQuer
 	return
 }
 
-// TODO:
-// 3. cache all of finalized historical data, e.g., blockByHash, txByHash
 func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum.FilterQuery, logsChan chan<- etypes.Log, watch bool, closeOnExit bool) (err error) {
 	if q.BlockHash != nil {
 		logs, err := cs.logFilterer.FilterLogs(ctx, q)
@@ -826,6 +937,15 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 
 	var lastBlockAtomic atomic.Uint64
 
+	var queryStorage SubscriberStorage
+	if useStorage {
+		queryStorage = cs.storage
+		if cs.isQueryHandlerSet() {
+			queryStorage = cs.queryHandler
+		}
+	}
+	tracker := newReorgTracker(cs.reorgWindow)
+
 	go func() {
 		for {
 			lastBlock, err := cs.c.BlockNumber(ctx)
@@ -881,6 +1001,21 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 					time.Sleep(cs.retryInterval)
 					continue Scan
 				}
+				head := lastBlock
+
+				if watch {
+					reorg, err := cs.handleReorg(ctx, tracker, q, queryStorage, logsChan)
+					if err != nil {
+						log.Warn("Subscriber FilterLogs handle reorg failed", "err", err, "queryHash", query.Hash())
+						time.Sleep(cs.retryInterval)
+						continue Scan
+					}
+
+					if reorg != nil && reorg.ForkBlock < startBlock {
+						startBlock = reorg.ForkBlock + 1
+						endBlock = startBlock + cs.currBlocksPerScan
+					}
+				}
 
 				if watch {
 					if lastBlock >= cs.blockConfirmationsOnSubscription {
@@ -935,6 +1070,11 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 				}
 				var lgs []etypes.Log
 
+				var endHeader *etypes.Header
+				if watch {
+					endHeader = cs.scannedHeader(ctx, head, endBlock)
+				}
+
 				if cs.storage.IsFilterLogsSupported(filterQuery) {
 					lgs, err = cs.storage.FilterLogs(ctx, filterQuery)
 				} else {
@@ -996,6 +1136,7 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 					log.Debug("latestHandledLog", "queryHash", query.Hash(), "query", q, "log", latestHandledLog)
 				}
 
+				var delivered []etypes.Log
 				for _, l := range lgs {
 					log.Debug("Subscriber FilterLogs is sending log", "queryHash", query.Hash(), "log", l, "latest_log", latestHandledLog)
 
@@ -1013,6 +1154,7 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 					}
 
 					logsChan <- l
+					delivered = append(delivered, l)
 
 					// if query handler is set, HandleQuery will be called.
 					// so we do not update latestLog twice after sending log.
@@ -1027,6 +1169,13 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 					}
 				}
 
+				if watch {
+					tracker.trackLogs(head, delivered)
+					if endHeader != nil {
+						tracker.track(head, endBlock, endHeader.Hash(), nil)
+					}
+				}
+
 				// if there's no logs emitted during block range, enforcely update latestBlock
 				// if query handler is not set, update latestBlock.
 				if useStorage && queryStateWriter != nil && (!cs.isQueryHandlerSet() || len(lgs) == 0) {
@@ -1045,6 +1194,10 @@ func (cs *ChainSubscriber) FilterLogsWithChannel(ctx context.Context, q ethereum
 					}
 				}
 
+				if watch {
+					cs.reportProgress(query.Hash(), endBlock, head)
+				}
+
 				updateScan()
 			}
 		}
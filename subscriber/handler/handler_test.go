@@ -52,3 +52,69 @@ func TestSimpleQueryHandler_HandleQuery(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, uint64(15), latest)
 }
+
+func TestSimpleQueryHandler_HandleQuery_Removed(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(1337)
+	storage := subscriber.NewMemoryStorage(chainID)
+	h := NewSimpleQueryHandler(storage)
+
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	q := subscriber.NewQuery(chainID, ethereum.FilterQuery{Addresses: []common.Address{addr}})
+
+	err := h.HandleQuery(ctx, q, types.Log{BlockNumber: 12, TxIndex: 1, Index: 3, Address: addr, Topics: []common.Hash{}})
+	require.NoError(t, err)
+
+	// The log was dropped by a reorg, so state is rolled back before its block.
+	err = h.HandleQuery(ctx, q, types.Log{BlockNumber: 12, TxIndex: 1, Index: 3, Address: addr, Topics: []common.Hash{}, Removed: true})
+	require.NoError(t, err)
+
+	latest, err := storage.LatestBlockForQuery(ctx, q.FilterQuery)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), latest)
+
+	latestLog, err := storage.LatestLogForQuery(ctx, q.FilterQuery)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), latestLog.BlockNumber)
+}
+
+func TestSimpleQueryHandler_HandleQuery_RemovedAtGenesis(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(1337)
+	storage := subscriber.NewMemoryStorage(chainID)
+	h := NewSimpleQueryHandler(storage)
+
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	q := subscriber.NewQuery(chainID, ethereum.FilterQuery{Addresses: []common.Address{addr}})
+
+	err := h.HandleQuery(ctx, q, types.Log{BlockNumber: 0, Address: addr, Topics: []common.Hash{}})
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveLatestBlockForQuery(ctx, q.FilterQuery, 3))
+
+	err = h.HandleQuery(ctx, q, types.Log{BlockNumber: 0, Address: addr, Topics: []common.Hash{}, Removed: true})
+	require.NoError(t, err)
+
+	latest, err := storage.LatestBlockForQuery(ctx, q.FilterQuery)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), latest)
+}
+
+func TestSimpleQueryHandler_RollbackQuery(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(1337)
+	storage := subscriber.NewMemoryStorage(chainID)
+	h := NewSimpleQueryHandler(storage)
+
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	q := subscriber.NewQuery(chainID, ethereum.FilterQuery{Addresses: []common.Address{addr}})
+
+	err := h.HandleQuery(ctx, q, types.Log{BlockNumber: 12, TxIndex: 1, Index: 3, Address: addr, Topics: []common.Hash{}})
+	require.NoError(t, err)
+
+	// Rolled back to the fork block of a reorg.
+	require.NoError(t, h.RollbackQuery(ctx, q, 10))
+
+	latest, err := storage.LatestBlockForQuery(ctx, q.FilterQuery)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), latest)
+}
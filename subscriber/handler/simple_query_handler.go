@@ -8,7 +8,10 @@ import (
 	"github.com/ivanzzeth/ethclient/subscriber"
 )
 
-var _ subscriber.QueryHandler = (*SimpleQueryHandler)(nil)
+var (
+	_ subscriber.QueryHandler    = (*SimpleQueryHandler)(nil)
+	_ subscriber.QueryRollbacker = (*SimpleQueryHandler)(nil)
+)
 
 type SimpleQueryHandler struct {
 	subscriber.SubscriberStorage
@@ -20,6 +23,15 @@ func NewSimpleQueryHandler(storage subscriber.SubscriberStorage) *SimpleQueryHan
 }
 
 func (h *SimpleQueryHandler) HandleQuery(ctx context.Context, query subscriber.Query, l types.Log) error {
+	if l.Removed {
+		// Nothing is before the genesis block.
+		blockNum := l.BlockNumber
+		if blockNum > 0 {
+			blockNum--
+		}
+		return h.RollbackQuery(ctx, query, blockNum)
+	}
+
 	err := h.SaveLatestLogForQuery(ctx, query.FilterQuery, l)
 	if err != nil {
 		return err
@@ -34,3 +46,7 @@ func (h *SimpleQueryHandler) HandleQuery(ctx context.Context, query subscriber.Q
 
 	return nil
 }
+
+func (h *SimpleQueryHandler) RollbackQuery(ctx context.Context, query subscriber.Query, blockNum uint64) error {
+	return subscriber.RollbackQuery(ctx, h, query.FilterQuery, blockNum)
+}
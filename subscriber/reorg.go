@@ -0,0 +1,181 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultReorgWindow is the number of blocks below the head whose hashes are tracked for reorgs.
+const DefaultReorgWindow = uint64(64)
+
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*etypes.Header, error)
+}
+
+// chainReorg describes blocks scanned before that are no longer canonical.
+type chainReorg struct {
+	// The highest block scanned which is still canonical.
+	ForkBlock uint64
+	// Logs delivered from the dropped blocks with Removed set, in reverse order.
+	RemovedLogs []etypes.Log
+}
+
+type trackedBlock struct {
+	number uint64
+	hash   common.Hash
+	logs   []etypes.Log
+}
+
+// reorgTracker records the hashes of scanned blocks and the logs delivered from them,
+// so that blocks dropped by a reorg can be found on the next scan.
+type reorgTracker struct {
+	mu     sync.Mutex
+	window uint64
+	blocks []trackedBlock // ascending by number
+}
+
+func newReorgTracker(window uint64) *reorgTracker {
+	return &reorgTracker{window: window}
+}
+
+// track records a scanned block and the logs delivered from it. Blocks too far below head are ignored.
+func (t *reorgTracker) track(head uint64, number uint64, hash common.Hash, logs []etypes.Log) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.window == 0 || number+t.window < head {
+		return
+	}
+
+	i := len(t.blocks)
+	for i > 0 && t.blocks[i-1].number >= number {
+		i--
+	}
+
+	if i < len(t.blocks) && t.blocks[i].number == number {
+		if t.blocks[i].hash == hash {
+			t.blocks[i].logs = append(t.blocks[i].logs, logs...)
+		} else {
+			t.blocks[i] = trackedBlock{number: number, hash: hash, logs: logs}
+		}
+	} else {
+		t.blocks = append(t.blocks, trackedBlock{})
+		copy(t.blocks[i+1:], t.blocks[i:])
+		t.blocks[i] = trackedBlock{number: number, hash: hash, logs: logs}
+	}
+
+	newest := t.blocks[len(t.blocks)-1].number
+	start := 0
+	for start < len(t.blocks) && t.blocks[start].number+t.window < newest {
+		start++
+	}
+	t.blocks = t.blocks[start:]
+}
+
+// trackLogs records the blocks of the logs delivered.
+func (t *reorgTracker) trackLogs(head uint64, logs []etypes.Log) {
+	for start := 0; start < len(logs); {
+		end := start + 1
+		for end < len(logs) && logs[end].BlockHash == logs[start].BlockHash {
+			end++
+		}
+		t.track(head, logs[start].BlockNumber, logs[start].BlockHash, slices.Clone(logs[start:end]))
+		start = end
+	}
+}
+
+// check compares the blocks tracked with the canonical chain, from the newest one, and
+// returns nil if none of them was dropped.
+func (t *reorgTracker) check(ctx context.Context, reader headerReader) (*chainReorg, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.blocks) == 0 {
+		return nil, nil
+	}
+
+	var removed []etypes.Log
+	i := len(t.blocks) - 1
+	for ; i >= 0; i-- {
+		block := t.blocks[i]
+		header, err := reader.HeaderByNumber(ctx, big.NewInt(0).SetUint64(block.number))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+
+		if err == nil && header.Hash() == block.hash {
+			break
+		}
+
+		for j := len(block.logs) - 1; j >= 0; j-- {
+			l := block.logs[j]
+			l.Removed = true
+			removed = append(removed, l)
+		}
+	}
+
+	if i == len(t.blocks)-1 {
+		return nil, nil
+	}
+
+	var forkBlock uint64
+	if i >= 0 {
+		forkBlock = t.blocks[i].number
+	} else {
+		// Every block tracked was dropped, rescan from the oldest one.
+		if t.blocks[0].number > 0 {
+			forkBlock = t.blocks[0].number - 1
+		}
+		log.Warn("reorg deeper than the blocks tracked", "oldestTracked", t.blocks[0].number, "window", t.window)
+	}
+
+	t.blocks = t.blocks[:i+1]
+
+	return &chainReorg{ForkBlock: forkBlock, RemovedLogs: removed}, nil
+}
+
+// RollbackQuery moves the state of the query back to blockNum after a reorg, so that logs after it
+// are handled again. The state is left as is if it's not beyond blockNum.
+func RollbackQuery(ctx context.Context, storage SubscriberStorage, query ethereum.FilterQuery, blockNum uint64) error {
+	latest, err := storage.LatestBlockForQuery(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	latestLog, err := storage.LatestLogForQuery(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	if latest > blockNum {
+		err = storage.SaveLatestBlockForQuery(ctx, query, blockNum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if latestLog.BlockNumber > blockNum {
+		// All logs of blockNum were handled.
+		err = storage.SaveLatestLogForQuery(ctx, query, etypes.Log{
+			BlockNumber: blockNum,
+			TxIndex:     math.MaxUint,
+			Index:       math.MaxUint,
+			Topics:      []common.Hash{},
+			Data:        []byte{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
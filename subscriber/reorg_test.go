@@ -0,0 +1,144 @@
+package subscriber
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChain map[uint64]*etypes.Header
+
+func (c fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*etypes.Header, error) {
+	header, ok := c[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (c fakeChain) setBlock(number uint64, extra string) *etypes.Header {
+	header := &etypes.Header{Number: big.NewInt(0).SetUint64(number), Extra: []byte(extra)}
+	c[number] = header
+	return header
+}
+
+func logInBlock(header *etypes.Header, index uint) etypes.Log {
+	return etypes.Log{BlockNumber: header.Number.Uint64(), BlockHash: header.Hash(), Index: index}
+}
+
+func TestReorgTracker_NoReorg(t *testing.T) {
+	chain := fakeChain{}
+	tracker := newReorgTracker(DefaultReorgWindow)
+
+	for i := uint64(1); i <= 5; i++ {
+		header := chain.setBlock(i, "")
+		tracker.trackLogs(5, []etypes.Log{logInBlock(header, 0)})
+	}
+
+	reorg, err := tracker.check(context.Background(), chain)
+	require.NoError(t, err)
+	assert.Nil(t, reorg)
+}
+
+func TestReorgTracker_Reorg(t *testing.T) {
+	chain := fakeChain{}
+	tracker := newReorgTracker(DefaultReorgWindow)
+
+	var logs []etypes.Log
+	for i := uint64(1); i <= 5; i++ {
+		header := chain.setBlock(i, "")
+		logs = append(logs, logInBlock(header, 0), logInBlock(header, 1))
+	}
+	tracker.trackLogs(5, logs)
+
+	// Blocks 4 and 5 were replaced, and block 6 was added.
+	for i := uint64(4); i <= 6; i++ {
+		chain.setBlock(i, "fork")
+	}
+
+	reorg, err := tracker.check(context.Background(), chain)
+	require.NoError(t, err)
+	require.NotNil(t, reorg)
+	assert.Equal(t, uint64(3), reorg.ForkBlock)
+
+	require.Len(t, reorg.RemovedLogs, 4)
+	for i, l := range reorg.RemovedLogs {
+		want := logs[len(logs)-1-i]
+		assert.True(t, l.Removed)
+		assert.Equal(t, want.BlockHash, l.BlockHash)
+		assert.Equal(t, want.Index, l.Index)
+	}
+
+	// Dropped blocks are not tracked any more.
+	reorg, err = tracker.check(context.Background(), chain)
+	require.NoError(t, err)
+	assert.Nil(t, reorg)
+}
+
+func TestReorgTracker_ReorgDeeperThanTracked(t *testing.T) {
+	chain := fakeChain{}
+	tracker := newReorgTracker(DefaultReorgWindow)
+
+	tracker.track(11, 10, chain.setBlock(10, "").Hash(), nil)
+	tracker.track(11, 11, chain.setBlock(11, "").Hash(), nil)
+
+	chain.setBlock(10, "fork")
+	chain.setBlock(11, "fork")
+
+	reorg, err := tracker.check(context.Background(), chain)
+	require.NoError(t, err)
+	require.NotNil(t, reorg)
+	assert.Equal(t, uint64(9), reorg.ForkBlock)
+	assert.Empty(t, reorg.RemovedLogs)
+}
+
+func TestReorgTracker_Window(t *testing.T) {
+	chain := fakeChain{}
+	tracker := newReorgTracker(2)
+
+	// Too far below the head.
+	tracker.track(10, 7, chain.setBlock(7, "").Hash(), nil)
+	assert.Empty(t, tracker.blocks)
+
+	for i := uint64(8); i <= 12; i++ {
+		tracker.track(12, i, chain.setBlock(i, "").Hash(), nil)
+	}
+	require.Len(t, tracker.blocks, 3)
+	assert.Equal(t, uint64(10), tracker.blocks[0].number)
+
+	disabled := newReorgTracker(0)
+	disabled.track(12, 12, chain[12].Hash(), nil)
+	assert.Empty(t, disabled.blocks)
+}
+
+func TestRollbackQuery(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage(big.NewInt(1))
+	q := ethereum.FilterQuery{Addresses: []common.Address{{}}}
+
+	require.NoError(t, storage.SaveLatestBlockForQuery(ctx, q, 20))
+	require.NoError(t, storage.SaveLatestLogForQuery(ctx, q, etypes.Log{BlockNumber: 18, TxIndex: 1, Index: 2}))
+
+	require.NoError(t, RollbackQuery(ctx, storage, q, 15))
+
+	latest, err := storage.LatestBlockForQuery(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), latest)
+
+	latestLog, err := storage.LatestLogForQuery(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), latestLog.BlockNumber)
+
+	// Never moves the state forward.
+	require.NoError(t, RollbackQuery(ctx, storage, q, 17))
+
+	latest, err = storage.LatestBlockForQuery(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), latest)
+}
@@ -80,7 +80,15 @@ type QueryHandler interface {
 	SubscriberStorage
 	// Subscriber will call back it for handling when incoming logs are ready.
 	// If log.Address is address(0), just for updating block number
+	// If log.Removed is set, the log was dropped by a reorg, roll query states back
+	// before log.BlockNumber, see RollbackQuery
 	HandleQuery(ctx context.Context, query Query, log etypes.Log) error
 }
 
+// QueryRollbacker is implemented by query handlers rolled back on reorgs, even if no logs were removed.
+type QueryRollbacker interface {
+	// RollbackQuery rolls query states back to blockNum, the fork block of a reorg.
+	RollbackQuery(ctx context.Context, query Query, blockNum uint64) error
+}
+
 type resubscribeFunc func() (ethereum.Subscription, error)
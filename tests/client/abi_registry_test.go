@@ -0,0 +1,93 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/contracts"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_RegisterABI(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	ctx := context.Background()
+	contractAddr, _, _ := helper.DeployTestContract(t, ctx, sim)
+
+	// Another contract defines an error with the same name.
+	other, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"TestRevert","inputs":[{"name":"owner","type":"address"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddABI(other)
+
+	testABI := contracts.GetTestContractABI()
+	client.RegisterABI(contractAddr, testABI)
+
+	data, err := testABI.Pack("testReverted", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := (&message.Request{From: helper.Addr1, To: &contractAddr, Data: data, Gas: 100000, SimulationOn: true}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+
+	var jsonErr *consts.JsonRpcError
+	if !errors.As(resp.Err, &jsonErr) {
+		t.Fatalf("want simulation error decoded, got %v", resp.Err)
+	}
+	decoded, ok := jsonErr.DecodedData.(consts.RevertError)
+	if !ok || decoded.FuncSignature != "TestRevert(uint256 a, uint256 b)" {
+		t.Fatalf("want error decoded with the abi registered, got %v", jsonErr.DecodedData)
+	}
+
+	data, err = testABI.Pack("testFunc1", "arg1", big.NewInt(2), []byte{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call, err := client.DecodeCalldata(ctx, &contractAddr, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Method.Name != "testFunc1" || call.Args["arg1"] != "arg1" {
+		t.Fatalf("unexpected call decoded: %v %v", call.Method.Name, call.Args)
+	}
+
+	req = (&message.Request{From: helper.Addr1, To: &contractAddr, Data: data, Gas: 100000}).SetRandomId()
+	client.ScheduleMsg(req)
+	resp = <-client.Response()
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+
+	receipt, ok := client.WaitTxReceipt(resp.Tx.Hash(), 0, 5*time.Second)
+	if !ok {
+		t.Fatal("no receipt")
+	}
+
+	event, err := client.DecodeLog(ctx, *receipt.Logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event.Name != "FuncEvent1" || event.Args["arg2"].(*big.Int).Int64() != 2 {
+		t.Fatalf("unexpected log decoded: %v %v", event.Event.Name, event.Args)
+	}
+}
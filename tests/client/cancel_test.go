@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestCancelMsg_Inflight(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	ctx := context.Background()
+
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1)}
+	tx := sendTestMsg(t, mm, req)
+
+	resp := mm.CancelMsg(ctx, req.Id())
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	cancelTx := resp.Tx
+	if cancelTx.Nonce() != tx.Nonce() {
+		t.Fatalf("cancellation nonce: want %v, got %v", tx.Nonce(), cancelTx.Nonce())
+	}
+	if *cancelTx.To() != helper.Addr1 || cancelTx.Value().Sign() != 0 {
+		t.Fatalf("want a zero-value self-transfer, got to=%v value=%v", cancelTx.To(), cancelTx.Value())
+	}
+	if cancelTx.GasTipCap().Cmp(tx.GasTipCap()) <= 0 || cancelTx.GasFeeCap().Cmp(tx.GasFeeCap()) <= 0 {
+		t.Fatalf("fees were not bumped: tip %v -> %v, fee cap %v -> %v",
+			tx.GasTipCap(), cancelTx.GasTipCap(), tx.GasFeeCap(), cancelTx.GasFeeCap())
+	}
+
+	msg, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != message.MessageStatusCancelled {
+		t.Fatalf("want msg cancelled, got %v", msg.Status)
+	}
+	if msg.Req.Value.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("the original request was not kept: %+v", msg.Req)
+	}
+
+	sim.CommitAndExpectTx(cancelTx.Hash())
+}
+
+func TestReplaceMsg(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	ctx := context.Background()
+
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1)}
+	tx := sendTestMsg(t, mm, req)
+
+	newReq := (&message.Request{From: helper.Addr1, To: &helper.Addr3, Value: big.NewInt(2)}).SetRandomId()
+	resp := mm.ReplaceMsg(ctx, req.Id(), *newReq)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	if resp.Tx.Nonce() != tx.Nonce() {
+		t.Fatalf("replacement nonce: want %v, got %v", tx.Nonce(), resp.Tx.Nonce())
+	}
+
+	old, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Status != message.MessageStatusNonceReleased {
+		t.Fatalf("want old msg nonce released, got %v", old.Status)
+	}
+
+	replaced, err := mm.GetMsg(newReq.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.Status != message.MessageStatusInflight || replaced.Resp == nil || replaced.Resp.Tx.Hash() != resp.Tx.Hash() {
+		t.Fatalf("unexpected new msg: status=%v resp=%+v", replaced.Status, replaced.Resp)
+	}
+
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+
+	// The nonce of a msg replaced can not be reused again.
+	resp = mm.ReplaceMsg(ctx, req.Id(), *(&message.Request{From: helper.Addr1, To: &helper.Addr3}).SetRandomId())
+	if resp.Err == nil {
+		t.Fatal("want error replacing a msg replaced already")
+	}
+}
+
+func TestClient_CancelMsg_Scheduled(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+	ctx := context.Background()
+
+	nonce, err := client.NonceAt(ctx, helper.Addr1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := (&message.Request{
+		From:      helper.Addr1,
+		To:        &helper.Addr2,
+		StartTime: time.Now().Add(2 * time.Second).UnixNano(),
+	}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	waitMsg(t, client, req.Id())
+	if err := client.CancelMsg(ctx, req.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case resp := <-client.Response():
+		if resp.Id != req.Id() || !errors.Is(resp.Err, message.ErrMsgCancelled) {
+			t.Fatalf("want msg cancelled, got %+v", resp)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+
+	msg, err := client.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != message.MessageStatusCancelled {
+		t.Fatalf("want msg cancelled, got %v", msg.Status)
+	}
+
+	pending, err := client.RawClient().PendingNonceAt(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != nonce {
+		t.Fatalf("no tx should be sent, nonce %v -> %v", nonce, pending)
+	}
+}
+
+func TestClient_CancelMsg_Interval(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+	ctx := context.Background()
+
+	req := (&message.Request{
+		From:     helper.Addr1,
+		To:       &helper.Addr2,
+		Interval: time.Second,
+	}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	select {
+	case resp := <-client.Response():
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+
+	// The first tick inflight is replaced, and the next ones are dropped.
+	if err := client.CancelMsg(ctx, req.Id()); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	timeout := time.After(4 * time.Second)
+	for {
+		select {
+		case resp := <-client.Response():
+			if !errors.Is(resp.Err, message.ErrMsgCancelled) {
+				t.Fatalf("want next ticks cancelled, got %+v", resp)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func waitMsg(t *testing.T, client *ethclient.Client, msgId common.Hash) {
+	for i := 0; i < 50; i++ {
+		if client.HasMsg(msgId) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("msg %v not found", msgId.Hex())
+}
+
+func TestSendMsg_Cancelled(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	ctx := context.Background()
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	if err := mm.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+	// Cancelled after being queued, while about to be sent.
+	if err := mm.UpdateMsgStatus(req.Id(), message.MessageStatusQueued); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.SwapMsgStatus(mm, req.Id(), []message.MessageStatus{message.MessageStatusQueued}, message.MessageStatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := mm.SendMsg(ctx, *req)
+	if !errors.Is(resp.Err, message.ErrMsgCancelled) {
+		t.Fatalf("want msg cancelled, got %v", resp.Err)
+	}
+
+	msg, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != message.MessageStatusCancelled {
+		t.Fatalf("want msg cancelled, got %v", msg.Status)
+	}
+
+	nonce, err := sim.Client().RawClient().NonceAt(ctx, helper.Addr1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx.Nonce() != nonce {
+		t.Fatalf("nonce of the cancelled msg was not reused: want %v, got %v", nonce, tx.Nonce())
+	}
+
+	sim.CommitAndExpectTx(tx.Hash())
+}
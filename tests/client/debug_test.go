@@ -0,0 +1,185 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/contracts"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/simulated"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestDebugTransactionOnChain(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	ctx := context.Background()
+	contractAddr, _, _ := helper.DeployTestContract(t, ctx, sim)
+
+	testABI := contracts.GetTestContractABI()
+	client.RegisterABI(contractAddr, testABI)
+
+	okData, err := testABI.Pack("testFunc1", "arg1", big.NewInt(2), []byte{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revertData, err := testABI.Pack("testReverted", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both txs are mined in the same block, the reverted one after the other.
+	var hashes []common.Hash
+	for _, data := range [][]byte{okData, revertData} {
+		req := (&message.Request{From: helper.Addr1, To: &contractAddr, Data: data, Gas: 100000}).SetRandomId()
+		client.ScheduleMsg(req)
+		resp := <-client.Response()
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		hashes = append(hashes, resp.Tx.Hash())
+	}
+	sim.Commit()
+	okHash, revertHash := hashes[0], hashes[1]
+
+	result, err := client.DebugTransactionOnChain(ctx, revertHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Traced || result.Revert != result.Root || result.Root.GasUsed == 0 {
+		t.Fatalf("unexpected trace: traced %v, gas used %v", result.Traced, result.Root.GasUsed)
+	}
+	assertTestRevert(t, result.Err())
+	if result.Root.Call == nil || result.Root.Call.Method.Name != "testReverted" {
+		t.Fatalf("want input decoded, got %v", result.Root.Call)
+	}
+
+	result, err = client.DebugTransactionOnChain(ctx, okHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Revert != nil || result.Err() != nil {
+		t.Fatalf("want no revert, got %v", result.Err())
+	}
+	if len(result.Root.Events) != 2 || result.Root.Events[0] == nil || result.Root.Events[0].Event.Name != "FuncEvent1" {
+		t.Fatalf("want logs decoded, got %v", result.Root.Events)
+	}
+
+	// Fallback if tracing is not supported.
+	result, err = client.ReplayTransaction(ctx, okHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Traced || result.Err() != nil || len(result.Root.Events) != 2 {
+		t.Fatalf("want first tx of the block replayed, traced %v, err %v", result.Traced, result.Err())
+	}
+
+	// The txs before it can't be replayed without eth_callMany.
+	if _, err = client.ReplayTransaction(ctx, revertHash); !errors.Is(err, ethclient.ErrReplayUnavailable) {
+		t.Fatalf("want replay unavailable, got %v", err)
+	}
+}
+
+func assertTestRevert(t *testing.T, err error) {
+	t.Helper()
+
+	var jsonErr *consts.JsonRpcError
+	if !errors.As(err, &jsonErr) {
+		t.Fatalf("want revert decoded, got %v", err)
+	}
+	decoded, ok := jsonErr.DecodedData.(consts.RevertError)
+	if !ok || decoded.FuncSignature != "TestRevert(uint256 a, uint256 b)" {
+		t.Fatalf("want TestRevert decoded, got %v", jsonErr.DecodedData)
+	}
+}
+
+// deployCaller deploys a contract forwarding its calldata to target, then reverting with no data if the call
+// failed and caught, e.g. with try/catch, or with the revert data of target otherwise.
+func deployCaller(t *testing.T, sim *simulated.Backend, target common.Address, caught bool) common.Address {
+	// calldatacopy(0, 0, calldatasize()); call(gas(), target, 0, 0, calldatasize(), 0, 0)
+	runtime := append([]byte{0x36, 0x60, 0x00, 0x60, 0x00, 0x37, 0x60, 0x00, 0x60, 0x00, 0x36, 0x60, 0x00, 0x60, 0x00, 0x73}, target.Bytes()...)
+	runtime = append(runtime, 0x5a, 0xf1, 0x50)
+	if caught {
+		// revert(0, 0)
+		runtime = append(runtime, 0x60, 0x00, 0x60, 0x00, 0xfd)
+	} else {
+		// returndatacopy(0, 0, returndatasize()); revert(0, returndatasize())
+		runtime = append(runtime, 0x3d, 0x60, 0x00, 0x60, 0x00, 0x3e, 0x3d, 0x60, 0x00, 0xfd)
+	}
+	// codecopy(0, 12, len(runtime)); return(0, len(runtime))
+	code := append([]byte{0x60, byte(len(runtime)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(runtime)), 0x60, 0x00, 0xf3}, runtime...)
+
+	ctx := context.Background()
+	auth, err := sim.Client().MessageToTransactOpts(ctx, message.Request{From: helper.Addr1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, tx, _, err := bind.DeployContract(auth, abi.ABI{}, code, sim.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.CommitAndExpectTx(tx.Hash())
+
+	return addr
+}
+
+func TestDebugTransactionOnChain_InnerRevert(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	ctx := context.Background()
+	contractAddr, _, _ := helper.DeployTestContract(t, ctx, sim)
+
+	testABI := contracts.GetTestContractABI()
+	client.RegisterABI(contractAddr, testABI)
+
+	revertData, err := testABI.Pack("testReverted", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	debugCaller := func(caught bool) *ethclient.TxDebugResult {
+		t.Helper()
+
+		caller := deployCaller(t, sim, contractAddr, caught)
+		req := (&message.Request{From: helper.Addr1, To: &caller, Data: revertData, Gas: 100000}).SetRandomId()
+		client.ScheduleMsg(req)
+		resp := <-client.Response()
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		sim.Commit()
+
+		result, err := client.DebugTransactionOnChain(ctx, resp.Tx.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Traced || len(result.Root.Calls) != 1 || result.Root.Calls[0].Error == "" {
+			t.Fatalf("want the inner call failed, traced %v", result.Traced)
+		}
+		return result
+	}
+
+	// The revert of the inner call is bubbled up.
+	result := debugCaller(false)
+	if result.Revert != result.Root.Calls[0] {
+		t.Fatal("want the inner call reverting")
+	}
+	assertTestRevert(t, result.Err())
+
+	// The inner call failed, but the caller reverted by itself after catching it.
+	result = debugCaller(true)
+	if result.Revert != result.Root {
+		t.Fatal("want the caller reverting")
+	}
+}
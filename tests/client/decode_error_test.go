@@ -2,9 +2,13 @@ package client_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ivanzzeth/ethclient/common/consts"
 	"github.com/ivanzzeth/ethclient/contracts"
+	"github.com/ivanzzeth/ethclient/message"
 	"github.com/ivanzzeth/ethclient/tests/helper"
 )
 
@@ -32,3 +36,43 @@ func Test_DecodeJsonRpcError(t *testing.T) {
 	err = testContract.TestRevertedString(nil, true)
 	t.Log("TestRevertedString err: ", err)
 }
+
+func Test_DecodeJsonRpcError_Class(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	client.AddABI(contracts.GetTestContractABI())
+
+	ctx := context.Background()
+	contractAddr, _, _ := helper.DeployTestContract(t, ctx, sim)
+
+	testContract, err := contracts.NewContracts(contractAddr, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testContract.TestRevertedString(nil, true)
+	if !errors.Is(err, consts.ErrExecutionReverted) {
+		t.Fatalf("want execution reverted from CallContract, got %v", err)
+	}
+
+	data, err := contracts.GetTestContractABI().Pack("testRevertedString", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.EstimateGas(ctx, ethereum.CallMsg{From: helper.Addr1, To: &contractAddr, Data: data})
+	if !errors.Is(err, consts.ErrExecutionReverted) {
+		t.Fatalf("want execution reverted from EstimateGas, got %v", err)
+	}
+
+	mm := newTestMsgManager(t, sim)
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2}
+	tx := sendTestMsg(t, mm, req)
+	sim.CommitAndExpectTx(tx.Hash())
+
+	resp := mm.ReplaceMsgWithHigherGasPrice(ctx, req.Id())
+	if !errors.Is(resp.Err, consts.ErrNonceTooLow) {
+		t.Fatalf("want nonce too low from SendTransaction, got %v", resp.Err)
+	}
+}
@@ -0,0 +1,221 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient"
+	"github.com/ivanzzeth/ethclient/transport"
+)
+
+type fakeEthService struct {
+	chainId uint64
+	block   uint64
+}
+
+func (s *fakeEthService) ChainId() hexutil.Uint64 {
+	return hexutil.Uint64(s.chainId)
+}
+
+func (s *fakeEthService) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(s.block)
+}
+
+// GetBalance returns the last byte of account as its balance, failing for the zero address.
+func (s *fakeEthService) GetBalance(account common.Address, block rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	if account == (common.Address{}) {
+		return nil, errors.New("invalid account")
+	}
+	return (*hexutil.Big)(big.NewInt(int64(account[19]))), nil
+}
+
+func newFakeRpcServer(t *testing.T, block uint64) *httptest.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &fakeEthService{chainId: 1337, block: block}); err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(server)
+	t.Cleanup(func() {
+		s.Close()
+		server.Stop()
+	})
+	return s
+}
+
+func TestDialMulti(t *testing.T) {
+	dead := httptest.NewServer(nil)
+	dead.Close()
+	alive := newFakeRpcServer(t, 100)
+
+	client, err := ethclient.DialMulti([]string{dead.URL, alive.URL}, ethclient.WithFailoverConfig(transport.FailoverConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	block, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block != 100 {
+		t.Fatalf("unexpected block number: %v", block)
+	}
+
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainId.Uint64() != 1337 {
+		t.Fatalf("unexpected chain id: %v", chainId)
+	}
+}
+
+func TestDialMulti_InvalidUrls(t *testing.T) {
+	if _, err := ethclient.DialMulti(nil); err == nil {
+		t.Fatal("expected error without urls")
+	}
+
+	if _, err := ethclient.DialMulti([]string{"ws://localhost:8546"}); err == nil {
+		t.Fatal("expected error for websocket url")
+	}
+}
+
+func TestDialMulti_Quorum(t *testing.T) {
+	s1 := newFakeRpcServer(t, 100)
+	s2 := newFakeRpcServer(t, 100)
+	lagging := newFakeRpcServer(t, 90)
+
+	client, err := ethclient.DialMulti([]string{lagging.URL, s1.URL, s2.URL}, ethclient.WithQuorum(transport.QuorumConfig{Threshold: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	block, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block != 100 {
+		t.Fatalf("unexpected block number: %v", block)
+	}
+
+	client2, err := ethclient.DialMulti([]string{lagging.URL, s1.URL}, ethclient.WithQuorum(transport.QuorumConfig{Threshold: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client2.Close()
+
+	_, err = client2.BlockNumber(ctx)
+	var quorumErr *transport.QuorumError
+	if !errors.As(err, &quorumErr) {
+		t.Fatalf("expected quorum error, got %v", err)
+	}
+}
+
+func TestDialMulti_Batching(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &fakeEthService{chainId: 1337, block: 100}); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// the max number of eth_getBalance in one http request
+	var mu sync.Mutex
+	maxBatch := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		if n := strings.Count(string(body), "eth_getBalance"); n > maxBatch {
+			maxBatch = n
+		}
+		mu.Unlock()
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		server.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	client, err := ethclient.DialMulti([]string{s.URL}, ethclient.WithBatching(transport.BatchConfig{
+		Window:       50 * time.Millisecond,
+		MaxBatchSize: 100,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			balance, err := client.BalanceAt(ctx, common.BigToAddress(big.NewInt(int64(i))), nil)
+			if i == 0 {
+				if err == nil || !strings.Contains(err.Error(), "invalid account") {
+					t.Errorf("want error of the zero address, got %v", err)
+				}
+				return
+			}
+			if err != nil || balance.Int64() != int64(i) {
+				t.Errorf("unexpected balance of %d: %v %v", i, balance, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxBatch < 2 {
+		t.Fatalf("want concurrent requests batched, got %v at most in a request", maxBatch)
+	}
+}
+
+func TestDialMulti_RateLimit(t *testing.T) {
+	s := newFakeRpcServer(t, 100)
+	defer s.Close()
+
+	client, err := ethclient.DialMulti([]string{s.URL}, ethclient.WithRateLimit(transport.RateLimitConfig{
+		Default: transport.RateLimit{RequestsPerSecond: 5},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := client.BlockNumber(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Fatalf("want requests limited to 5/s, took %v", elapsed)
+	}
+}
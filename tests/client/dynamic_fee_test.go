@@ -0,0 +1,225 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/account"
+	"github.com/ivanzzeth/ethclient/gas"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/simulated"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func newTestMsgManager(t *testing.T, sim *simulated.Backend) *message.SimpleManager {
+	client := sim.Client()
+	ctx := context.Background()
+
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := account.NewSimpleRegistry(chainId)
+	if err := registry.RegisterPrivateKey(ctx, helper.PrivateKey1); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := message.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return message.NewSimpleManager(client.Client, client.GetNonceManager(), registry, store)
+}
+
+func sendTestMsg(t *testing.T, mm *message.SimpleManager, req *message.Request) *types.Transaction {
+	message.AssignMessageId(req)
+	if err := mm.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := mm.SendMsg(context.Background(), *req)
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	// The response is stored by the client broadcasting the msg.
+	if err := mm.UpdateResponse(req.Id(), resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Tx
+}
+
+func TestSendMsg_DynamicFee(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+
+	accessList := types.AccessList{{Address: helper.Addr3, StorageKeys: []common.Hash{{1}}}}
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1), AccessList: accessList})
+
+	if tx.Type() != types.DynamicFeeTxType {
+		t.Fatalf("expected dynamic fee tx on london chain, got type %v", tx.Type())
+	}
+	if tx.GasFeeCap().Cmp(tx.GasTipCap()) < 0 {
+		t.Fatalf("fee cap %v below tip cap %v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+	if len(tx.AccessList()) != 1 || tx.AccessList()[0].Address != helper.Addr3 {
+		t.Fatalf("access list was not included: %v", tx.AccessList())
+	}
+
+	feeCap, tipCap := big.NewInt(100e9), big.NewInt(2e9)
+	tx = sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, GasFeeCap: feeCap, GasTipCap: tipCap})
+	if tx.GasFeeCap().Cmp(feeCap) != 0 || tx.GasTipCap().Cmp(tipCap) != 0 {
+		t.Fatalf("unexpected fees: feeCap=%v tipCap=%v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+
+	tx = sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, GasPrice: big.NewInt(10e9)})
+	if tx.Type() != types.LegacyTxType {
+		t.Fatalf("expected legacy tx when gas price is set, got type %v", tx.Type())
+	}
+
+	tx = sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, GasPrice: big.NewInt(10e9), AccessList: accessList})
+	if tx.Type() != types.AccessListTxType {
+		t.Fatalf("expected access list tx when gas price and access list are set, got type %v", tx.Type())
+	}
+
+	sim.Commit()
+}
+
+func TestReplaceMsgWithHigherGasPrice_DynamicFee(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, GasFeeCap: big.NewInt(100e9), GasTipCap: big.NewInt(2e9)}
+	oldTx := sendTestMsg(t, mm, req)
+
+	resp := mm.ReplaceMsgWithHigherGasPrice(context.Background(), req.Id())
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	newTx := resp.Tx
+	if newTx.Type() != types.DynamicFeeTxType || newTx.Nonce() != oldTx.Nonce() {
+		t.Fatalf("unexpected replacement: type=%v nonce=%v", newTx.Type(), newTx.Nonce())
+	}
+	if newTx.GasTipCap().Cmp(big.NewInt(2.4e9)) < 0 || newTx.GasFeeCap().Cmp(big.NewInt(120e9)) < 0 {
+		t.Fatalf("fees were not bumped: feeCap=%v tipCap=%v", newTx.GasFeeCap(), newTx.GasTipCap())
+	}
+
+	msg, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Resp.Tx.Hash() != newTx.Hash() {
+		t.Fatal("replacement was not persisted")
+	}
+
+	sim.CommitAndExpectTx(newTx.Hash())
+	if _, ok := mm.WaitTxReceipt(newTx.Hash(), 0, 5*time.Second); !ok {
+		t.Fatal("replacement not included")
+	}
+}
+
+func TestReplaceMsgWithHigherGasPrice_Legacy(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, GasPrice: big.NewInt(100e9)}
+	oldTx := sendTestMsg(t, mm, req)
+
+	resp := mm.ReplaceMsgWithHigherGasPrice(context.Background(), req.Id())
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	if resp.Tx.Type() != types.LegacyTxType || resp.Tx.Nonce() != oldTx.Nonce() || resp.Tx.GasPrice().Cmp(big.NewInt(120e9)) != 0 {
+		t.Fatalf("unexpected replacement: type=%v nonce=%v gasPrice=%v", resp.Tx.Type(), resp.Tx.Nonce(), resp.Tx.GasPrice())
+	}
+
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+}
+
+func TestSendMsg_GasOracle(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+
+	ctx := context.Background()
+	estimated, err := sim.Client().GetNonceManager().EstimateGas(ctx, ethereum.CallMsg{From: helper.Addr1, To: &helper.Addr2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mm.SetGasOracle(gas.NewTieredOracle(
+		gas.NewCappedOracle(gas.NewFixedOracle(gas.Fees{GasPrice: big.NewInt(50e9), GasTipCap: big.NewInt(5e9), GasFeeCap: big.NewInt(50e9)}),
+			gas.Fees{GasFeeCap: big.NewInt(30e9)}),
+		map[gas.Urgency]gas.Oracle{
+			gas.UrgencyHigh: gas.NewFixedOracle(gas.Fees{GasPrice: big.NewInt(80e9), GasTipCap: big.NewInt(10e9), GasFeeCap: big.NewInt(80e9)}),
+		},
+	))
+
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx.GasFeeCap().Cmp(big.NewInt(30e9)) != 0 || tx.GasTipCap().Cmp(big.NewInt(5e9)) != 0 {
+		t.Fatalf("unexpected fees: feeCap=%v tipCap=%v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+	if tx.Gas() != estimated {
+		t.Fatalf("gas limit should be estimated once by the nonce manager, want %v got %v", estimated, tx.Gas())
+	}
+
+	// Ceilings also apply to the tiers and the fees set on requests.
+	tx = sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, Urgency: gas.UrgencyHigh})
+	if tx.GasFeeCap().Cmp(big.NewInt(30e9)) != 0 || tx.GasTipCap().Cmp(big.NewInt(10e9)) != 0 {
+		t.Fatalf("unexpected fees: feeCap=%v tipCap=%v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+
+	tx = sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2, GasFeeCap: big.NewInt(100e9), GasTipCap: big.NewInt(1e9)})
+	if tx.GasFeeCap().Cmp(big.NewInt(30e9)) != 0 || tx.GasTipCap().Cmp(big.NewInt(1e9)) != 0 {
+		t.Fatalf("unexpected fees: feeCap=%v tipCap=%v", tx.GasFeeCap(), tx.GasTipCap())
+	}
+
+	sim.Commit()
+}
+
+func TestReplaceMsgWithHigherGasPrice_Capped(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+
+	// Without a gas price suggested, e.g. a fixed oracle configured with EIP-1559 fees only.
+	mm.SetGasOracle(gas.NewCappedOracle(gas.NewFixedOracle(gas.Fees{GasTipCap: big.NewInt(1e9), GasFeeCap: big.NewInt(50e9)}),
+		gas.Fees{GasFeeCap: big.NewInt(105e9)}))
+
+	legacy := &message.Request{From: helper.Addr1, To: &helper.Addr2, GasPrice: big.NewInt(10e9)}
+	sendTestMsg(t, mm, legacy)
+	resp := mm.ReplaceMsgWithHigherGasPrice(context.Background(), legacy.Id())
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Tx.GasPrice().Cmp(big.NewInt(12e9)) != 0 {
+		t.Fatalf("unexpected replacement gasPrice %v", resp.Tx.GasPrice())
+	}
+
+	// Bumping the fee cap by 10% goes over the ceiling.
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, GasFeeCap: big.NewInt(100e9), GasTipCap: big.NewInt(2e9)}
+	oldTx := sendTestMsg(t, mm, req)
+
+	resp = mm.ReplaceMsgWithHigherGasPrice(context.Background(), req.Id())
+	if !errors.Is(resp.Err, message.ErrReplacementCapped) {
+		t.Fatalf("want ErrReplacementCapped, got %v", resp.Err)
+	}
+
+	msg, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Resp.Tx.Hash() != oldTx.Hash() {
+		t.Fatal("tx should not be replaced")
+	}
+
+	sim.Commit()
+}
@@ -0,0 +1,121 @@
+package client_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_SubscribeMsg(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	client.SetFinalityConfig(message.FinalityConfig{
+		Confirmations: 1,
+		PollInterval:  100 * time.Millisecond,
+	})
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	events := make(chan message.Event, 20)
+	sub := client.SubscribeMsg(req.Id(), events)
+
+	all := make(chan message.Event, 100)
+	allSub := client.SubscribeMsgEvents(message.EventFilter{From: []common.Address{helper.Addr1}}, all)
+	defer allSub.Unsubscribe()
+
+	client.ScheduleMsg(req)
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+	sim.Commit()
+
+	select {
+	case <-sub.Err():
+	case <-time.After(10 * time.Second):
+		t.Fatal("want subscription ended once msg finalized")
+	}
+
+	want := []message.EventType{
+		message.EventSubmitted,
+		message.EventScheduled,
+		message.EventQueued,
+		message.EventNonceAssigned,
+		message.EventInflight,
+		message.EventOnChain,
+		message.EventFinalized,
+	}
+	var got []message.Event
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want events %v, got %v", want, got)
+	}
+	for i, e := range got {
+		if e.Type != want[i] || e.MsgId != req.Id() || e.From != helper.Addr1 {
+			t.Fatalf("want event %v of msg, got %+v", want[i], e)
+		}
+	}
+	if got[4].Tx == nil || got[4].Tx.Hash() != resp.Tx.Hash() {
+		t.Fatal("want tx of inflight msg")
+	}
+	if got[6].Receipt == nil || got[6].Receipt.TxHash != resp.Tx.Hash() {
+		t.Fatal("want receipt of finalized msg")
+	}
+
+	for i := range want {
+		select {
+		case e := <-all:
+			if e.Type != want[i] {
+				t.Fatalf("want event %v of sender, got %v", want[i], e.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("want event %v of sender", want[i])
+		}
+	}
+}
+
+func TestClient_SubscribeMsg_Failed(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	policy := message.DefaultRetryPolicy()
+	policy.MaxAttempts = 1
+	client.SetRetryPolicy(policy)
+
+	// Rejected by the node for insufficient funds.
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2, Gas: params.TxGas, Value: new(big.Int).Lsh(big.NewInt(1), 200)}).SetRandomId()
+	events := make(chan message.Event, 20)
+	sub := client.SubscribeMsg(req.Id(), events)
+
+	client.ScheduleMsg(req)
+
+	select {
+	case <-sub.Err():
+	case <-time.After(10 * time.Second):
+		t.Fatal("want subscription ended once msg failed")
+	}
+
+	var last message.Event
+	for len(events) > 0 {
+		last = <-events
+	}
+	if last.Type != message.EventFailed || last.Err == nil {
+		t.Fatalf("want msg failed, got %+v", last)
+	}
+}
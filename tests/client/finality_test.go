@@ -0,0 +1,68 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_Receipt_Finality(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	client.SetFinalityConfig(message.FinalityConfig{
+		Confirmations: 2,
+		PollInterval:  100 * time.Millisecond,
+	})
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+
+	waitReceipt := func(status message.MessageStatus) message.Receipt {
+		select {
+		case receipt := <-client.Receipt():
+			if receipt.Id != req.Id() || receipt.Status != status {
+				t.Fatalf("want receipt of %v with status %v, got %+v", req.Id(), status, receipt)
+			}
+			return receipt
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no receipt with status %v", status)
+		}
+		return message.Receipt{}
+	}
+
+	onChain := waitReceipt(message.MessageStatusOnChain)
+	if onChain.TxReceipt.TxHash != resp.Tx.Hash() {
+		t.Fatalf("want receipt of tx %v, got %v", resp.Tx.Hash(), onChain.TxReceipt.TxHash)
+	}
+
+	sim.Commit()
+	sim.Commit()
+
+	finalized := waitReceipt(message.MessageStatusFinalized)
+	if finalized.TxReceipt.BlockHash != onChain.TxReceipt.BlockHash {
+		t.Fatalf("want finalized in block %v, got %v", onChain.TxReceipt.BlockHash, finalized.TxReceipt.BlockHash)
+	}
+
+	msg, err := client.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != message.MessageStatusFinalized {
+		t.Fatalf("want msg finalized, got %v", msg.Status)
+	}
+}
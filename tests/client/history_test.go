@@ -0,0 +1,68 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_GetMsgHistory(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+
+	select {
+	case <-client.Receipt():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no receipt")
+	}
+
+	history, err := client.GetMsgHistory(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []message.MessageStatus{
+		message.MessageStatusSubmitted,
+		message.MessageStatusScheduled,
+		message.MessageStatusQueued,
+		message.MessageStatusNonceAssigned,
+		message.MessageStatusInflight,
+		message.MessageStatusOnChain,
+	}
+	var got []message.MessageStatus
+	for _, record := range history {
+		if len(got) == 0 || got[len(got)-1] != record.To {
+			got = append(got, record.To)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want statuses %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("want statuses %v, got %v", want, got)
+		}
+	}
+
+	last := history[len(history)-1]
+	if last.TxHash == nil || *last.TxHash != resp.Tx.Hash() || *last.Nonce != resp.Tx.Nonce() {
+		t.Fatalf("want tx of msg recorded, got %+v", last)
+	}
+}
@@ -0,0 +1,101 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/metrics"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClient_Metrics(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	reg := prometheus.NewRegistry()
+	m, err := metrics.NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMetrics(m)
+
+	client.SetFinalityConfig(message.FinalityConfig{
+		Confirmations: 1,
+		PollInterval:  100 * time.Millisecond,
+	})
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+	sim.Commit()
+
+	deadline := time.After(10 * time.Second)
+	for finalized := false; !finalized; {
+		select {
+		case receipt := <-client.Receipt():
+			finalized = receipt.Status == message.MessageStatusFinalized
+		case <-deadline:
+			t.Fatal("msg not finalized")
+		}
+	}
+
+	for _, status := range []message.MessageStatus{
+		message.MessageStatusSubmitted,
+		message.MessageStatusScheduled,
+		message.MessageStatusQueued,
+		message.MessageStatusNonceAssigned,
+		message.MessageStatusInflight,
+		message.MessageStatusOnChain,
+		message.MessageStatusFinalized,
+	} {
+		if n := metricValue(t, reg, "ethclient_message_transitions_total", status.String()); n != 1 {
+			t.Fatalf("want 1 msg %v, got %v", status, n)
+		}
+	}
+
+	if n := metricValue(t, reg, "ethclient_message_gas_used_total", helper.Addr1.Hex()); n != 21000 {
+		t.Fatalf("want gas used by the transfer counted, got %v", n)
+	}
+
+	if n, err := testutil.GatherAndCount(reg, "ethclient_message_stage_duration_seconds"); err != nil || n != 6 {
+		t.Fatalf("want durations of the 6 stages before finalized, got %v %v", n, err)
+	}
+	if n, err := testutil.GatherAndCount(reg, "ethclient_messages", "ethclient_sequencer_pending_msgs"); err != nil || n != 7 {
+		t.Fatalf("want msg counts by status and sequencer depth, got %v %v", n, err)
+	}
+}
+
+// metricValue returns the value of the counter name with the label value, 0 if missing.
+func metricValue(t *testing.T, reg *prometheus.Registry, name, label string) float64 {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetValue() == label {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
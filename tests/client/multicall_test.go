@@ -0,0 +1,53 @@
+package client_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ivanzzeth/ethclient/contracts"
+	"github.com/ivanzzeth/ethclient/multicall"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_CallContract_Coalesced(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	ctx := context.Background()
+	contractAddr, _, _ := helper.DeployTestContract(t, ctx, sim)
+
+	// Multicall3 is not deployed on the simulated chain, so calls coalesced are done on their own.
+	config := multicall.DefaultConfig()
+	config.Window = 20 * time.Millisecond
+	client.SetMulticallConfig(config)
+
+	if _, err := client.Multicall(ctx, []multicall.Call{{Target: contractAddr}}, nil); err == nil {
+		t.Fatal("want multicall failed without Multicall3")
+	}
+
+	testContract, err := contracts.NewContracts(contractAddr, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter, err := testContract.Counter(&bind.CallOpts{Context: ctx})
+			if err != nil || counter.Sign() != 0 {
+				t.Errorf("unexpected counter: %v %v", counter, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := testContract.TestReverted(nil, true); err == nil {
+		t.Fatal("want revert")
+	}
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestSendMsg_ReleaseNonce(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	ctx := context.Background()
+
+	// Rejected by the node for insufficient funds.
+	req := &message.Request{From: helper.Addr1, To: &helper.Addr2, Gas: params.TxGas, Value: new(big.Int).Lsh(big.NewInt(1), 200)}
+	message.AssignMessageId(req)
+	if err := mm.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := mm.SendMsg(ctx, *req)
+	if resp.Err == nil {
+		t.Fatal("want error sending msg")
+	}
+
+	msg, err := mm.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != message.MessageStatusFailed {
+		t.Fatalf("want msg failed, got %v", msg.Status)
+	}
+
+	nonce, err := sim.Client().RawClient().NonceAt(ctx, helper.Addr1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx.Nonce() != nonce {
+		t.Fatalf("nonce released was not reused: want %v, got %v", nonce, tx.Nonce())
+	}
+
+	sim.CommitAndExpectTx(tx.Hash())
+}
+
+func TestSendMsg_ReuseReleasedNonce(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	nm := sim.Client().GetNonceManager()
+	ctx := context.Background()
+
+	reserved, err := nm.ReserveNonce(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx1 := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx1.Nonce() != reserved+1 {
+		t.Fatalf("want nonce %v, got %v", reserved+1, tx1.Nonce())
+	}
+
+	if err := nm.ReleaseNonce(helper.Addr1, reserved); err != nil {
+		t.Fatal(err)
+	}
+
+	tx2 := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx2.Nonce() != reserved {
+		t.Fatalf("nonce released was not reused: want %v, got %v", reserved, tx2.Nonce())
+	}
+
+	tx3 := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx3.Nonce() != reserved+2 {
+		t.Fatalf("want nonce %v, got %v", reserved+2, tx3.Nonce())
+	}
+
+	sim.Commit()
+}
+
+func TestFillNonceGaps(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	nm := sim.Client().GetNonceManager()
+	ctx := context.Background()
+
+	reserved, err := nm.ReserveNonce(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if err := nm.ReleaseNonce(helper.Addr1, reserved); err != nil {
+		t.Fatal(err)
+	}
+
+	// Found for the first time, the gap may still be filled by the next msg.
+	txs, err := mm.FillNonceGaps(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 0 {
+		t.Fatalf("want no gap filled yet, got %v", len(txs))
+	}
+
+	txs, err = mm.FillNonceGaps(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].Nonce() != reserved {
+		t.Fatalf("want gap %v filled, got %v txs", reserved, len(txs))
+	}
+	if *txs[0].To() != helper.Addr1 || txs[0].Value().Sign() != 0 {
+		t.Fatalf("want a zero-value self-transfer, got to=%v value=%v", txs[0].To(), txs[0].Value())
+	}
+
+	sim.Commit()
+
+	nonce, err := sim.Client().RawClient().NonceAt(ctx, helper.Addr1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != tx.Nonce()+1 {
+		t.Fatalf("txs blocked by the gap were not mined: nonce %v", nonce)
+	}
+
+	txs, err = mm.FillNonceGaps(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 0 {
+		t.Fatalf("want no gap left, got %v", len(txs))
+	}
+}
+
+func TestFillNonceGaps_HeldByMsg(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	mm := newTestMsgManager(t, sim)
+	nm := sim.Client().GetNonceManager()
+	ctx := context.Background()
+
+	chainId, err := sim.Client().ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tx of a msg evicted from the mempool of the node, to be sent again.
+	held, err := nm.ReserveNonce(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.CommitNonce(helper.Addr1, held); err != nil {
+		t.Fatal(err)
+	}
+	evicted, err := types.SignNewTx(helper.PrivateKey1, types.LatestSignerForChainID(chainId), &types.DynamicFeeTx{
+		ChainID: chainId, Nonce: held, To: &helper.Addr1, Gas: params.TxGas, GasFeeCap: big.NewInt(1e10), GasTipCap: big.NewInt(1e9),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr1}).SetRandomId()
+	if err := mm.AddMsg(*req); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.UpdateResponse(req.Id(), message.Response{Id: req.Id(), Tx: evicted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.UpdateMsgStatus(req.Id(), message.MessageStatusInflight); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := sendTestMsg(t, mm, &message.Request{From: helper.Addr1, To: &helper.Addr2})
+	if tx.Nonce() != held+1 {
+		t.Fatalf("unexpected nonce %v", tx.Nonce())
+	}
+
+	for i := 0; i < 2; i++ {
+		txs, err := mm.FillNonceGaps(ctx, helper.Addr1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 0 {
+			t.Fatalf("nonce %v held by a msg should not be filled", txs[0].Nonce())
+		}
+	}
+
+	next, err := nm.ReserveNonce(ctx, helper.Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != held+2 {
+		t.Fatalf("nonce held by a msg should not be reused, got %v", next)
+	}
+}
@@ -0,0 +1,116 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ivanzzeth/ethclient"
+	"github.com/ivanzzeth/ethclient/account"
+	"github.com/ivanzzeth/ethclient/common/consts"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/subscriber"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestNewEthClient_RecoverMsgs(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	ctx := context.Background()
+
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := account.NewSimpleRegistry(chainId)
+	if err := registry.RegisterPrivateKey(ctx, helper.PrivateKey1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Msgs left by a previous process.
+	store, err := message.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := client.GetNonceManager()
+	mm := message.NewSimpleManager(client.Client, nm, registry, store)
+
+	addMsg := func(status message.MessageStatus) *message.Request {
+		req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+		if err := store.AddMsg(*req); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateMsgStatus(req.Id(), status); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	mined := &message.Request{From: helper.Addr1, To: &helper.Addr2}
+	minedTx := sendTestMsg(t, mm, mined)
+	sim.CommitAndExpectTx(minedTx.Hash())
+
+	pending := &message.Request{From: helper.Addr1, To: &helper.Addr2}
+	sendTestMsg(t, mm, pending)
+
+	submitted := addMsg(message.MessageStatusSubmitted)
+	queued := addMsg(message.MessageStatusQueued)
+	unknownTx := addMsg(message.MessageStatusNonceAssigned)
+
+	failed := addMsg(message.MessageStatusQueued)
+	if err := store.UpdateResponse(failed.Id(), message.Response{Id: failed.Id(), Err: errors.New("failed")}); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := subscriber.NewChainSubscriber(client.RpcClient(), subscriber.NewMemoryStorage(chainId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequencer := message.NewMemorySequencer(client.Client, store, consts.DefaultMsgBuffer)
+
+	recovered, err := ethclient.NewEthClient(client.RpcClient(), registry, store, nm, mm, sub, sequencer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := recovered.RecoveryReport()
+	if len(report.Rescheduled) != 1 || report.Rescheduled[0] != submitted.Id() {
+		t.Fatalf("unexpected rescheduled msgs: %v", report.Rescheduled)
+	}
+	if len(report.Requeued) != 1 || report.Requeued[0] != queued.Id() {
+		t.Fatalf("unexpected requeued msgs: %v", report.Requeued)
+	}
+	if len(report.Confirmed) != 1 || report.Confirmed[0] != mined.Id() {
+		t.Fatalf("unexpected confirmed msgs: %v", report.Confirmed)
+	}
+	if len(report.Protected) != 1 || report.Protected[0] != pending.Id() {
+		t.Fatalf("unexpected protected msgs: %v", report.Protected)
+	}
+	if len(report.Unreconciled) != 1 || report.Unreconciled[0].MsgId != unknownTx.Id() {
+		t.Fatalf("unexpected unreconciled msgs: %v", report.Unreconciled)
+	}
+
+	msg, err := store.GetMsg(mined.Id())
+	if err != nil || msg.Receipt == nil || msg.Receipt.TxReceipt.TxHash != minedTx.Hash() {
+		t.Fatalf("receipt of mined msg was not recovered: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case resp := <-recovered.Response():
+			if resp.Err != nil || (resp.Id != submitted.Id() && resp.Id != queued.Id()) {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("recovered msgs were not sent")
+		}
+	}
+
+	sim.Commit()
+
+	if _, ok := recovered.WaitMsgReceipt(pending.Id(), 0, 10*time.Second); !ok {
+		t.Fatal("protected msg did not get its receipt")
+	}
+}
@@ -0,0 +1,65 @@
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+)
+
+func TestClient_RetryMsg_NonceTooLow(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+	ctx := context.Background()
+
+	policy := message.DefaultRetryPolicy()
+	policy.Backoff = 100 * time.Millisecond
+	client.SetRetryPolicy(policy)
+
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsg(req)
+	resp := <-client.Response()
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.Commit()
+
+	// The nonce manager is out of sync with the chain, e.g. reading a node lagging behind.
+	nm := client.GetNonceManager()
+	nm.SetNonceAt(func(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+		return 0, nil
+	})
+	if err := nm.ResetNonce(ctx, helper.Addr1); err != nil {
+		t.Fatal(err)
+	}
+
+	req = (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsg(req)
+
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Tx.Nonce() != 1 {
+		t.Fatalf("want nonce 1 after resync, got %v", resp.Tx.Nonce())
+	}
+
+	msg, err := client.GetMsg(req.Id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Retries) != 1 || msg.Retries[0].Action != message.RetryActionResyncNonce {
+		t.Fatalf("unexpected retries recorded: %+v", msg.Retries)
+	}
+
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+}
@@ -106,7 +106,8 @@ func testScheduleMsg(t *testing.T, sim *simulated.Backend, concurrent bool) {
 			t.Fatal("get msg failed: ", err)
 		}
 
-		if msg.Status != message.MessageStatusInflight {
+		// Txs broadcasted together are mined by the same commit.
+		if msg.Status != message.MessageStatusInflight && msg.Status != message.MessageStatusOnChain {
 			t.Fatal("unexpected msg status: ", msg.Status)
 		}
 
@@ -127,7 +128,9 @@ func testScheduleMsg(t *testing.T, sim *simulated.Backend, concurrent bool) {
 			t.Fatal("get msg failed: ", err)
 		}
 
-		// if msg.Status != message.MessageStatusOnChain
+		if msg.Status != message.MessageStatusOnChain {
+			t.Fatal("unexpected msg status: ", msg.Status)
+		}
 		if msg.Receipt == nil {
 			t.Fatalf("get msg %v receipt failed", msg.Id())
 		}
@@ -8,12 +8,19 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	"github.com/ivanzzeth/ethclient"
+	"github.com/ivanzzeth/ethclient/account"
 	"github.com/ivanzzeth/ethclient/contracts"
 	"github.com/ivanzzeth/ethclient/message"
 	"github.com/ivanzzeth/ethclient/simulated"
+	"github.com/ivanzzeth/ethclient/subscriber"
 	"github.com/ivanzzeth/ethclient/tests/helper"
+	goredislib "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,3 +106,106 @@ func test_Sequencer_Concurrent(t *testing.T, sim *simulated.Backend) {
 
 	assert.True(t, sort.IsSorted(sort.IntSlice(nonceRes)))
 }
+
+func Test_RedisSequencer(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	ctx := context.Background()
+
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := account.NewSimpleRegistry(chainId)
+	if err := registry.RegisterPrivateKey(ctx, helper.PrivateKey1); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := message.NewMemoryStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := client.GetNonceManager()
+	mm := message.NewSimpleManager(client.Client, nm, registry, store)
+	sub, err := subscriber.NewChainSubscriber(client.RpcClient(), subscriber.NewMemoryStorage(chainId))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	pool := goredis.NewPool(goredislib.NewClient(&goredislib.Options{Addr: mr.Addr()}))
+	sequencer := message.NewRedisSequencer(chainId, pool, store, time.Minute)
+
+	cli, err := ethclient.NewEthClient(client.RpcClient(), registry, store, nm, mm, sub, sequencer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	// scheduled in reverse order, each one after the previous one
+	var ids []common.Hash
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (&message.Request{}).SetRandomId().Id())
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		req := &message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1)}
+		req.SetId(ids[i])
+		if i > 0 {
+			req.AfterMsg = &ids[i-1]
+		}
+		cli.ScheduleMsg(req)
+	}
+
+	for i := range ids {
+		select {
+		case resp := <-cli.Response():
+			if resp.Err != nil || resp.Id != ids[i] {
+				t.Fatalf("want msg %v sent, got %+v", ids[i], resp)
+			}
+			nonce, err := store.GetNonce(resp.Id)
+			if err != nil || nonce != uint64(i) {
+				t.Fatalf("unexpected nonce %v of msg %v: %v", nonce, i, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("msgs were not sent")
+		}
+	}
+
+	// a msg redelivered after it was sent is responded without being sent again, before the msgs after it
+	sent := (&message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1)}).SetRandomId()
+	tx := types.NewTx(&types.LegacyTx{Nonce: 100, GasPrice: big.NewInt(1)})
+	if err := store.AddMsg(*sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateResponse(sent.Id(), message.Response{Id: sent.Id(), Tx: tx}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sequencer.PushMsg(*sent); err != nil {
+		t.Fatal(err)
+	}
+	sentId := sent.Id()
+	after := (&message.Request{From: helper.Addr1, To: &helper.Addr2, Value: big.NewInt(1), AfterMsg: &sentId}).SetRandomId()
+	cli.ScheduleMsg(after)
+
+	for i, id := range []common.Hash{sentId, after.Id()} {
+		select {
+		case resp := <-cli.Response():
+			if resp.Err != nil || resp.Id != id {
+				t.Fatalf("want msg %v responded, got %+v", id, resp)
+			}
+			if i == 0 && resp.Tx.Hash() != tx.Hash() {
+				t.Fatalf("want the tx stored, got %v", resp.Tx.Hash())
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("msgs were not responded")
+		}
+	}
+	if nonce, err := store.GetNonce(after.Id()); err != nil || nonce != uint64(len(ids)) {
+		t.Fatalf("unexpected nonce %v of msg after: %v", nonce, err)
+	}
+
+	if pending, err := sequencer.PendingMsgCount(); err != nil || pending != 0 {
+		t.Fatalf("unexpected msgs pending %v: %v", pending, err)
+	}
+}
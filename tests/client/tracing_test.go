@@ -0,0 +1,99 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClient_Tracing(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	client := sim.Client()
+	defer client.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client.SetTracerProvider(tp)
+
+	client.SetFinalityConfig(message.FinalityConfig{
+		Confirmations: 1,
+		PollInterval:  100 * time.Millisecond,
+	})
+
+	ctx, caller := tp.Tracer("test").Start(context.Background(), "caller")
+	req := (&message.Request{From: helper.Addr1, To: &helper.Addr2}).SetRandomId()
+	client.ScheduleMsgWithContext(ctx, req)
+	caller.End()
+
+	var resp message.Response
+	select {
+	case resp = <-client.Response():
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response")
+	}
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	sim.CommitAndExpectTx(resp.Tx.Hash())
+	sim.Commit()
+
+	deadline := time.After(10 * time.Second)
+	for finalized := false; !finalized; {
+		select {
+		case receipt := <-client.Receipt():
+			finalized = receipt.Status == message.MessageStatusFinalized
+		case <-deadline:
+			t.Fatal("msg not finalized")
+		}
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	msgSpan, ok := spans["ethclient.message"]
+	if !ok {
+		t.Fatalf("want msg span ended once finalized, got %v", spans)
+	}
+	if msgSpan.Parent().SpanID() != caller.SpanContext().SpanID() {
+		t.Fatal("want msg span child of the span scheduling it")
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range msgSpan.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["msg.id"] != req.Id().Hex() || attrs["msg.from"] != helper.Addr1.Hex() || attrs["tx.hash"] != resp.Tx.Hash().Hex() {
+		t.Fatalf("want msg and tx attributes, got %v", attrs)
+	}
+
+	parents := map[string]string{
+		"ethclient.schedule":     "ethclient.message",
+		"ethclient.sequence":     "ethclient.message",
+		"ethclient.broadcast":    "ethclient.message",
+		"ethclient.estimate_gas": "ethclient.broadcast",
+		"ethclient.sign":         "ethclient.broadcast",
+		"ethclient.send":         "ethclient.broadcast",
+		"ethclient.protect":      "ethclient.message",
+		"ethclient.wait_receipt": "ethclient.protect",
+		"ethclient.finality":     "ethclient.message",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("want span %v", name)
+		}
+		if span.Parent().SpanID() != spans[parent].SpanContext().SpanID() {
+			t.Fatalf("want span %v child of %v", name, parent)
+		}
+		if span.SpanContext().TraceID() != caller.SpanContext().TraceID() {
+			t.Fatalf("want span %v in the trace of the caller", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package subscriber_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/message"
+	"github.com/ivanzzeth/ethclient/subscriber"
+	"github.com/ivanzzeth/ethclient/tests/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SubscribeFilterLogs_Reorg(t *testing.T) {
+	sim := helper.SetUpClient(t)
+	defer sim.Close()
+
+	client := sim.Client()
+	client.SetBlockConfirmationsOnSubscription(0)
+	cs := client.Subscriber.(*subscriber.ChainSubscriber)
+	defer cs.Close()
+	cs.SetRetryInterval(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	contractAddr, _, contract := helper.DeployTestContract(t, ctx, sim)
+
+	fromBlock, err := client.BlockNumber(ctx)
+	require.NoError(t, err)
+
+	logs := make(chan types.Log, 16)
+	sub, err := client.Subscriber.SubscribeFilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: big.NewInt(0).SetUint64(fromBlock + 1),
+		Addresses: []common.Address{contractAddr},
+	}, logs)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	parent, err := client.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+
+	opts, err := client.MessageToTransactOpts(ctx, message.Request{From: helper.Addr1})
+	require.NoError(t, err)
+	tx, err := contract.TestFunc1(opts, "hello", big.NewInt(100), []byte("world"))
+	require.NoError(t, err)
+	orphanHash := sim.CommitAndExpectTx(tx.Hash())
+
+	receiveLog := func() types.Log {
+		select {
+		case l := <-logs:
+			return l
+		case <-ctx.Done():
+			t.Fatal("no log received")
+		}
+		return types.Log{}
+	}
+
+	var delivered []types.Log
+	for i := 0; i < 2; i++ {
+		l := receiveLog()
+		require.False(t, l.Removed)
+		require.Equal(t, orphanHash, l.BlockHash)
+		delivered = append(delivered, l)
+	}
+
+	// Replace the block including the tx with a longer side chain.
+	require.NoError(t, sim.Fork(parent.Hash()))
+	sim.Rollback()
+	sim.Commit()
+	sim.Commit()
+
+	for i := len(delivered) - 1; i >= 0; i-- {
+		l := receiveLog()
+		assert.True(t, l.Removed)
+		assert.Equal(t, delivered[i].BlockHash, l.BlockHash)
+		assert.Equal(t, delivered[i].Index, l.Index)
+	}
+
+	select {
+	case l := <-logs:
+		// The tx may be included again in the new chain.
+		assert.False(t, l.Removed)
+		assert.NotEqual(t, orphanHash, l.BlockHash)
+	case <-time.After(2 * time.Second):
+	}
+}
@@ -0,0 +1,152 @@
+package ethclient
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ivanzzeth/ethclient/message"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ivanzzeth/ethclient"
+
+// msgTrace is the span of a msg, from scheduling until it's finalized or failed,
+// and the span of the stage it's in.
+type msgTrace struct {
+	span trace.Span
+
+	mu    sync.Mutex
+	stage trace.Span
+}
+
+// SetTracerProvider sets the provider of the spans traced for each msg, the global one by default.
+// Set it before scheduling any msg.
+func (c *Client) SetTracerProvider(tp trace.TracerProvider) {
+	c.tracer = tp.Tracer(tracerName)
+}
+
+// startMsgTrace starts the span of req as a child of the span in ctx, unless it's traced already.
+func (c *Client) startMsgTrace(ctx context.Context, req message.Request, opts ...trace.SpanStartOption) {
+	if _, ok := c.msgTraces.Load(req.Id()); ok {
+		return
+	}
+
+	opts = append(opts, trace.WithAttributes(message.MsgAttributes(req)...))
+	_, span := c.tracer.Start(ctx, "ethclient.message", opts...)
+	if !span.IsRecording() {
+		return
+	}
+	c.msgTraces.Store(req.Id(), &msgTrace{span: span})
+}
+
+func (c *Client) loadMsgTrace(msgId common.Hash) *msgTrace {
+	t, ok := c.msgTraces.Load(msgId)
+	if !ok {
+		return nil
+	}
+	return t.(*msgTrace)
+}
+
+// msgContext returns ctx carrying the span of msgId, so that the steps of the msg are traced as its children.
+func (c *Client) msgContext(ctx context.Context, msgId common.Hash) context.Context {
+	if t := c.loadMsgTrace(msgId); t != nil {
+		return trace.ContextWithSpan(ctx, t.span)
+	}
+	return ctx
+}
+
+// msgLink links the span of a msg created by msgId, e.g. the next one of a recurring msg, to its span.
+func (c *Client) msgLink(msgId common.Hash) trace.SpanStartOption {
+	return trace.WithLinks(trace.LinkFromContext(c.msgContext(context.Background(), msgId)))
+}
+
+// startMsgStage ends the stage msgId is in, and starts the one named name.
+func (c *Client) startMsgStage(msgId common.Hash, name string) {
+	t := c.loadMsgTrace(msgId)
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stage != nil {
+		t.stage.End()
+	}
+	_, t.stage = c.tracer.Start(trace.ContextWithSpan(context.Background(), t.span), name)
+}
+
+func (c *Client) endMsgStage(msgId common.Hash) {
+	t := c.loadMsgTrace(msgId)
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stage != nil {
+		t.stage.End()
+		t.stage = nil
+	}
+}
+
+// traceTx records the tx sent for msgId, the last one if replaced.
+func (c *Client) traceTx(msgId common.Hash, tx *types.Transaction) {
+	if t := c.loadMsgTrace(msgId); t != nil && tx != nil {
+		t.span.SetAttributes(message.TxAttributes(tx)...)
+	}
+}
+
+func (c *Client) traceReceipt(receipt message.Receipt) {
+	t := c.loadMsgTrace(receipt.Id)
+	if t == nil {
+		return
+	}
+
+	switch receipt.Status {
+	case message.MessageStatusOnChain:
+		if receipt.TxReceipt != nil {
+			t.span.SetAttributes(attribute.String("tx.hash", receipt.TxReceipt.TxHash.Hex()))
+		}
+		c.startMsgStage(receipt.Id, "ethclient.finality")
+	case message.MessageStatusInflight:
+		t.span.AddEvent("reorged")
+		c.endMsgStage(receipt.Id)
+	case message.MessageStatusFinalized, message.MessageStatusCancelled:
+		c.endMsgTrace(receipt.Id, nil)
+	}
+}
+
+// endMsgTrace ends the span of msgId, failed with err if not nil.
+func (c *Client) endMsgTrace(msgId common.Hash, err error) {
+	v, ok := c.msgTraces.LoadAndDelete(msgId)
+	if !ok {
+		return
+	}
+	t := v.(*msgTrace)
+
+	t.mu.Lock()
+	if t.stage != nil {
+		t.stage.End()
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		t.span.RecordError(err)
+		t.span.SetStatus(codes.Error, err.Error())
+	}
+	t.span.End()
+}
+
+// endMsgTraces ends the spans of msgs unfinished when the client is closed.
+func (c *Client) endMsgTraces() {
+	c.msgTraces.Range(func(msgId, _ any) bool {
+		c.endMsgTrace(msgId.(common.Hash), nil)
+		return true
+	})
+}
@@ -0,0 +1,238 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBatchExcludedMethods are sent on their own when BatchConfig.ExcludedMethods is nil,
+// since nodes may handle requests of a batch in any order, e.g. txs of the same sender.
+var DefaultBatchExcludedMethods = []string{
+	"eth_sendRawTransaction",
+	"eth_sendTransaction",
+}
+
+type BatchConfig struct {
+	// Window is how long a request is held for being batched with the concurrent ones.
+	Window time.Duration
+	// MaxBatchSize is the max number of requests in a batch, which is sent as soon as it's full.
+	MaxBatchSize int
+	// ExcludedMethods are never batched, DefaultBatchExcludedMethods if nil.
+	ExcludedMethods []string
+}
+
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		Window:       5 * time.Millisecond,
+		MaxBatchSize: 100,
+	}
+}
+
+var _ http.RoundTripper = (*BatchTransport)(nil)
+
+// BatchTransport coalesces concurrent single JSON-RPC requests to the same url into batch requests,
+// and splits the responses, errors included, back to each request.
+// Requests already batched and excluded methods are sent as is.
+type BatchTransport struct {
+	base     http.RoundTripper
+	config   BatchConfig
+	excluded map[string]bool
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch
+}
+
+type batchedRequest struct {
+	req  *http.Request
+	msg  *jsonrpcMessage
+	resp chan batchedResponse
+}
+
+type batchedResponse struct {
+	resp *http.Response
+	err  error
+}
+
+type pendingBatch struct {
+	url   string
+	reqs  []*batchedRequest
+	timer *time.Timer
+}
+
+// NewBatchTransport creates a transport batching requests sent through base.
+// If base is nil, http.DefaultTransport is used.
+func NewBatchTransport(base http.RoundTripper, config BatchConfig) *BatchTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	excludedMethods := config.ExcludedMethods
+	if excludedMethods == nil {
+		excludedMethods = DefaultBatchExcludedMethods
+	}
+	excluded := make(map[string]bool, len(excludedMethods))
+	for _, method := range excludedMethods {
+		excluded[method] = true
+	}
+
+	return &BatchTransport{
+		base:     base,
+		config:   config,
+		excluded: excluded,
+		pending:  make(map[string]*pendingBatch),
+	}
+}
+
+func (t *BatchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || t.config.Window <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, batch, err := parseMessages(body)
+	if err != nil || batch || len(msgs[0].ID) == 0 || t.excluded[msgs[0].Method] {
+		return t.base.RoundTrip(req)
+	}
+
+	r := &batchedRequest{req: req, msg: msgs[0], resp: make(chan batchedResponse, 1)}
+	t.enqueue(r)
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case resp := <-r.resp:
+		return resp.resp, resp.err
+	}
+}
+
+func (t *BatchTransport) enqueue(r *batchedRequest) {
+	url := r.req.URL.String()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.pending[url]
+	if !ok {
+		b = &pendingBatch{url: url}
+		b.timer = time.AfterFunc(t.config.Window, func() { t.flush(b) })
+		t.pending[url] = b
+	}
+
+	b.reqs = append(b.reqs, r)
+
+	if t.config.MaxBatchSize > 0 && len(b.reqs) >= t.config.MaxBatchSize {
+		b.timer.Stop()
+		delete(t.pending, url)
+		go t.send(b)
+	}
+}
+
+func (t *BatchTransport) flush(b *pendingBatch) {
+	t.mu.Lock()
+	if t.pending[b.url] != b {
+		// sent once full
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, b.url)
+	t.mu.Unlock()
+
+	t.send(b)
+}
+
+func (t *BatchTransport) send(b *pendingBatch) {
+	if len(b.reqs) == 1 {
+		r := b.reqs[0]
+		resp, err := t.base.RoundTrip(r.req)
+		r.resp <- batchedResponse{resp: resp, err: err}
+		return
+	}
+
+	// Requests are renumbered, since they may come from different rpc clients with the same ids.
+	msgs := make([]*jsonrpcMessage, len(b.reqs))
+	for i, r := range b.reqs {
+		msg := *r.msg
+		msg.ID = json.RawMessage(strconv.Itoa(i + 1))
+		msgs[i] = &msg
+	}
+
+	body, err := json.Marshal(msgs)
+	if err != nil {
+		t.fail(b, err)
+		return
+	}
+
+	// The batch outlives the cancellation of any request in it.
+	first := b.reqs[0].req
+	req, err := cloneRequest(first.WithContext(context.WithoutCancel(first.Context())), b.url, body)
+	if err != nil {
+		t.fail(b, err)
+		return
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.fail(b, err)
+		return
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		t.fail(b, err)
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		for _, r := range b.reqs {
+			r.resp <- batchedResponse{resp: newResponse(r.req, resp.StatusCode, respBody)}
+		}
+		return
+	}
+
+	resps, batch, err := parseMessages(respBody)
+	if err != nil {
+		t.fail(b, fmt.Errorf("invalid batch response: %v", err))
+		return
+	}
+
+	byId := make(map[string]*jsonrpcMessage, len(resps))
+	for _, resp := range resps {
+		byId[string(resp.ID)] = resp
+	}
+
+	for i, r := range b.reqs {
+		resp, ok := byId[strconv.Itoa(i+1)]
+		if !batch && len(resps) == 1 {
+			// an error for the whole batch, e.g. the batch is too large
+			resp, ok = resps[0], true
+		}
+		if !ok {
+			r.resp <- batchedResponse{err: fmt.Errorf("no response for %v in batch", r.msg.Method)}
+			continue
+		}
+
+		out := *resp
+		out.ID = r.msg.ID
+		body, err := json.Marshal(&out)
+		if err != nil {
+			r.resp <- batchedResponse{err: err}
+			continue
+		}
+		r.resp <- batchedResponse{resp: newResponse(r.req, http.StatusOK, body)}
+	}
+}
+
+func (t *BatchTransport) fail(b *pendingBatch, err error) {
+	for _, r := range b.reqs {
+		r.resp <- batchedResponse{err: err}
+	}
+}
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBatchServer echoes the params of eth_echo and fails eth_fail, counting http requests and the size of batches.
+func newBatchServer(t *testing.T) (*httptest.Server, *atomic.Int64, *atomic.Int64) {
+	var requests, maxBatch atomic.Int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		reqs, batch, err := parseMessages(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if int64(len(reqs)) > maxBatch.Load() {
+			maxBatch.Store(int64(len(reqs)))
+		}
+
+		var resps []*jsonrpcMessage
+		for _, req := range reqs {
+			resp := &jsonrpcMessage{Version: "2.0", ID: req.ID}
+			if req.Method == "eth_fail" {
+				resp.Error = &jsonrpcError{Code: -32000, Message: "failed"}
+			} else {
+				resp.Result = req.Params
+			}
+			resps = append(resps, resp)
+		}
+
+		if batch {
+			json.NewEncoder(w).Encode(resps)
+		} else {
+			json.NewEncoder(w).Encode(resps[0])
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests, &maxBatch
+}
+
+func postJson(t *testing.T, rt http.RoundTripper, url, body string) *jsonrpcMessage {
+	client := &http.Client{Transport: rt}
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var msg jsonrpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Error(err)
+		return nil
+	}
+	return &msg
+}
+
+func TestBatchTransport(t *testing.T) {
+	s, requests, maxBatch := newBatchServer(t)
+	bt := NewBatchTransport(nil, BatchConfig{Window: 50 * time.Millisecond, MaxBatchSize: 4})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			method := "eth_echo"
+			if i%5 == 0 {
+				method = "eth_fail"
+			}
+			// the same id for every request, as sent by different rpc clients
+			resp := postJson(t, bt, s.URL, fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":"%v","params":[%d]}`, method, i))
+			if resp == nil {
+				return
+			}
+
+			if string(resp.ID) != "7" {
+				t.Errorf("want id of the request restored, got %s", resp.ID)
+			}
+			if method == "eth_fail" {
+				if resp.Error == nil || resp.Error.Message != "failed" {
+					t.Errorf("want error of request %d, got %+v", i, resp)
+				}
+			} else if string(resp.Result) != fmt.Sprintf("[%d]", i) {
+				t.Errorf("want result of request %d, got %s", i, resp.Result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if requests.Load() != 3 || maxBatch.Load() != 4 {
+		t.Fatalf("want 10 requests in 3 batches of 4 at most, got %v requests, max batch %v", requests.Load(), maxBatch.Load())
+	}
+}
+
+func TestBatchTransport_Excluded(t *testing.T) {
+	s, requests, maxBatch := newBatchServer(t)
+	bt := NewBatchTransport(nil, BatchConfig{Window: 50 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			postJson(t, bt, s.URL, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"eth_sendRawTransaction","params":[]}`, i))
+		}(i)
+	}
+	wg.Wait()
+
+	if requests.Load() != 3 || maxBatch.Load() != 1 {
+		t.Fatalf("want txs sent on their own, got %v requests, max batch %v", requests.Load(), maxBatch.Load())
+	}
+}
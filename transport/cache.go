@@ -0,0 +1,334 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/cache"
+)
+
+type CacheConfig struct {
+	// Size is the number of responses kept in memory.
+	Size int
+	// Storage persists responses behind the memory, e.g. cache.RedisStorage. Optional.
+	Storage cache.Storage
+	// BlockTag considered final, rpc.FinalizedBlockNumber or rpc.SafeBlockNumber.
+	// Txs and receipts are cached once their block is final.
+	BlockTag rpc.BlockNumber
+	// Confirmations after which blocks are considered final if BlockTag is not supported by the node.
+	Confirmations uint64
+	// FinalityInterval is how long the final block is cached.
+	FinalityInterval time.Duration
+}
+
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Size:             10000,
+		BlockTag:         rpc.FinalizedBlockNumber,
+		Confirmations:    64,
+		FinalityInterval: 10 * time.Second,
+	}
+}
+
+var _ http.RoundTripper = (*CacheTransport)(nil)
+
+// CacheTransport answers requests of immutable data from the cache, i.e. eth_chainId, eth_getBlockByHash,
+// and eth_getTransactionByHash and eth_getTransactionReceipt once their block is final.
+// Other requests, and the ones not cached yet, are sent through base.
+type CacheTransport struct {
+	base    http.RoundTripper
+	config  CacheConfig
+	memory  *cache.MemoryStorage
+	storage cache.Storage
+
+	mu          sync.Mutex
+	final       map[string]uint64 // the final block of each url
+	finalExpiry map[string]time.Time
+}
+
+// NewCacheTransport creates a transport caching responses of base.
+// If base is nil, http.DefaultTransport is used.
+func NewCacheTransport(base http.RoundTripper, config CacheConfig) *CacheTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &CacheTransport{
+		base:        base,
+		config:      config,
+		memory:      cache.NewMemoryStorage(config.Size),
+		storage:     config.Storage,
+		final:       make(map[string]uint64),
+		finalExpiry: make(map[string]time.Time),
+	}
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, batch, err := parseMessages(body)
+	if err != nil {
+		return t.base.RoundTrip(req)
+	}
+
+	// Answers from the cache, and requests missed.
+	answers := make([]*jsonrpcMessage, len(msgs))
+	var missed []*jsonrpcMessage
+	for i, msg := range msgs {
+		if result, ok := t.get(msg); ok {
+			answers[i] = &jsonrpcMessage{Version: "2.0", ID: msg.ID, Result: result}
+			continue
+		}
+		missed = append(missed, msg)
+	}
+
+	if len(missed) == 0 {
+		return t.respond(req, answers, batch)
+	}
+
+	if len(missed) < len(msgs) {
+		body, err = json.Marshal(missed)
+		if err != nil {
+			return nil, err
+		}
+		req, err = cloneRequest(req, req.URL.String(), body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newBody(respBody)
+
+	resps, _, err := parseMessages(respBody)
+	if err != nil {
+		return resp, nil
+	}
+
+	byId := make(map[string]*jsonrpcMessage, len(resps))
+	for _, r := range resps {
+		byId[string(r.ID)] = r
+	}
+
+	for _, msg := range missed {
+		if r, ok := byId[string(msg.ID)]; ok {
+			t.put(req.URL.String(), msg, r)
+		}
+	}
+
+	if len(missed) == len(msgs) {
+		return resp, nil
+	}
+
+	for i, msg := range msgs {
+		if answers[i] != nil {
+			continue
+		}
+
+		r, ok := byId[string(msg.ID)]
+		if !ok {
+			return nil, fmt.Errorf("no response for %v", msg.Method)
+		}
+		answers[i] = r
+	}
+
+	return t.respond(req, answers, batch)
+}
+
+func (t *CacheTransport) respond(req *http.Request, answers []*jsonrpcMessage, batch bool) (*http.Response, error) {
+	var body []byte
+	var err error
+	if batch {
+		body, err = json.Marshal(answers)
+	} else {
+		body, err = json.Marshal(answers[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse(req, http.StatusOK, body), nil
+}
+
+func cacheKey(msg *jsonrpcMessage) string {
+	// params are hashes and flags, compared case-insensitively
+	var params bytes.Buffer
+	if err := json.Compact(&params, msg.Params); err != nil {
+		params.Write(msg.Params)
+	}
+	return msg.Method + ":" + strings.ToLower(params.String())
+}
+
+func cacheable(method string) bool {
+	switch method {
+	case "eth_chainId", "eth_getBlockByHash", "eth_getTransactionByHash", "eth_getTransactionReceipt":
+		return true
+	}
+	return false
+}
+
+func (t *CacheTransport) get(msg *jsonrpcMessage) (json.RawMessage, bool) {
+	if !cacheable(msg.Method) {
+		return nil, false
+	}
+
+	key := cacheKey(msg)
+	if value, ok, _ := t.memory.Get(key); ok {
+		return value, true
+	}
+
+	if t.storage == nil {
+		return nil, false
+	}
+
+	value, ok, err := t.storage.Get(key)
+	if err != nil {
+		log.Warn("get cached response failed", "method", msg.Method, "err", err)
+		return nil, false
+	}
+	if ok {
+		t.memory.Set(key, value)
+	}
+
+	return value, ok
+}
+
+func (t *CacheTransport) put(url string, msg, resp *jsonrpcMessage) {
+	if !cacheable(msg.Method) || resp.Error != nil || len(resp.Result) == 0 || string(resp.Result) == "null" {
+		return
+	}
+
+	switch msg.Method {
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt":
+		var located struct {
+			BlockNumber *hexutil.Big `json:"blockNumber"`
+		}
+		if err := json.Unmarshal(resp.Result, &located); err != nil || located.BlockNumber == nil {
+			// pending
+			return
+		}
+
+		final, err := t.finalBlock(url)
+		if err != nil {
+			log.Debug("get final block failed", "err", err)
+			return
+		}
+		if located.BlockNumber.ToInt().Uint64() > final {
+			return
+		}
+	}
+
+	key := cacheKey(msg)
+	t.memory.Set(key, resp.Result)
+	if t.storage != nil {
+		if err := t.storage.Set(key, resp.Result); err != nil {
+			log.Warn("cache response failed", "method", msg.Method, "err", err)
+		}
+	}
+}
+
+// finalBlock returns the highest block considered final by the endpoint at url.
+func (t *CacheTransport) finalBlock(url string) (uint64, error) {
+	t.mu.Lock()
+	final, expiry := t.final[url], t.finalExpiry[url]
+	t.mu.Unlock()
+	if time.Now().Before(expiry) {
+		return final, nil
+	}
+
+	var head struct {
+		Number hexutil.Uint64 `json:"number"`
+	}
+	found, err := t.call(url, "eth_getBlockByNumber", &head, t.config.BlockTag.String(), false)
+	if err == nil && found {
+		final = uint64(head.Number)
+	} else {
+		var latest hexutil.Uint64
+		if _, err := t.call(url, "eth_blockNumber", &latest); err != nil {
+			return 0, err
+		}
+
+		final = 0
+		if uint64(latest) > t.config.Confirmations {
+			final = uint64(latest) - t.config.Confirmations
+		}
+	}
+
+	t.mu.Lock()
+	t.final[url] = final
+	t.finalExpiry[url] = time.Now().Add(t.config.FinalityInterval)
+	t.mu.Unlock()
+
+	return final, nil
+}
+
+// call calls method through base, returning false if the result is null.
+func (t *CacheTransport) call(url, method string, result interface{}, params ...interface{}) (bool, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(&jsonrpcMessage{Version: "2.0", ID: json.RawMessage("1"), Method: method, Params: rawParams})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return false, err
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%v responded with status %v", method, resp.StatusCode)
+	}
+
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(respBody, &msg); err != nil {
+		return false, err
+	}
+	if msg.Error != nil {
+		return false, fmt.Errorf("%v err: %v", method, msg.Error.Message)
+	}
+	if len(msg.Result) == 0 || string(msg.Result) == "null" {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(msg.Result, result)
+}
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ivanzzeth/ethclient/cache"
+)
+
+// newChainServer serves a chain with head 100, finalized 90, where tx 0x1 is at block 80 and tx 0x2 at block 95,
+// counting the requests of each method.
+func newChainServer(t *testing.T) (*httptest.Server, func(method string) int) {
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	results := map[string]string{
+		"eth_chainId":                    `"0x1"`,
+		"eth_getBlockByHash:0xaa":        `{"number":"0x50","hash":"0xaa"}`,
+		"eth_getBlockByHash:0xff":        `null`,
+		"eth_getBlockByNumber:finalized": `{"number":"0x5a"}`,
+		"eth_getTransactionByHash:0x1":   `{"hash":"0x1","blockNumber":"0x50"}`,
+		"eth_getTransactionReceipt:0x1":  `{"transactionHash":"0x1","blockNumber":"0x50"}`,
+		"eth_getTransactionReceipt:0x2":  `{"transactionHash":"0x2","blockNumber":"0x5f"}`,
+		"eth_getTransactionByHash:0x3":   `{"hash":"0x3","blockNumber":null}`,
+		"eth_blockNumber":                `"0x64"`,
+		"eth_getBlockByNumber:latest":    `{"number":"0x64"}`,
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs, batch, err := parseMessages(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var resps []*jsonrpcMessage
+		for _, req := range reqs {
+			mu.Lock()
+			counts[req.Method]++
+			mu.Unlock()
+
+			key := req.Method
+			var params []interface{}
+			json.Unmarshal(req.Params, &params)
+			if len(params) > 0 {
+				key += ":" + strings.ToLower(params[0].(string))
+			}
+
+			resp := &jsonrpcMessage{Version: "2.0", ID: req.ID}
+			if result, ok := results[key]; ok {
+				resp.Result = json.RawMessage(result)
+			} else {
+				resp.Error = &jsonrpcError{Code: -32601, Message: "not found"}
+			}
+			resps = append(resps, resp)
+		}
+
+		if batch {
+			json.NewEncoder(w).Encode(resps)
+		} else {
+			json.NewEncoder(w).Encode(resps[0])
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s, func(method string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[method]
+	}
+}
+
+func TestCacheTransport(t *testing.T) {
+	s, count := newChainServer(t)
+	ct := NewCacheTransport(nil, DefaultCacheConfig())
+
+	for i := 0; i < 3; i++ {
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+		resp := postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":2,"method":"eth_getBlockByHash","params":["0xAA",false]}`)
+		if resp == nil || string(resp.ID) != "2" || string(resp.Result) != `{"number":"0x50","hash":"0xaa"}` {
+			t.Fatalf("unexpected block: %+v", resp)
+		}
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":3,"method":"eth_getBlockByHash","params":["0xff",false]}`)
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":4,"method":"eth_getTransactionReceipt","params":["0x1"]}`)
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":5,"method":"eth_getTransactionReceipt","params":["0x2"]}`)
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":6,"method":"eth_getTransactionByHash","params":["0x3"]}`)
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":7,"method":"eth_blockNumber","params":[]}`)
+	}
+
+	if count("eth_chainId") != 1 {
+		t.Fatalf("want chain id cached, got %v requests", count("eth_chainId"))
+	}
+	if count("eth_getBlockByHash") != 4 {
+		t.Fatalf("want block cached but not the missing one, got %v requests", count("eth_getBlockByHash"))
+	}
+	if count("eth_getTransactionReceipt") != 4 {
+		t.Fatalf("want only the finalized receipt cached, got %v requests", count("eth_getTransactionReceipt"))
+	}
+	if count("eth_getTransactionByHash") != 3 {
+		t.Fatalf("want pending tx not cached, got %v requests", count("eth_getTransactionByHash"))
+	}
+	if count("eth_blockNumber") != 3 {
+		t.Fatalf("want mutable data not cached, got %v requests", count("eth_blockNumber"))
+	}
+	if count("eth_getBlockByNumber") != 1 {
+		t.Fatalf("want finalized block refreshed by interval, got %v requests", count("eth_getBlockByNumber"))
+	}
+}
+
+func TestCacheTransport_Batch(t *testing.T) {
+	s, count := newChainServer(t)
+	ct := NewCacheTransport(nil, DefaultCacheConfig())
+
+	postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+
+	client := &http.Client{Transport: ct}
+	resp, err := client.Post(s.URL, "application/json", newBody([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber","params":[]}
+	]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var msgs []*jsonrpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || string(msgs[0].ID) != "1" || string(msgs[0].Result) != `"0x1"` ||
+		string(msgs[1].ID) != "2" || string(msgs[1].Result) != `"0x64"` {
+		t.Fatalf("unexpected batch response: %+v %+v", msgs[0], msgs[1])
+	}
+	if count("eth_chainId") != 1 || count("eth_blockNumber") != 1 {
+		t.Fatal("want only misses of the batch forwarded")
+	}
+}
+
+func TestCacheTransport_Storage(t *testing.T) {
+	s, count := newChainServer(t)
+	storage := cache.NewMemoryStorage(10)
+
+	config := DefaultCacheConfig()
+	config.Storage = storage
+	postJson(t, NewCacheTransport(nil, config), s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+
+	// a new transport, e.g. after a restart, reads from the storage
+	resp := postJson(t, NewCacheTransport(nil, config), s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	if resp == nil || string(resp.Result) != `"0x1"` || count("eth_chainId") != 1 {
+		t.Fatalf("want chain id read from storage, got %+v, %v requests", resp, count("eth_chainId"))
+	}
+}
+
+func TestCacheTransport_Confirmations(t *testing.T) {
+	s, count := newChainServer(t)
+
+	config := DefaultCacheConfig()
+	// not supported by the server
+	config.BlockTag = rpc.SafeBlockNumber
+	config.Confirmations = 3
+	config.FinalityInterval = time.Minute
+	ct := NewCacheTransport(nil, config)
+
+	for i := 0; i < 2; i++ {
+		postJson(t, ct, s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0x2"]}`)
+	}
+	if count("eth_getTransactionReceipt") != 1 || count("eth_blockNumber") != 1 {
+		t.Fatalf("want receipt cached after confirmations, got %v requests", count("eth_getTransactionReceipt"))
+	}
+}
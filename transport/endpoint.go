@@ -0,0 +1,151 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CircuitState uint8
+
+const (
+	CircuitClosed   CircuitState = iota + 1 // requests flow normally
+	CircuitOpen                             // requests are rejected until the open timeout elapsed
+	CircuitHalfOpen                         // one trial request is allowed to probe the endpoint
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker stops sending requests to an endpoint after too many consecutive failures.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            CircuitState
+	failures         int
+	openedAt         time.Time
+	trialInFlight    bool
+	failureThreshold int
+	openTimeout      time.Duration
+}
+
+func newCircuitBreaker(failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
+	return &circuitBreaker{
+		state:            CircuitClosed,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+}
+
+// allow reports whether a request may be sent now.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.trialInFlight = true
+		return true
+	case CircuitHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trialInFlight = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trialInFlight = false
+	if b.state == CircuitHalfOpen || b.failures >= b.failureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release gives up the trial request without a verdict, e.g. as its caller cancelled it,
+// so that the next request probes the endpoint instead.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialInFlight = false
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Endpoint is one upstream JSON-RPC url with its own health and circuit state.
+type Endpoint struct {
+	URL string
+
+	transport   http.RoundTripper
+	breaker     *circuitBreaker
+	healthy     atomic.Bool
+	latestBlock atomic.Uint64
+}
+
+func newEndpoint(rawurl string, transport http.RoundTripper, failureThreshold int, openTimeout time.Duration) *Endpoint {
+	e := &Endpoint{
+		URL:       rawurl,
+		transport: transport,
+		breaker:   newCircuitBreaker(failureThreshold, openTimeout),
+	}
+	e.healthy.Store(true)
+
+	return e
+}
+
+// EndpointStatus is a snapshot of an endpoint state, useful for diagnosis.
+type EndpointStatus struct {
+	URL         string
+	Healthy     bool
+	Circuit     CircuitState
+	LatestBlock uint64
+}
+
+func (e *Endpoint) Status() EndpointStatus {
+	return EndpointStatus{
+		URL:         e.URL,
+		Healthy:     e.healthy.Load(),
+		Circuit:     e.breaker.currentState(),
+		LatestBlock: e.latestBlock.Load(),
+	}
+}
@@ -0,0 +1,326 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	ErrNoEndpoints         = errors.New("no endpoints provided")
+	ErrNoEndpointAvailable = errors.New("no endpoint available, all circuits are open")
+
+	errRateLimitedResponse = errors.New("endpoint responded with rate limit error")
+	errServerErrorResponse = errors.New("endpoint responded with server error")
+)
+
+var healthCheckRequestBody = []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+
+type FailoverConfig struct {
+	// How often all endpoints are probed with eth_blockNumber. 0 disables health checks.
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// Consecutive failures before the circuit of an endpoint opens.
+	FailureThreshold int
+	// How long an open circuit rejects requests before a trial request is let through.
+	OpenTimeout time.Duration
+	// Endpoints lagging behind the highest block seen by more than MaxBlockLag blocks
+	// are marked unhealthy by health checks. 0 disables the check.
+	MaxBlockLag uint64
+	// Quorum enables quorum reads when set, see QuorumConfig.
+	Quorum *QuorumConfig
+}
+
+func DefaultFailoverConfig() FailoverConfig {
+	return FailoverConfig{
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		FailureThreshold:    3,
+		OpenTimeout:         30 * time.Second,
+	}
+}
+
+var _ http.RoundTripper = (*FailoverTransport)(nil)
+
+// FailoverTransport sends every request to the first available endpoint in priority order,
+// and fails over to the next one on transport errors, 5xx responses or rate limiting.
+type FailoverTransport struct {
+	endpoints []*Endpoint
+	config    FailoverConfig
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewFailoverTransport creates a transport over rawurls, ordered by priority.
+// If base is nil, http.DefaultTransport is used for all endpoints.
+func NewFailoverTransport(rawurls []string, base http.RoundTripper, config FailoverConfig) (*FailoverTransport, error) {
+	if len(rawurls) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	if q := config.Quorum; q != nil {
+		if q.Threshold < 1 {
+			return nil, fmt.Errorf("invalid quorum threshold %d", q.Threshold)
+		}
+
+		n := q.Endpoints
+		if n == 0 {
+			n = len(rawurls)
+		}
+		if q.Threshold > n || n > len(rawurls) {
+			return nil, fmt.Errorf("invalid quorum %d of %d with %d endpoints", q.Threshold, n, len(rawurls))
+		}
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &FailoverTransport{
+		config:  config,
+		closeCh: make(chan struct{}),
+	}
+
+	for _, rawurl := range rawurls {
+		t.endpoints = append(t.endpoints, newEndpoint(rawurl, base, config.FailureThreshold, config.OpenTimeout))
+	}
+
+	if config.HealthCheckInterval > 0 {
+		go t.runHealthCheck()
+	}
+
+	return t, nil
+}
+
+func (t *FailoverTransport) Endpoints() []*Endpoint {
+	return t.endpoints
+}
+
+func (t *FailoverTransport) Status() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		statuses = append(statuses, e.Status())
+	}
+	return statuses
+}
+
+func (t *FailoverTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closeCh)
+	})
+	return nil
+}
+
+func (t *FailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.config.Quorum != nil && t.config.Quorum.requires(requestMethods(body)) {
+		return t.roundTripQuorum(req, body)
+	}
+
+	var (
+		lastResp *http.Response
+		lastErr  error
+	)
+
+	for _, e := range t.candidates() {
+		if !e.breaker.allow() {
+			continue
+		}
+
+		resp, err := t.send(req, e, body)
+		if err == nil {
+			e.breaker.success()
+			return resp, nil
+		}
+
+		if req.Context().Err() != nil {
+			// The caller gave up, it says nothing about the endpoint.
+			e.breaker.release()
+			return nil, req.Context().Err()
+		}
+
+		log.Warn("rpc endpoint failed, failing over", "url", e.URL, "err", err, "methods", requestMethods(body))
+		e.breaker.failure()
+		lastErr = err
+		if resp != nil {
+			lastResp = resp
+		}
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+
+	if lastErr == nil {
+		lastErr = ErrNoEndpointAvailable
+	}
+
+	return nil, lastErr
+}
+
+// candidates returns healthy endpoints first, then unhealthy ones as a last resort,
+// both in priority order.
+func (t *FailoverTransport) candidates() []*Endpoint {
+	candidates := make([]*Endpoint, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		if e.healthy.Load() {
+			candidates = append(candidates, e)
+		}
+	}
+	for _, e := range t.endpoints {
+		if !e.healthy.Load() {
+			candidates = append(candidates, e)
+		}
+	}
+	return candidates
+}
+
+// send does one attempt against e. A non-nil error means the endpoint should be failed over,
+// the response returned along with it (if any) is the one to give back when no endpoint succeeds.
+func (t *FailoverTransport) send(req *http.Request, e *Endpoint, body []byte) (*http.Response, error) {
+	out, err := cloneRequest(req, e.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newBody(respBody)
+
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return resp, fmt.Errorf("%w: %v", errServerErrorResponse, resp.Status)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		msgs, _, err := parseMessages(respBody)
+		if err == nil {
+			for _, m := range msgs {
+				if isRateLimitError(m.Error) {
+					return resp, fmt.Errorf("%w: %v", errRateLimitedResponse, m.Error.Message)
+				}
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+func (t *FailoverTransport) runHealthCheck() {
+	ticker := time.NewTicker(t.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		t.checkHealth()
+
+		select {
+		case <-t.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *FailoverTransport) checkHealth() {
+	var wg sync.WaitGroup
+	for _, e := range t.endpoints {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+
+			block, err := t.probe(e)
+			if err != nil {
+				log.Warn("rpc endpoint health check failed", "url", e.URL, "err", err)
+				e.healthy.Store(false)
+				return
+			}
+
+			e.latestBlock.Store(block)
+			e.healthy.Store(true)
+		}(e)
+	}
+	wg.Wait()
+
+	if t.config.MaxBlockLag == 0 {
+		return
+	}
+
+	var best uint64
+	for _, e := range t.endpoints {
+		if e.healthy.Load() && e.latestBlock.Load() > best {
+			best = e.latestBlock.Load()
+		}
+	}
+
+	for _, e := range t.endpoints {
+		if e.healthy.Load() && e.latestBlock.Load()+t.config.MaxBlockLag < best {
+			log.Warn("rpc endpoint is lagging behind", "url", e.URL, "block", e.latestBlock.Load(), "best", best)
+			e.healthy.Store(false)
+		}
+	}
+}
+
+func (t *FailoverTransport) probe(e *Endpoint) (uint64, error) {
+	timeout := t.config.HealthCheckTimeout
+	if timeout == 0 {
+		timeout = t.config.HealthCheckInterval
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(healthCheckRequestBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.transport.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := readBody(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return 0, err
+	}
+
+	if msg.Error != nil {
+		return 0, fmt.Errorf("json-rpc error(code=%d, msg=%q)", msg.Error.Code, msg.Error.Message)
+	}
+
+	var block hexutil.Uint64
+	if err := json.Unmarshal(msg.Result, &block); err != nil {
+		return 0, err
+	}
+
+	return uint64(block), nil
+}
@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const blockNumberResponse = `{"jsonrpc":"2.0","id":1,"result":"0x10"}`
+
+func newTestServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int64) {
+	var hits atomic.Int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s, &hits
+}
+
+func newTestTransport(t *testing.T, urls []string, config FailoverConfig) *FailoverTransport {
+	ft, err := NewFailoverTransport(urls, nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ft.Close() })
+	return ft
+}
+
+func doRequest(t *testing.T, ft *FailoverTransport, url string) string {
+	client := &http.Client{Transport: ft}
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestFailoverTransport_Failover(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, "internal error"},
+		{"http rate limit", http.StatusTooManyRequests, "too many requests"},
+		{"json-rpc rate limit", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`},
+		{"json-rpc rate limit message", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"daily request quota reached"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad, badHits := newTestServer(t, tt.status, tt.body)
+			good, goodHits := newTestServer(t, http.StatusOK, blockNumberResponse)
+
+			ft := newTestTransport(t, []string{bad.URL, good.URL}, FailoverConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+			if got := doRequest(t, ft, bad.URL); got != blockNumberResponse {
+				t.Fatalf("unexpected response: %v", got)
+			}
+			if badHits.Load() != 1 || goodHits.Load() != 1 {
+				t.Fatalf("unexpected hits: bad=%v good=%v", badHits.Load(), goodHits.Load())
+			}
+		})
+	}
+}
+
+func TestFailoverTransport_TransportError(t *testing.T) {
+	good, _ := newTestServer(t, http.StatusOK, blockNumberResponse)
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	ft := newTestTransport(t, []string{dead.URL, good.URL}, FailoverConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	if got := doRequest(t, ft, dead.URL); got != blockNumberResponse {
+		t.Fatalf("unexpected response: %v", got)
+	}
+}
+
+func TestFailoverTransport_CircuitBreaker(t *testing.T) {
+	bad, badHits := newTestServer(t, http.StatusBadGateway, "bad gateway")
+	good, goodHits := newTestServer(t, http.StatusOK, blockNumberResponse)
+
+	ft := newTestTransport(t, []string{bad.URL, good.URL}, FailoverConfig{FailureThreshold: 2, OpenTimeout: 100 * time.Millisecond})
+
+	for i := 0; i < 5; i++ {
+		doRequest(t, ft, bad.URL)
+	}
+
+	if badHits.Load() != 2 {
+		t.Fatalf("circuit should open after 2 failures, bad endpoint hit %v times", badHits.Load())
+	}
+	if goodHits.Load() != 5 {
+		t.Fatalf("good endpoint should serve all requests, hit %v times", goodHits.Load())
+	}
+	if s := ft.Status()[0].Circuit; s != CircuitOpen {
+		t.Fatalf("expected open circuit, got %v", s)
+	}
+
+	// After the open timeout a single trial request goes through and reopens the circuit.
+	time.Sleep(150 * time.Millisecond)
+	doRequest(t, ft, bad.URL)
+	if badHits.Load() != 3 {
+		t.Fatalf("expected one trial request, bad endpoint hit %v times", badHits.Load())
+	}
+	if s := ft.Status()[0].Circuit; s != CircuitOpen {
+		t.Fatalf("expected reopened circuit, got %v", s)
+	}
+}
+
+func TestFailoverTransport_CancelledTrial(t *testing.T) {
+	tests := []struct {
+		name   string
+		quorum *QuorumConfig
+	}{
+		{"failover", nil},
+		{"quorum", &QuorumConfig{Threshold: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const (
+				failing = iota
+				hanging
+				serving
+			)
+			var mode atomic.Int64
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				switch mode.Load() {
+				case failing:
+					w.WriteHeader(http.StatusBadGateway)
+				case hanging:
+					<-r.Context().Done()
+				default:
+					w.Write([]byte(blockNumberResponse))
+				}
+			}))
+			t.Cleanup(s.Close)
+
+			ft := newTestTransport(t, []string{s.URL}, FailoverConfig{FailureThreshold: 1, OpenTimeout: 100 * time.Millisecond, Quorum: tt.quorum})
+			body := `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`
+
+			post(ft, s.URL, body)
+			if state := ft.Status()[0].Circuit; state != CircuitOpen {
+				t.Fatalf("expected open circuit, got %v", state)
+			}
+
+			// The trial request is cancelled by its caller while the circuit is half-open.
+			time.Sleep(150 * time.Millisecond)
+			mode.Store(hanging)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(body))
+			if _, err := (&http.Client{Transport: ft}).Do(req); err == nil {
+				t.Fatal("cancelled request should fail")
+			}
+			if state := ft.Status()[0].Circuit; state != CircuitHalfOpen {
+				t.Fatalf("expected half-open circuit, got %v", state)
+			}
+
+			// The next request probes the endpoint again and closes the circuit.
+			mode.Store(serving)
+			if got, err := post(ft, s.URL, body); err != nil || !strings.Contains(got, `"0x10"`) {
+				t.Fatalf("unexpected response %v: %v", got, err)
+			}
+			if state := ft.Status()[0].Circuit; state != CircuitClosed {
+				t.Fatalf("expected closed circuit, got %v", state)
+			}
+		})
+	}
+}
+
+func TestFailoverTransport_AllFailed(t *testing.T) {
+	bad1, _ := newTestServer(t, http.StatusInternalServerError, "error 1")
+	bad2, _ := newTestServer(t, http.StatusInternalServerError, "error 2")
+
+	ft := newTestTransport(t, []string{bad1.URL, bad2.URL}, FailoverConfig{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	// The last bad response is given back, so that rpc.Client reports it as usual.
+	if got := doRequest(t, ft, bad1.URL); got != "error 2" {
+		t.Fatalf("unexpected response: %v", got)
+	}
+}
+
+func TestFailoverTransport_HealthCheck(t *testing.T) {
+	bad, _ := newTestServer(t, http.StatusInternalServerError, "error")
+	lagging, _ := newTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	good, _ := newTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x100"}`)
+
+	ft := newTestTransport(t, []string{bad.URL, lagging.URL, good.URL}, FailoverConfig{MaxBlockLag: 10, HealthCheckTimeout: time.Second})
+	ft.checkHealth()
+
+	statuses := ft.Status()
+	if statuses[0].Healthy || statuses[1].Healthy || !statuses[2].Healthy {
+		t.Fatalf("unexpected health: %+v", statuses)
+	}
+	if statuses[2].LatestBlock != 0x100 {
+		t.Fatalf("unexpected latest block: %v", statuses[2].LatestBlock)
+	}
+
+	if c := ft.candidates(); c[0].URL != good.URL {
+		t.Fatalf("healthy endpoint should be tried first, got %v", c[0].URL)
+	}
+}
@@ -0,0 +1,144 @@
+// Package transport provides http.RoundTripper implementations which sit between
+// go-ethereum's rpc.Client and the upstream JSON-RPC endpoints, so that every
+// component sharing the rpc.Client (ethclient, subscriber, nonce and message
+// managers) benefits from them transparently.
+//
+// Only HTTP(S) endpoints are supported, websocket connections bypass http transports.
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ivanzzeth/ethclient/common/consts"
+)
+
+// jsonrpcMessage is the minimal shape of a JSON-RPC request or response we need to inspect.
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// isBatch reports whether the raw JSON body is a batch (array) message.
+func isBatch(raw []byte) bool {
+	for _, c := range raw {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return c == '['
+	}
+	return false
+}
+
+// parseMessages decodes a single or batch JSON-RPC body into a slice of messages.
+func parseMessages(raw []byte) ([]*jsonrpcMessage, bool, error) {
+	if isBatch(raw) {
+		var msgs []*jsonrpcMessage
+		if err := json.Unmarshal(raw, &msgs); err != nil {
+			return nil, true, err
+		}
+		return msgs, true, nil
+	}
+
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, false, err
+	}
+	return []*jsonrpcMessage{&msg}, false, nil
+}
+
+// requestMethods returns the methods called by a JSON-RPC request body.
+func requestMethods(raw []byte) []string {
+	msgs, _, err := parseMessages(raw)
+	if err != nil {
+		return nil
+	}
+
+	methods := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		methods = append(methods, m.Method)
+	}
+	return methods
+}
+
+// readBody drains and closes the body, returning its content.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// requestBody returns the body of req without consuming it for later retries.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		return readBody(body)
+	}
+
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = newBody(body)
+	return body, nil
+}
+
+// cloneRequest returns a copy of req targeting rawurl with the given body.
+func cloneRequest(req *http.Request, rawurl string, body []byte) (*http.Request, error) {
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	out.Header = req.Header.Clone()
+	out.ContentLength = int64(len(body))
+	out.GetBody = func() (io.ReadCloser, error) { return newBody(body), nil }
+	return out, nil
+}
+
+func newBody(body []byte) io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(body))
+}
+
+// newResponse builds a response to req carrying body.
+func newResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          newBody(body),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// isRateLimitError reports whether a JSON-RPC error means the endpoint is throttling us.
+func isRateLimitError(e *jsonrpcError) bool {
+	if e == nil {
+		return false
+	}
+
+	// Providers and gateways also signal throttling in messages, even when responding with HTTP 200.
+	return consts.ClassifyJsonRpcError(consts.JsonRpcErrorCode(e.Code), e.Message) == consts.ErrRateLimited
+}
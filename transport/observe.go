@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RequestObserver is called for each JSON-RPC request answered, err being the transport error,
+// the unexpected http status or the JSON-RPC error of the request.
+type RequestObserver func(method string, duration time.Duration, err error)
+
+var _ http.RoundTripper = (*ObserveTransport)(nil)
+
+// ObserveTransport reports the latency and the outcome of every JSON-RPC request sent through base,
+// requests of a batch included.
+type ObserveTransport struct {
+	base    http.RoundTripper
+	observe RequestObserver
+}
+
+// NewObserveTransport creates a transport reporting requests sent through base to observe.
+// If base is nil, http.DefaultTransport is used.
+func NewObserveTransport(base http.RoundTripper, observe RequestObserver) *ObserveTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &ObserveTransport{
+		base:    base,
+		observe: observe,
+	}
+}
+
+func (t *ObserveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, _, err := parseMessages(body)
+	if err != nil {
+		return t.base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+
+	if err != nil {
+		t.observeAll(msgs, duration, err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.observeAll(msgs, duration, fmt.Errorf("unexpected status: %v", resp.Status))
+		return resp, nil
+	}
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		t.observeAll(msgs, duration, err)
+		return nil, err
+	}
+	resp.Body = newBody(respBody)
+
+	resps, _, err := parseMessages(respBody)
+	if err != nil {
+		t.observeAll(msgs, duration, fmt.Errorf("invalid response: %v", err))
+		return resp, nil
+	}
+
+	byId := make(map[string]*jsonrpcMessage, len(resps))
+	for _, r := range resps {
+		byId[string(r.ID)] = r
+	}
+
+	for _, msg := range msgs {
+		var err error
+		if r, ok := byId[string(msg.ID)]; !ok {
+			err = fmt.Errorf("no response")
+		} else if r.Error != nil {
+			err = fmt.Errorf("json-rpc error(code=%d, msg=%q)", r.Error.Code, r.Error.Message)
+		}
+		t.observe(msg.Method, duration, err)
+	}
+
+	return resp, nil
+}
+
+func (t *ObserveTransport) observeAll(msgs []*jsonrpcMessage, duration time.Duration, err error) {
+	for _, msg := range msgs {
+		t.observe(msg.Method, duration, err)
+	}
+}
@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserveTransport(t *testing.T) {
+	s, _, _ := newBatchServer(t)
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	rt := NewObserveTransport(nil, func(method string, duration time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[method] = err
+	})
+
+	postJson(t, rt, s.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_echo","params":["0x1"]}`)
+
+	client := &http.Client{Transport: rt}
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"eth_fail","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber","params":[]}]`
+	resp, err := client.Post(s.URL, "application/json", strings.NewReader(batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(errs) != 3 {
+		t.Fatalf("want every request of the batch observed, got %v", errs)
+	}
+	if errs["eth_echo"] != nil || errs["eth_blockNumber"] != nil {
+		t.Fatalf("want requests succeeded, got %v", errs)
+	}
+	if errs["eth_fail"] == nil {
+		t.Fatal("want json-rpc error observed")
+	}
+}
+
+func TestObserveTransport_Unavailable(t *testing.T) {
+	s, _ := newTestServer(t, http.StatusServiceUnavailable, "")
+
+	var observed []error
+	rt := NewObserveTransport(nil, func(method string, duration time.Duration, err error) {
+		observed = append(observed, err)
+	})
+
+	client := &http.Client{Transport: rt}
+	resp, err := client.Post(s.URL, "application/json", strings.NewReader(request("eth_blockNumber")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(observed) != 1 || observed[0] == nil {
+		t.Fatalf("want unexpected status observed, got %v", observed)
+	}
+}
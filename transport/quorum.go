@@ -0,0 +1,356 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var ErrQuorumNotReached = errors.New("quorum not reached")
+
+// DefaultQuorumMethods are the read methods served with quorum when QuorumConfig.Methods is empty.
+var DefaultQuorumMethods = []string{
+	"eth_blockNumber",
+	"eth_getBlockByNumber",
+	"eth_getBlockByHash",
+	"eth_call",
+	"eth_getLogs",
+	"eth_getBalance",
+	"eth_getCode",
+	"eth_getStorageAt",
+}
+
+// EqualFunc reports whether two results of method are considered the same answer.
+type EqualFunc func(method string, a, b json.RawMessage) bool
+
+// TieBreakFunc picks the winner among groups which all reached the threshold,
+// returning its index in groups. Groups are ordered by the priority of their first endpoint.
+type TieBreakFunc func(method string, groups []QuorumGroup) int
+
+type QuorumConfig struct {
+	// Number of endpoints queried (N). 0 means all endpoints.
+	Endpoints int
+	// Number of endpoints which must agree (M).
+	Threshold int
+	// Methods served with quorum, DefaultQuorumMethods if empty.
+	// Other methods keep the plain failover behavior.
+	Methods []string
+	// Compares results, DefaultEqual if nil.
+	Equal EqualFunc
+	// Breaks ties when several groups reached the threshold, DefaultTieBreak if nil.
+	TieBreak TieBreakFunc
+}
+
+// QuorumGroup is a set of endpoints which returned the same answer.
+type QuorumGroup struct {
+	Endpoints []string
+	Result    json.RawMessage
+	// Error is set instead of Result when the endpoints agreed on a JSON-RPC error.
+	Error string
+}
+
+// QuorumError is returned when not enough endpoints agreed on an answer.
+// It can be retrieved with errors.As from the errors returned by the client.
+type QuorumError struct {
+	Method    string
+	Threshold int
+	Groups    []QuorumGroup
+	// Endpoints which failed to give an answer at all.
+	Failures map[string]error
+}
+
+func (e *QuorumError) Error() string {
+	answers := make([]string, 0, len(e.Groups))
+	for _, g := range e.Groups {
+		answers = append(answers, fmt.Sprintf("%d endpoints", len(g.Endpoints)))
+	}
+
+	return fmt.Sprintf("%v for %v: %d endpoints must agree, got answers from [%v] and %d failures",
+		ErrQuorumNotReached, e.Method, e.Threshold, strings.Join(answers, ", "), len(e.Failures))
+}
+
+func (e *QuorumError) Is(target error) bool {
+	return target == ErrQuorumNotReached
+}
+
+// DefaultEqual compares results semantically, ignoring formatting and object key order.
+func DefaultEqual(method string, a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// NumberTolerance returns an EqualFunc which considers hex quantities (e.g. the result of
+// eth_blockNumber) within tolerance of each other equal, and falls back to DefaultEqual otherwise.
+func NumberTolerance(tolerance uint64) EqualFunc {
+	return func(method string, a, b json.RawMessage) bool {
+		var na, nb hexutil.Big
+		if json.Unmarshal(a, &na) != nil || json.Unmarshal(b, &nb) != nil {
+			return DefaultEqual(method, a, b)
+		}
+
+		diff := new(big.Int).Sub(na.ToInt(), nb.ToInt())
+		return diff.Abs(diff).Cmp(new(big.Int).SetUint64(tolerance)) <= 0
+	}
+}
+
+// DefaultTieBreak picks the largest group, then the one containing the highest priority endpoint.
+func DefaultTieBreak(method string, groups []QuorumGroup) int {
+	winner := 0
+	for i, g := range groups {
+		if len(g.Endpoints) > len(groups[winner].Endpoints) {
+			winner = i
+		}
+	}
+	return winner
+}
+
+func (c *QuorumConfig) requires(methods []string) bool {
+	quorumMethods := c.Methods
+	if len(quorumMethods) == 0 {
+		quorumMethods = DefaultQuorumMethods
+	}
+
+	for _, m := range methods {
+		for _, qm := range quorumMethods {
+			if m == qm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *QuorumConfig) requiresMethod(method string) bool {
+	return c.requires([]string{method})
+}
+
+func (c *QuorumConfig) equal(method string, a, b *jsonrpcMessage) bool {
+	if a.Error != nil || b.Error != nil {
+		return a.Error != nil && b.Error != nil &&
+			a.Error.Code == b.Error.Code && a.Error.Message == b.Error.Message && string(a.Error.Data) == string(b.Error.Data)
+	}
+
+	equal := c.Equal
+	if equal == nil {
+		equal = DefaultEqual
+	}
+	return equal(method, a.Result, b.Result)
+}
+
+func (c *QuorumConfig) tieBreak(method string, groups []QuorumGroup) int {
+	tieBreak := c.TieBreak
+	if tieBreak == nil {
+		tieBreak = DefaultTieBreak
+	}
+
+	winner := tieBreak(method, groups)
+	if winner < 0 || winner >= len(groups) {
+		winner = 0
+	}
+	return winner
+}
+
+// quorumAnswer is the response of one endpoint, indexed by request id.
+type quorumAnswer struct {
+	endpoint *Endpoint
+	msgs     map[string]*jsonrpcMessage
+	err      error
+}
+
+// roundTripQuorum sends the request to the configured number of endpoints concurrently,
+// and builds the response from the answers agreed by enough of them, request by request.
+func (t *FailoverTransport) roundTripQuorum(req *http.Request, body []byte) (*http.Response, error) {
+	config := t.config.Quorum
+
+	reqs, batch, err := parseMessages(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []*Endpoint
+	for _, e := range t.candidates() {
+		if config.Endpoints > 0 && len(endpoints) >= config.Endpoints {
+			break
+		}
+		if e.breaker.allow() {
+			endpoints = append(endpoints, e)
+		}
+	}
+
+	answers := make([]*quorumAnswer, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e *Endpoint) {
+			defer wg.Done()
+			answers[i] = t.askQuorum(req, e, body)
+		}(i, e)
+	}
+	wg.Wait()
+
+	if req.Context().Err() != nil {
+		return nil, req.Context().Err()
+	}
+
+	resps := make([]*jsonrpcMessage, 0, len(reqs))
+	for _, r := range reqs {
+		// Notifications have no id and no response.
+		if len(r.ID) == 0 {
+			continue
+		}
+
+		var resp *jsonrpcMessage
+		if config.requiresMethod(r.Method) {
+			resp, err = t.agree(r, answers)
+		} else {
+			resp, err = firstAnswer(r, answers)
+		}
+		if err != nil {
+			return nil, err
+		}
+		resps = append(resps, resp)
+	}
+
+	var respBody []byte
+	if batch {
+		respBody, err = json.Marshal(resps)
+	} else if len(resps) > 0 {
+		respBody, err = json.Marshal(resps[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse(req, http.StatusOK, respBody), nil
+}
+
+func (t *FailoverTransport) askQuorum(req *http.Request, e *Endpoint, body []byte) *quorumAnswer {
+	answer := &quorumAnswer{endpoint: e}
+
+	resp, err := t.send(req, e, body)
+	if err == nil {
+		var respBody []byte
+		respBody, err = readBody(resp.Body)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status: %v", resp.Status)
+		}
+		if err == nil {
+			var msgs []*jsonrpcMessage
+			msgs, _, err = parseMessages(respBody)
+			answer.msgs = make(map[string]*jsonrpcMessage, len(msgs))
+			for _, m := range msgs {
+				answer.msgs[string(m.ID)] = m
+			}
+		}
+	}
+
+	if err != nil {
+		if req.Context().Err() == nil {
+			log.Warn("rpc endpoint failed to answer quorum request", "url", e.URL, "err", err, "methods", requestMethods(body))
+			e.breaker.failure()
+		} else {
+			e.breaker.release()
+		}
+		answer.err = err
+		return answer
+	}
+
+	e.breaker.success()
+	return answer
+}
+
+// agree groups the answers to r and returns the one agreed by enough endpoints.
+func (t *FailoverTransport) agree(r *jsonrpcMessage, answers []*quorumAnswer) (*jsonrpcMessage, error) {
+	config := t.config.Quorum
+
+	var (
+		groups   []QuorumGroup
+		results  []*jsonrpcMessage
+		failures = make(map[string]error)
+	)
+
+	for _, a := range answers {
+		if a.err != nil {
+			failures[a.endpoint.URL] = a.err
+			continue
+		}
+
+		m, ok := a.msgs[string(r.ID)]
+		if !ok {
+			failures[a.endpoint.URL] = fmt.Errorf("missing response for request id %s", r.ID)
+			continue
+		}
+
+		grouped := false
+		for i := range groups {
+			if config.equal(r.Method, results[i], m) {
+				groups[i].Endpoints = append(groups[i].Endpoints, a.endpoint.URL)
+				grouped = true
+				break
+			}
+		}
+		if grouped {
+			continue
+		}
+
+		group := QuorumGroup{Endpoints: []string{a.endpoint.URL}, Result: m.Result}
+		if m.Error != nil {
+			group.Result = nil
+			group.Error = fmt.Sprintf("json-rpc error(code=%d, msg=%q)", m.Error.Code, m.Error.Message)
+		}
+		groups = append(groups, group)
+		results = append(results, m)
+	}
+
+	var (
+		reached        []QuorumGroup
+		reachedResults []*jsonrpcMessage
+	)
+	for i, g := range groups {
+		if len(g.Endpoints) >= config.Threshold {
+			reached = append(reached, g)
+			reachedResults = append(reachedResults, results[i])
+		}
+	}
+
+	if len(reached) == 0 {
+		return nil, &QuorumError{
+			Method:    r.Method,
+			Threshold: config.Threshold,
+			Groups:    groups,
+			Failures:  failures,
+		}
+	}
+
+	if len(groups) > 1 {
+		log.Warn("rpc endpoints disagree", "method", r.Method, "groups", len(groups))
+	}
+
+	return reachedResults[config.tieBreak(r.Method, reached)], nil
+}
+
+// firstAnswer returns the answer to r of the highest priority endpoint which answered.
+func firstAnswer(r *jsonrpcMessage, answers []*quorumAnswer) (*jsonrpcMessage, error) {
+	var lastErr error = ErrNoEndpointAvailable
+	for _, a := range answers {
+		if a.err != nil {
+			lastErr = a.err
+			continue
+		}
+		if m, ok := a.msgs[string(r.ID)]; ok {
+			return m, nil
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,187 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newJsonRpcServer answers every request of a single or batch body with the result given by results.
+func newJsonRpcServer(t *testing.T, results map[string]string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs, batch, err := parseMessages(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var resps []*jsonrpcMessage
+		for _, req := range reqs {
+			resps = append(resps, &jsonrpcMessage{Version: "2.0", ID: req.ID, Result: json.RawMessage(results[req.Method])})
+		}
+
+		if batch {
+			json.NewEncoder(w).Encode(resps)
+		} else {
+			json.NewEncoder(w).Encode(resps[0])
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newQuorumTransport(t *testing.T, servers []*httptest.Server, quorum QuorumConfig) *FailoverTransport {
+	var urls []string
+	for _, s := range servers {
+		urls = append(urls, s.URL)
+	}
+	return newTestTransport(t, urls, FailoverConfig{FailureThreshold: 3, OpenTimeout: time.Minute, Quorum: &quorum})
+}
+
+func post(ft *FailoverTransport, url, body string) (string, error) {
+	client := &http.Client{Transport: ft}
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	return string(respBody), err
+}
+
+func TestQuorum_Agree(t *testing.T) {
+	servers := []*httptest.Server{
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x10"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x1"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x10"`}),
+	}
+	ft := newQuorumTransport(t, servers, QuorumConfig{Threshold: 2})
+
+	got, err := post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg jsonrpcMessage
+	if err := json.Unmarshal([]byte(got), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Result) != `"0x10"` || string(msg.ID) != "1" {
+		t.Fatalf("unexpected response: %v", got)
+	}
+}
+
+func TestQuorum_Disagree(t *testing.T) {
+	servers := []*httptest.Server{
+		newJsonRpcServer(t, map[string]string{"eth_getLogs": `[]`}),
+		newJsonRpcServer(t, map[string]string{"eth_getLogs": `[{"blockNumber":"0x1"}]`}),
+		newJsonRpcServer(t, map[string]string{"eth_getLogs": `[{"blockNumber":"0x2"}]`}),
+	}
+	ft := newQuorumTransport(t, servers, QuorumConfig{Threshold: 2})
+
+	_, err := post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{}]}`)
+	if !errors.Is(err, ErrQuorumNotReached) {
+		t.Fatalf("expected quorum error, got %v", err)
+	}
+
+	var urlErr *url.Error
+	var quorumErr *QuorumError
+	if !errors.As(err, &urlErr) || !errors.As(err, &quorumErr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if quorumErr.Method != "eth_getLogs" || len(quorumErr.Groups) != 3 {
+		t.Fatalf("unexpected quorum error: %+v", quorumErr)
+	}
+}
+
+func TestQuorum_FailedEndpoint(t *testing.T) {
+	bad, _ := newTestServer(t, http.StatusInternalServerError, "error")
+	servers := []*httptest.Server{
+		bad,
+		newJsonRpcServer(t, map[string]string{"eth_call": `"0x01"`}),
+		newJsonRpcServer(t, map[string]string{"eth_call": `"0x01"`}),
+	}
+
+	ft := newQuorumTransport(t, servers, QuorumConfig{Threshold: 2})
+	got, err := post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `"0x01"`) {
+		t.Fatalf("unexpected response: %v", got)
+	}
+
+	ft = newQuorumTransport(t, servers, QuorumConfig{Threshold: 3})
+	_, err = post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`)
+	var quorumErr *QuorumError
+	if !errors.As(err, &quorumErr) || len(quorumErr.Failures) != 1 {
+		t.Fatalf("expected quorum error with one failure, got %v", err)
+	}
+}
+
+func TestQuorum_EqualAndTieBreak(t *testing.T) {
+	servers := []*httptest.Server{
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x10"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x11"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x20"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x20"`}),
+	}
+
+	highest := func(method string, groups []QuorumGroup) int {
+		return len(groups) - 1
+	}
+
+	ft := newQuorumTransport(t, servers, QuorumConfig{Threshold: 2, Equal: NumberTolerance(1), TieBreak: highest})
+	got, err := post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `"0x20"`) {
+		t.Fatalf("unexpected response: %v", got)
+	}
+
+	ft = newQuorumTransport(t, servers, QuorumConfig{Threshold: 2, Equal: NumberTolerance(1)})
+	got, err = post(ft, servers[0].URL, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `"0x10"`) {
+		t.Fatalf("default tie break should prefer the highest priority endpoint, got %v", got)
+	}
+}
+
+func TestQuorum_Batch(t *testing.T) {
+	servers := []*httptest.Server{
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x10"`, "eth_chainId": `"0x1"`}),
+		newJsonRpcServer(t, map[string]string{"eth_blockNumber": `"0x10"`, "eth_chainId": `"0x2"`}),
+	}
+	ft := newQuorumTransport(t, servers, QuorumConfig{Threshold: 2})
+
+	got, err := post(ft, servers[0].URL, `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_chainId","params":[]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []*jsonrpcMessage
+	if err := json.Unmarshal([]byte(got), &msgs); err != nil {
+		t.Fatal(err)
+	}
+	// eth_chainId is not a quorum method, the highest priority answer is used.
+	if len(msgs) != 2 || string(msgs[0].Result) != `"0x10"` || string(msgs[1].Result) != `"0x1"` {
+		t.Fatalf("unexpected response: %v", got)
+	}
+}
+
+func TestQuorum_InvalidConfig(t *testing.T) {
+	if _, err := NewFailoverTransport([]string{"http://a", "http://b"}, nil, FailoverConfig{Quorum: &QuorumConfig{Threshold: 3}}); err == nil {
+		t.Fatal("expected error for threshold above endpoints")
+	}
+}
@@ -0,0 +1,338 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority of requests waiting for the rate limit, higher ones are sent first.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities = 3
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority overrides the priority of requests sent with ctx.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	return p, ok
+}
+
+// DefaultMethodPriorities are used when RateLimit.MethodPriorities is nil, others are PriorityNormal.
+// Transactions are never starved by log backfills.
+var DefaultMethodPriorities = map[string]Priority{
+	"eth_sendRawTransaction": PriorityHigh,
+	"eth_sendTransaction":    PriorityHigh,
+	"eth_getLogs":            PriorityLow,
+}
+
+// DefaultMethodCosts are compute units of methods, as billed by common providers,
+// used when RateLimit.MethodCosts is nil.
+var DefaultMethodCosts = map[string]float64{
+	"eth_chainId":               0,
+	"net_version":               0,
+	"eth_blockNumber":           10,
+	"eth_feeHistory":            10,
+	"eth_maxPriorityFeePerGas":  10,
+	"eth_getTransactionReceipt": 15,
+	"eth_getBlockByNumber":      16,
+	"eth_getStorageAt":          17,
+	"eth_getTransactionByHash":  17,
+	"eth_gasPrice":              19,
+	"eth_getBalance":            19,
+	"eth_getBlockByHash":        21,
+	"eth_call":                  26,
+	"eth_getCode":               26,
+	"eth_getTransactionCount":   26,
+	"eth_getLogs":               75,
+	"eth_estimateGas":           87,
+	"eth_sendRawTransaction":    250,
+	"debug_traceTransaction":    309,
+	"eth_getBlockReceipts":      500,
+	"eth_callMany":              500,
+}
+
+// DefaultMethodCost is the cost of methods missing in the cost table.
+const DefaultMethodCost = 20
+
+// RateLimit of one endpoint. Zero rates are unlimited.
+type RateLimit struct {
+	RequestsPerSecond     float64
+	ComputeUnitsPerSecond float64
+	// MethodCosts in compute units, DefaultMethodCosts if nil.
+	MethodCosts map[string]float64
+	// MethodPriorities, DefaultMethodPriorities if nil.
+	MethodPriorities map[string]Priority
+}
+
+func (l RateLimit) cost(method string) float64 {
+	costs := l.MethodCosts
+	if costs == nil {
+		costs = DefaultMethodCosts
+	}
+	if cost, ok := costs[method]; ok {
+		return cost
+	}
+	return DefaultMethodCost
+}
+
+func (l RateLimit) priority(method string) Priority {
+	priorities := l.MethodPriorities
+	if priorities == nil {
+		priorities = DefaultMethodPriorities
+	}
+	if p, ok := priorities[method]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+type RateLimitConfig struct {
+	// Default applies to endpoints missing in Endpoints.
+	Default RateLimit
+	// Endpoints by url.
+	Endpoints map[string]RateLimit
+}
+
+var _ http.RoundTripper = (*RateLimitTransport)(nil)
+
+// RateLimitTransport holds requests until the rate limit of their endpoint allows them,
+// counting every request of a batch and its cost in compute units.
+// Waiting requests are sent by priority, then in arrival order.
+type RateLimitTransport struct {
+	base   http.RoundTripper
+	config RateLimitConfig
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+// NewRateLimitTransport creates a transport limiting requests sent through base.
+// If base is nil, http.DefaultTransport is used.
+func NewRateLimitTransport(base http.RoundTripper, config RateLimitConfig) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &RateLimitTransport{
+		base:     base,
+		config:   config,
+		limiters: make(map[string]*limiter),
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	l, rl := t.limiter(url)
+	if l == nil || req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := requestMethods(body)
+	requests := float64(len(methods))
+	if requests == 0 {
+		requests = 1
+	}
+
+	var cost float64
+	priority := PriorityLow
+	for _, method := range methods {
+		cost += rl.cost(method)
+		if p := rl.priority(method); p > priority {
+			priority = p
+		}
+	}
+	if len(methods) == 0 {
+		priority = PriorityNormal
+	}
+	if p, ok := PriorityFromContext(req.Context()); ok {
+		priority = p
+	}
+
+	if err := l.wait(req.Context(), priority, requests, cost); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+func (t *RateLimitTransport) limiter(url string) (*limiter, RateLimit) {
+	rl, ok := t.config.Endpoints[url]
+	if !ok {
+		rl = t.config.Default
+	}
+	if rl.RequestsPerSecond <= 0 && rl.ComputeUnitsPerSecond <= 0 {
+		return nil, rl
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.limiters[url]
+	if !ok {
+		l = newLimiter(rl.RequestsPerSecond, rl.ComputeUnitsPerSecond)
+		t.limiters[url] = l
+	}
+
+	return l, rl
+}
+
+// bucket of tokens refilled at rate per second, up to one second of tokens.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64) bucket {
+	return bucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// delay until n tokens are available. Costs above the burst only need a full bucket,
+// leaving it in debt.
+func (b *bucket) delay(n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	n = math.Min(n, b.rate)
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *bucket) take(n float64) {
+	if b.rate > 0 {
+		b.tokens -= n
+	}
+}
+
+type waiter struct {
+	requests float64
+	cost     float64
+	ready    chan struct{}
+}
+
+type limiter struct {
+	mu       sync.Mutex
+	requests bucket
+	cost     bucket
+	queues   [numPriorities][]*waiter
+	timer    *time.Timer
+}
+
+func newLimiter(requestsPerSecond, computeUnitsPerSecond float64) *limiter {
+	return &limiter{
+		requests: newBucket(requestsPerSecond),
+		cost:     newBucket(computeUnitsPerSecond),
+	}
+}
+
+func (l *limiter) wait(ctx context.Context, p Priority, requests, cost float64) error {
+	if p < PriorityLow {
+		p = PriorityLow
+	} else if p > PriorityHigh {
+		p = PriorityHigh
+	}
+
+	w := &waiter{requests: requests, cost: cost, ready: make(chan struct{})}
+
+	l.mu.Lock()
+	l.queues[p] = append(l.queues[p], w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		select {
+		case <-w.ready:
+			// granted meanwhile
+			return nil
+		default:
+		}
+
+		for i, queued := range l.queues[p] {
+			if queued == w {
+				l.queues[p] = append(l.queues[p][:i], l.queues[p][i+1:]...)
+				break
+			}
+		}
+		l.dispatch()
+		return ctx.Err()
+	}
+}
+
+// dispatch lets waiters through by priority as long as the buckets allow,
+// and schedules itself for when the next one can go. l.mu must be held.
+func (l *limiter) dispatch() {
+	now := time.Now()
+	l.requests.refill(now)
+	l.cost.refill(now)
+
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		for len(l.queues[p]) > 0 {
+			w := l.queues[p][0]
+			delay := max(l.requests.delay(w.requests), l.cost.delay(w.cost))
+			if delay > 0 {
+				if l.timer != nil {
+					l.timer.Stop()
+				}
+				l.timer = time.AfterFunc(delay, func() {
+					l.mu.Lock()
+					defer l.mu.Unlock()
+					l.dispatch()
+				})
+				return
+			}
+
+			l.requests.take(w.requests)
+			l.cost.take(w.cost)
+			l.queues[p] = l.queues[p][1:]
+			close(w.ready)
+		}
+	}
+}
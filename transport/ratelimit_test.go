@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newOrderServer records the methods in the order they're received.
+func newOrderServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var methods []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		methods = append(methods, requestMethods(body)...)
+		mu.Unlock()
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	}))
+	t.Cleanup(s.Close)
+
+	return s, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), methods...)
+	}
+}
+
+func request(method string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"%v","params":[]}`, method)
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	s, _ := newOrderServer(t)
+	rt := NewRateLimitTransport(nil, RateLimitConfig{
+		Endpoints: map[string]RateLimit{
+			s.URL: {RequestsPerSecond: 10},
+		},
+	})
+
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		postJson(t, rt, s.URL, request("eth_blockNumber"))
+	}
+	// a burst of 10, then one every 100ms
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("want requests limited to 10/s, took %v", elapsed)
+	}
+
+	// other endpoints are not limited by default
+	other, _ := newOrderServer(t)
+	start = time.Now()
+	for i := 0; i < 20; i++ {
+		postJson(t, rt, other.URL, request("eth_blockNumber"))
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("want other endpoint unlimited, took %v", elapsed)
+	}
+}
+
+func TestRateLimitTransport_ComputeUnits(t *testing.T) {
+	s, _ := newOrderServer(t)
+	rt := NewRateLimitTransport(nil, RateLimitConfig{
+		Default: RateLimit{
+			ComputeUnitsPerSecond: 100,
+			MethodCosts:           map[string]float64{"eth_getLogs": 50, "eth_chainId": 0},
+		},
+	})
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		postJson(t, rt, s.URL, request("eth_chainId"))
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatalf("want free methods unlimited, took %v", elapsed)
+	}
+
+	// a batch of 2 costs 100 units, the second batch waits for a second
+	batch := "[" + request("eth_getLogs") + "," + request("eth_getLogs") + "]"
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		client := &http.Client{Transport: rt}
+		resp, err := client.Post(s.URL, "application/json", strings.NewReader(batch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("want batches limited by compute units, took %v", elapsed)
+	}
+}
+
+func TestRateLimitTransport_Priority(t *testing.T) {
+	s, methods := newOrderServer(t)
+	rt := NewRateLimitTransport(nil, RateLimitConfig{Default: RateLimit{RequestsPerSecond: 5}})
+
+	for i := 0; i < 5; i++ {
+		postJson(t, rt, s.URL, request("eth_blockNumber"))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postJson(t, rt, s.URL, request("eth_getLogs"))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	postJson(t, rt, s.URL, request("eth_sendRawTransaction"))
+	wg.Wait()
+
+	got := methods()[5:]
+	if len(got) != 4 || got[0] != "eth_sendRawTransaction" {
+		t.Fatalf("want tx sent before log backfills waiting, got %v", got)
+	}
+
+	// waiting requests give up with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx = WithPriority(ctx, PriorityLow)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(request("eth_sendRawTransaction")))
+	if _, err := rt.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+}